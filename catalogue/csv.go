@@ -0,0 +1,226 @@
+package catalogue
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/volatiletech/null"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+//the columns of a catalogue file, an import needs the required ones and accepts them in any order
+const (
+	IDColumn                 = "id"
+	NameColumn               = "name"
+	CategoryColumn           = "category"
+	PriceColumn              = "price"
+	StrikeThroughPriceColumn = "strikethrough_price"
+	InStockColumn            = "in_stock"
+	BaseQuantityColumn       = "base_quantity"
+	ImageURLColumn           = "image_url"
+)
+
+//Columns are the columns of an export, in order
+var Columns = []string{IDColumn, NameColumn, CategoryColumn, PriceColumn, StrikeThroughPriceColumn, InStockColumn, BaseQuantityColumn, ImageURLColumn}
+
+var requiredColumns = []string{NameColumn, CategoryColumn, PriceColumn}
+
+var (
+	ErrEmptyFile   = errors.New("the file has no rows")
+	ErrTooManyRows = fmt.Errorf("a file can have at most %d rows", models.MaxCatalogueImportRows)
+)
+
+//Parse reads the rows of a catalogue file, the error is set when the file can't be read at all and
+//the row errors list every invalid value of the rows
+func Parse(r io.Reader) ([]models.CatalogueRow, []models.CatalogueRowError, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, ErrEmptyFile
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	columns := make(map[string]int, len(header))
+	for i, column := range header {
+		// spreadsheets save the file with a byte order mark
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))] = i
+	}
+	for _, column := range requiredColumns {
+		if _, ok := columns[column]; !ok {
+			return nil, nil, fmt.Errorf("missing column %s", column)
+		}
+	}
+
+	rows := make([]models.CatalogueRow, 0)
+	rowErrors := make([]models.CatalogueRowError, 0)
+	ids := make(map[int]int)
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if isBlank(record) {
+			continue
+		}
+		if len(rows) == models.MaxCatalogueImportRows {
+			return nil, nil, ErrTooManyRows
+		}
+		value := func(column string) string {
+			i, ok := columns[column]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		row, errs := parseRow(line, value)
+		if row.ID.Valid {
+			if first, ok := ids[row.ID.Int]; ok {
+				errs = append(errs, rowError(line, IDColumn, fmt.Sprintf("item %d is already on row %d", row.ID.Int, first)))
+			} else {
+				ids[row.ID.Int] = line
+			}
+		}
+		rows = append(rows, row)
+		rowErrors = append(rowErrors, errs...)
+	}
+	if len(rows) == 0 {
+		return nil, nil, ErrEmptyFile
+	}
+	return rows, rowErrors, nil
+}
+
+//categoryPath trims the levels of the category path and joins them with the separator, ok is false when a level is empty
+func categoryPath(category string) (string, bool) {
+	levels := strings.Split(category, strings.TrimSpace(models.CategoryPathSeparator))
+	for i := range levels {
+		levels[i] = strings.TrimSpace(levels[i])
+		if levels[i] == "" {
+			return "", false
+		}
+	}
+	return strings.Join(levels, models.CategoryPathSeparator), true
+}
+
+func parseRow(line int, value func(column string) string) (models.CatalogueRow, []models.CatalogueRowError) {
+	row := models.CatalogueRow{
+		Row:          line,
+		Name:         value(NameColumn),
+		Category:     value(CategoryColumn),
+		BaseQuantity: value(BaseQuantityColumn),
+		ImageURL:     value(ImageURLColumn),
+		InStock:      true,
+	}
+	errs := make([]models.CatalogueRowError, 0)
+	if id := value(IDColumn); id != "" {
+		parsed, err := strconv.Atoi(id)
+		if err != nil || parsed <= 0 {
+			errs = append(errs, rowError(line, IDColumn, "must be the id of an item or empty for a new one"))
+		}
+		row.ID = null.IntFrom(parsed)
+	}
+	if row.Name == "" {
+		errs = append(errs, rowError(line, NameColumn, "can't be empty"))
+	}
+	if row.Category == "" {
+		errs = append(errs, rowError(line, CategoryColumn, "can't be empty"))
+	} else if path, ok := categoryPath(row.Category); ok {
+		row.Category = path
+	} else {
+		errs = append(errs, rowError(line, CategoryColumn, "can't have an empty level"))
+	}
+	price, err := parsePrice(value(PriceColumn))
+	if err != nil || !price.Valid {
+		errs = append(errs, rowError(line, PriceColumn, "must be a price of 0 or more"))
+	}
+	row.Price = price.Float32
+	row.StrikeThroughPrice, err = parsePrice(value(StrikeThroughPriceColumn))
+	if err != nil {
+		errs = append(errs, rowError(line, StrikeThroughPriceColumn, "must be a price of 0 or more or empty"))
+	}
+	if inStock := value(InStockColumn); inStock != "" {
+		switch strings.ToLower(inStock) {
+		case "true", "yes", "y", "1":
+			row.InStock = true
+		case "false", "no", "n", "0":
+			row.InStock = false
+		default:
+			errs = append(errs, rowError(line, InStockColumn, "must be true or false"))
+		}
+	}
+	if row.ImageURL != "" {
+		imageURL, err := url.Parse(row.ImageURL)
+		if err != nil || (imageURL.Scheme != "http" && imageURL.Scheme != "https") || imageURL.Host == "" {
+			errs = append(errs, rowError(line, ImageURLColumn, "must be an http or https url"))
+		}
+	}
+	return row, errs
+}
+
+func parsePrice(value string) (null.Float32, error) {
+	if value == "" {
+		return null.Float32{}, nil
+	}
+	price, err := strconv.ParseFloat(value, 32)
+	if err != nil || price < 0 {
+		return null.Float32{}, fmt.Errorf("invalid price %q", value)
+	}
+	return null.Float32From(float32(price)), nil
+}
+
+func isBlank(record []string) bool {
+	for _, value := range record {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func rowError(line int, column, message string) models.CatalogueRowError {
+	return models.CatalogueRowError{Row: line, Column: column, Message: message}
+}
+
+//Write writes the rows as a catalogue file in the format Parse reads
+func Write(w io.Writer, rows []models.CatalogueRow) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(Columns); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record := []string{
+			"",
+			row.Name,
+			row.Category,
+			formatPrice(row.Price),
+			"",
+			strconv.FormatBool(row.InStock),
+			row.BaseQuantity,
+			row.ImageURL,
+		}
+		if row.ID.Valid {
+			record[0] = strconv.Itoa(row.ID.Int)
+		}
+		if row.StrikeThroughPrice.Valid {
+			record[4] = formatPrice(row.StrikeThroughPrice.Float32)
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func formatPrice(price float32) string {
+	return strconv.FormatFloat(float64(price), 'f', -1, 32)
+}
@@ -0,0 +1,2 @@
+//Package catalogue reads and writes the catalogue as CSV for bulk imports and exports by store managers
+package catalogue
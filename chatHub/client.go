@@ -0,0 +1,100 @@
+package chatHub
+
+import (
+	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+const (
+	writeWait      = 10 * time.Second
+	pongWait       = 60 * time.Second
+	pingPeriod     = (pongWait * 9) / 10
+	maxMessageSize = 4096
+	sendBufferSize = 32
+)
+
+// Client is a single websocket connection of a user to an order chat
+type Client struct {
+	UserID     int
+	Permission models.UserPermission
+	OrderID    int
+	conn       *websocket.Conn
+	send       chan models.ChatEvent
+	closed     bool
+	// mu guards replaying and pending, the events held back until the missed messages are replayed
+	mu        sync.Mutex
+	replaying bool
+	pending   []models.ChatEvent
+}
+
+func NewClient(conn *websocket.Conn, user *models.User, orderID int) *Client {
+	return &Client{
+		UserID:     user.ID,
+		Permission: user.Permission,
+		OrderID:    orderID,
+		conn:       conn,
+		send:       make(chan models.ChatEvent, sendBufferSize),
+	}
+}
+
+// ReadPump reads events from the connection and passes them to handle until the connection is closed
+func (c *Client) ReadPump(hub *Hub, handle func(c *Client, event models.ChatEvent)) {
+	defer func() {
+		hub.Unregister(c)
+		c.conn.Close()
+	}()
+
+	c.conn.SetReadLimit(maxMessageSize)
+	if err := c.conn.SetReadDeadline(time.Now().Add(pongWait)); err != nil {
+		logrus.Errorf("ReadPump: failed to set read deadline error: %v", err)
+		return
+	}
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		var event models.ChatEvent
+		if err := c.conn.ReadJSON(&event); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				logrus.Errorf("ReadPump: user %d order %d error: %v", c.UserID, c.OrderID, err)
+			}
+			return
+		}
+		event.OrderID = c.OrderID
+		event.Sender = c.UserID
+		handle(c, event)
+	}
+}
+
+// WritePump writes queued events and keep-alive pings to the connection
+func (c *Client) WritePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case event, ok := <-c.send:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				_ = c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.conn.WriteJSON(event); err != nil {
+				logrus.Errorf("WritePump: user %d order %d error: %v", c.UserID, c.OrderID, err)
+				return
+			}
+		case <-ticker.C:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,2 @@
+// Package chatHub keeps track of the websocket connections of every order chat
+package chatHub
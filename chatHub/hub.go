@@ -0,0 +1,145 @@
+package chatHub
+
+import (
+	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/sirupsen/logrus"
+	"sync"
+)
+
+// Hub holds the connected clients of each order chat, grouped by order id
+type Hub struct {
+	mu    sync.RWMutex
+	rooms map[int]map[*Client]struct{}
+}
+
+var HubInstance = NewHub()
+
+func NewHub() *Hub {
+	return &Hub{
+		rooms: make(map[int]map[*Client]struct{}),
+	}
+}
+
+// Register adds the client to the room of its order
+func (h *Hub) Register(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	room, ok := h.rooms[c.OrderID]
+	if !ok {
+		room = make(map[*Client]struct{})
+		h.rooms[c.OrderID] = room
+	}
+	room[c] = struct{}{}
+}
+
+// RegisterReplaying adds the client to the room of its order holding back the events sent to it until Replay, so the
+// messages posted while its missed messages are loaded are neither lost nor sent before them
+func (h *Hub) RegisterReplaying(c *Client) {
+	c.replaying = true
+	h.Register(c)
+}
+
+// Replay queues the missed messages for the client registered with RegisterReplaying and then the events held back
+// since, skipping the messages up to lastMessageID or replayed already
+func (h *Hub) Replay(c *Client, missed []models.Chat, lastMessageID int) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := range missed {
+		h.queue(c, models.ChatEvent{
+			Type:    models.ChatEventMessage,
+			OrderID: c.OrderID,
+			Chat:    &missed[i],
+		})
+		if missed[i].ID > lastMessageID {
+			lastMessageID = missed[i].ID
+		}
+	}
+	for _, event := range c.pending {
+		if event.Type == models.ChatEventMessage && event.Chat != nil && event.Chat.ID <= lastMessageID {
+			continue
+		}
+		h.queue(c, event)
+	}
+	c.pending = nil
+	c.replaying = false
+}
+
+// Unregister removes the client from its room and stops its write pump
+func (h *Hub) Unregister(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if c.closed {
+		return
+	}
+	c.closed = true
+	close(c.send)
+
+	room := h.rooms[c.OrderID]
+	delete(room, c)
+	if len(room) == 0 {
+		delete(h.rooms, c.OrderID)
+	}
+}
+
+// SendTo queues the event for a single client
+func (h *Hub) SendTo(c *Client, event models.ChatEvent) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	h.send(c, event)
+}
+
+// Broadcast queues the event for every client of the order chat except the given one
+func (h *Hub) Broadcast(orderID int, event models.ChatEvent, except *Client) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for c := range h.rooms[orderID] {
+		if c != except {
+			h.send(c, event)
+		}
+	}
+}
+
+// IsOnline tells if the user or staff of the order, other than the given user, is connected to the order chat.
+// Store managers watching the chat are not counted as they don't send receipts
+func (h *Hub) IsOnline(orderID, exceptUserID int) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for c := range h.rooms[orderID] {
+		if c.UserID != exceptUserID && c.Permission != models.StoreManager {
+			return true
+		}
+	}
+	return false
+}
+
+// send must be called with h.mu held
+func (h *Hub) send(c *Client, event models.ChatEvent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.replaying {
+		if len(c.pending) >= sendBufferSize {
+			logrus.Errorf("chatHub: replay buffer full for user %d in order %d, dropping %s event", c.UserID, c.OrderID, event.Type)
+			return
+		}
+		c.pending = append(c.pending, event)
+		return
+	}
+	h.queue(c, event)
+}
+
+// queue must be called with h.mu and c.mu held
+func (h *Hub) queue(c *Client, event models.ChatEvent) {
+	if c.closed {
+		return
+	}
+	select {
+	case c.send <- event:
+	default:
+		logrus.Errorf("chatHub: send buffer full for user %d in order %d, dropping %s event", c.UserID, c.OrderID, event.Type)
+	}
+}
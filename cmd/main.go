@@ -44,6 +44,46 @@ func InitiateCronJobs() error {
 	}
 	initiateScheduledOrder.Start()
 
+	releaseContactProxies := cron.New()
+	err = releaseContactProxies.AddFunc("@every 1m", func() {
+		cronJobs.ReleaseContactProxies()
+	})
+	if err != nil {
+		logrus.Errorf("cronJobs job releaseContactProxies intiation failed %v", err)
+		return err
+	}
+	releaseContactProxies.Start()
+
+	expireOrderItemChanges := cron.New()
+	err = expireOrderItemChanges.AddFunc("@every 1m", func() {
+		cronJobs.ExpireOrderItemChanges()
+	})
+	if err != nil {
+		logrus.Errorf("cronJobs job expireOrderItemChanges intiation failed %v", err)
+		return err
+	}
+	expireOrderItemChanges.Start()
+
+	applyItemPrices := cron.New()
+	err = applyItemPrices.AddFunc("@every 1m", func() {
+		cronJobs.ApplyItemPrices()
+	})
+	if err != nil {
+		logrus.Errorf("cronJobs job applyItemPrices intiation failed %v", err)
+		return err
+	}
+	applyItemPrices.Start()
+
+	sendBackInStockAlerts := cron.New()
+	err = sendBackInStockAlerts.AddFunc("@every 5m", func() {
+		cronJobs.SendBackInStockAlerts()
+	})
+	if err != nil {
+		logrus.Errorf("cronJobs job sendBackInStockAlerts intiation failed %v", err)
+		return err
+	}
+	sendBackInStockAlerts.Start()
+
 	logrus.Infof("cronJobs job initiation successfull ")
 	return nil
 }
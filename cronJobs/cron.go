@@ -81,3 +81,42 @@ func InitiateScheduledOrder() {
 	}
 
 }
+
+// ReleaseContactProxies releases the masked contacts of completed orders and the ones past their expiry
+func ReleaseContactProxies() {
+	proxies, err := dbHelpers.GetReleasableContactProxies(nil)
+	if err != nil {
+		logrus.Errorf("ReleaseContactProxies: failed to fetch contacts error: %v", err)
+		return
+	}
+	handlers.ReleaseContactProxies(proxies)
+}
+
+// ExpireOrderItemChanges applies the default to the item changes the user didn't answer in time
+func ExpireOrderItemChanges() {
+	changes, err := dbHelpers.GetExpiredItemChanges()
+	if err != nil {
+		logrus.Errorf("ExpireOrderItemChanges: failed to fetch changes error: %v", err)
+		return
+	}
+	handlers.ExpireOrderItemChanges(changes)
+}
+
+// ApplyItemPrices applies the scheduled price changes which took effect
+func ApplyItemPrices() {
+	applied, err := dbHelpers.ApplyDueItemPrices()
+	if err != nil {
+		logrus.Errorf("ApplyItemPrices: failed to apply price changes error: %v", err)
+		return
+	}
+	if applied > 0 {
+		logrus.Infof("ApplyItemPrices: applied %d price changes", applied)
+	}
+}
+
+// SendBackInStockAlerts sends again the back in stock alerts which failed to be sent
+func SendBackInStockAlerts() {
+	if err := dbHelpers.SendBackInStockAlerts(); err != nil {
+		logrus.Errorf("SendBackInStockAlerts: failed to send back in stock alerts error: %v", err)
+	}
+}
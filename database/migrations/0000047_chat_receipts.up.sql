@@ -0,0 +1,10 @@
+BEGIN;
+
+ALTER TABLE chat
+    ADD PRIMARY KEY (id),
+    ADD COLUMN delivered_at timestamptz,
+    ADD COLUMN read_at      timestamptz;
+
+CREATE INDEX chat_order_id_index ON chat (order_id, id);
+
+COMMIT;
@@ -0,0 +1,18 @@
+ALTER TYPE image_type ADD VALUE 'chat';
+
+BEGIN;
+
+CREATE TYPE chat_attachment_type AS ENUM (
+    'image',
+    'location',
+    'item'
+    );
+
+ALTER TABLE chat
+    ADD COLUMN attachment_type chat_attachment_type,
+    ADD COLUMN image_id        int REFERENCES images (id),
+    ADD COLUMN lat             decimal(9, 6),
+    ADD COLUMN long            decimal(9, 6),
+    ADD COLUMN item_id         int REFERENCES items (id);
+
+COMMIT;
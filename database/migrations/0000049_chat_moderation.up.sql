@@ -0,0 +1,41 @@
+BEGIN;
+
+CREATE TABLE chat_settings
+(
+    sm_id         int PRIMARY KEY REFERENCES users (id),
+    mask_phone    boolean     NOT NULL DEFAULT false,
+    mask_email    boolean     NOT NULL DEFAULT false,
+    blocked_words text[]      NOT NULL DEFAULT '{}',
+    created_at    timestamptz NOT NULL DEFAULT now(),
+    updated_at    timestamptz
+);
+
+CREATE TABLE chat_reports
+(
+    id              serial PRIMARY KEY,
+    chat_id         int         NOT NULL REFERENCES chat (id),
+    order_id        int         NOT NULL REFERENCES orders (id),
+    reported_by     int         NOT NULL REFERENCES users (id),
+    reason          text        NOT NULL,
+    created_at      timestamptz NOT NULL DEFAULT now(),
+    resolved_at     timestamptz,
+    resolved_by     int REFERENCES users (id),
+    resolution_note text
+);
+
+CREATE UNIQUE INDEX chat_reports_unique_reporter ON chat_reports (chat_id, reported_by);
+CREATE INDEX chat_reports_open_index ON chat_reports (order_id) WHERE resolved_at IS NULL;
+
+CREATE TABLE chat_mutes
+(
+    id         serial PRIMARY KEY,
+    order_id   int         NOT NULL REFERENCES orders (id),
+    user_id    int         NOT NULL REFERENCES users (id),
+    muted_by   int         NOT NULL REFERENCES users (id),
+    created_at timestamptz NOT NULL DEFAULT now(),
+    unmuted_at timestamptz
+);
+
+CREATE UNIQUE INDEX chat_mutes_active ON chat_mutes (order_id, user_id) WHERE unmuted_at IS NULL;
+
+COMMIT;
@@ -0,0 +1,25 @@
+BEGIN;
+
+CREATE TYPE contact_kind AS ENUM (
+    'proxy_number',
+    'call_token'
+    );
+
+CREATE TABLE contact_proxies
+(
+    id           serial PRIMARY KEY,
+    order_id     int          NOT NULL REFERENCES orders (id),
+    caller_id    int          NOT NULL REFERENCES users (id),
+    callee_id    int          NOT NULL REFERENCES users (id),
+    provider     text         NOT NULL,
+    kind         contact_kind NOT NULL,
+    value        text         NOT NULL,
+    provider_ref text         NOT NULL,
+    expires_at   timestamptz  NOT NULL,
+    created_at   timestamptz  NOT NULL DEFAULT now(),
+    released_at  timestamptz
+);
+
+CREATE UNIQUE INDEX contact_proxies_active ON contact_proxies (order_id, caller_id) WHERE released_at IS NULL;
+
+COMMIT;
@@ -0,0 +1,24 @@
+BEGIN;
+
+ALTER TABLE order_otp
+    DROP CONSTRAINT IF EXISTS order_otp_order_id_otp_key,
+    ADD PRIMARY KEY (order_id),
+    ADD COLUMN expires_at   timestamptz,
+    ADD COLUMN attempts     int NOT NULL DEFAULT 0,
+    ADD COLUMN locked_at    timestamptz,
+    ADD COLUMN resend_count int NOT NULL DEFAULT 0,
+    ADD COLUMN updated_at   timestamptz;
+
+CREATE TABLE order_otp_attempts
+(
+    id         serial PRIMARY KEY,
+    order_id   int         NOT NULL REFERENCES orders (id),
+    staff_id   int         NOT NULL REFERENCES users (id),
+    success    boolean     NOT NULL,
+    locked     boolean     NOT NULL DEFAULT false,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX order_otp_attempts_order_id_index ON order_otp_attempts (order_id);
+
+COMMIT;
@@ -0,0 +1,20 @@
+ALTER TYPE image_type ADD VALUE 'delivery-proof';
+
+BEGIN;
+
+CREATE TABLE delivery_proofs
+(
+    id                 serial PRIMARY KEY,
+    order_id           int         NOT NULL UNIQUE REFERENCES orders (id),
+    staff_id           int         NOT NULL REFERENCES users (id),
+    photo_image_id     int REFERENCES images (id),
+    signature_image_id int REFERENCES images (id),
+    lat                decimal(9, 6),
+    long               decimal(9, 6),
+    captured_at        timestamptz NOT NULL,
+    created_at         timestamptz NOT NULL DEFAULT now(),
+    updated_at         timestamptz,
+    CHECK (photo_image_id IS NOT NULL OR signature_image_id IS NOT NULL)
+);
+
+COMMIT;
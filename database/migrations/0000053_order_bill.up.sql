@@ -0,0 +1,10 @@
+BEGIN;
+
+ALTER TABLE order_items
+    ADD COLUMN item_id int REFERENCES items (id);
+
+ALTER TABLE orders
+    ADD COLUMN bill_submitted_at timestamptz,
+    ADD COLUMN bill_confirmed_at timestamptz;
+
+COMMIT;
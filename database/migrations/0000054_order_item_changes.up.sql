@@ -0,0 +1,37 @@
+BEGIN;
+
+ALTER TABLE order_items
+    ADD COLUMN id                serial PRIMARY KEY,
+    ADD COLUMN original_quantity int,
+    ADD COLUMN substitute_for    int REFERENCES order_items (id);
+
+UPDATE order_items
+SET original_quantity = quantity;
+
+ALTER TABLE order_items
+    ALTER COLUMN original_quantity SET DEFAULT 0,
+    ALTER COLUMN original_quantity SET NOT NULL;
+
+CREATE TYPE order_item_change_type AS ENUM ('substitute', 'reduce');
+CREATE TYPE order_item_change_status AS ENUM ('pending', 'approved', 'rejected', 'expired');
+
+CREATE TABLE order_item_changes
+(
+    id                 serial PRIMARY KEY,
+    order_id           int                      NOT NULL REFERENCES orders (id),
+    order_item_id      int                      NOT NULL REFERENCES order_items (id) ON DELETE CASCADE,
+    change_type        order_item_change_type   NOT NULL,
+    substitute_item_id int REFERENCES items (id),
+    quantity           int                      NOT NULL CHECK (quantity >= 0),
+    proposed_by        int                      NOT NULL REFERENCES users (id),
+    status             order_item_change_status NOT NULL DEFAULT 'pending',
+    expires_at         timestamptz              NOT NULL,
+    resolved_at        timestamptz,
+    created_at         timestamptz              NOT NULL DEFAULT now(),
+    CHECK (change_type <> 'substitute' OR (substitute_item_id IS NOT NULL AND quantity > 0))
+);
+
+CREATE UNIQUE INDEX order_item_changes_pending_idx ON order_item_changes (order_item_id) WHERE status = 'pending';
+CREATE INDEX order_item_changes_expiry_idx ON order_item_changes (expires_at) WHERE status = 'pending';
+
+COMMIT;
@@ -0,0 +1,35 @@
+BEGIN;
+
+CREATE TABLE store_details
+(
+    sm_id          int PRIMARY KEY REFERENCES users (id),
+    name           text        NOT NULL,
+    address        text        NOT NULL DEFAULT '',
+    gstin          text        NOT NULL DEFAULT '',
+    phone          text        NOT NULL DEFAULT '',
+    email          text        NOT NULL DEFAULT '',
+    invoice_prefix text        NOT NULL DEFAULT 'YD',
+    updated_at     timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE TABLE invoice_sequences
+(
+    sm_id       int PRIMARY KEY REFERENCES users (id),
+    last_number int NOT NULL
+);
+
+CREATE TABLE invoices
+(
+    id             serial PRIMARY KEY,
+    order_id       int UNIQUE  NOT NULL REFERENCES orders (id),
+    sm_id          int         NOT NULL REFERENCES users (id),
+    sequence       int         NOT NULL,
+    invoice_number text UNIQUE NOT NULL,
+    data           jsonb       NOT NULL,
+    bucket         text,
+    path           text,
+    issued_at      timestamptz NOT NULL DEFAULT now(),
+    UNIQUE (sm_id, sequence)
+);
+
+COMMIT;
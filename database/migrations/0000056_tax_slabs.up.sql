@@ -0,0 +1,31 @@
+BEGIN;
+
+CREATE TABLE tax_slabs
+(
+    id          serial PRIMARY KEY,
+    name        text          NOT NULL,
+    rate        decimal(5, 2) NOT NULL CHECK ( rate >= 0 AND rate <= 100 ),
+    inclusive   boolean       NOT NULL DEFAULT TRUE,
+    created_at  timestamptz   NOT NULL DEFAULT now(),
+    updated_at  timestamptz,
+    archived_at timestamptz
+);
+
+ALTER TABLE categories
+    ADD COLUMN tax_slab_id int REFERENCES tax_slabs (id);
+
+-- overrides the tax slab of the category
+ALTER TABLE items
+    ADD COLUMN tax_slab_id int REFERENCES tax_slabs (id);
+
+ALTER TABLE order_items
+    ADD COLUMN tax_name       text,
+    ADD COLUMN tax_rate       decimal(5, 2)  NOT NULL DEFAULT 0,
+    ADD COLUMN tax_inclusive  boolean        NOT NULL DEFAULT TRUE,
+    ADD COLUMN taxable_amount decimal(20, 3) NOT NULL DEFAULT 0,
+    ADD COLUMN tax_amount     decimal(20, 3) NOT NULL DEFAULT 0;
+
+UPDATE order_items
+SET taxable_amount = (price - (price * COALESCE(discount, 0) / 100)) * quantity;
+
+COMMIT;
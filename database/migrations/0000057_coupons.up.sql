@@ -0,0 +1,49 @@
+BEGIN;
+
+CREATE TYPE coupon_discount_type AS ENUM ('flat', 'percentage');
+CREATE TYPE coupon_scope AS ENUM ('order', 'category', 'item');
+
+CREATE TABLE coupons
+(
+    id              serial PRIMARY KEY,
+    code            text                 NOT NULL,
+    description     text                 NOT NULL DEFAULT '',
+    discount_type   coupon_discount_type NOT NULL,
+    value           decimal(10, 2)       NOT NULL CHECK ( value > 0 ),
+    max_discount    decimal(10, 2) CHECK ( max_discount IS NULL OR max_discount > 0 ),
+    scope           coupon_scope         NOT NULL DEFAULT 'order',
+    category_ids    int[]                NOT NULL DEFAULT '{}',
+    item_ids        int[]                NOT NULL DEFAULT '{}',
+    min_order_value decimal(10, 2)       NOT NULL DEFAULT 0,
+    usage_limit     int CHECK ( usage_limit IS NULL OR usage_limit > 0 ),
+    per_user_limit  int CHECK ( per_user_limit IS NULL OR per_user_limit > 0 ),
+    stackable       boolean              NOT NULL DEFAULT FALSE,
+    starts_at       timestamptz          NOT NULL DEFAULT now(),
+    ends_at         timestamptz,
+    created_by      int                  NOT NULL REFERENCES users (id),
+    created_at      timestamptz          NOT NULL DEFAULT now(),
+    updated_at      timestamptz,
+    archived_at     timestamptz,
+    CHECK ( discount_type = 'flat' OR value <= 100 ),
+    CHECK ( ends_at IS NULL OR ends_at > starts_at )
+);
+
+CREATE UNIQUE INDEX active_coupon_code ON coupons (upper(code)) WHERE archived_at IS NULL;
+
+CREATE TABLE coupon_redemptions
+(
+    id         serial PRIMARY KEY,
+    coupon_id  int                     NOT NULL REFERENCES coupons (id),
+    user_id    int                     NOT NULL REFERENCES users (id),
+    order_id   int UNIQUE              NOT NULL REFERENCES orders (id),
+    discount   decimal(20, 3)          NOT NULL,
+    created_at timestamptz             NOT NULL DEFAULT now()
+);
+
+CREATE INDEX coupon_redemptions_coupon_user_idx ON coupon_redemptions (coupon_id, user_id);
+
+ALTER TABLE orders
+    ADD COLUMN coupon_id       int REFERENCES coupons (id),
+    ADD COLUMN coupon_discount decimal(20, 3) NOT NULL DEFAULT 0;
+
+COMMIT;
@@ -0,0 +1,28 @@
+BEGIN;
+
+CREATE TABLE delivery_settings
+(
+    sm_id           int PRIMARY KEY REFERENCES users (id),
+    min_order_value decimal     NOT NULL DEFAULT 0 CHECK (min_order_value >= 0),
+    updated_at      timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE TABLE delivery_fee_rules
+(
+    id           serial PRIMARY KEY,
+    sm_id        int         NOT NULL REFERENCES users (id),
+    mode         order_mode  NOT NULL,
+    min_distance decimal     NOT NULL DEFAULT 0 CHECK (min_distance >= 0),
+    max_distance decimal CHECK (max_distance > min_distance),
+    fee          decimal     NOT NULL CHECK (fee >= 0),
+    free_above   decimal CHECK (free_above > 0),
+    created_at   timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX delivery_fee_rules_sm_id_mode_idx ON delivery_fee_rules (sm_id, mode);
+
+ALTER TABLE orders
+    ADD COLUMN delivery_distance decimal,
+    ADD COLUMN delivery_fee      decimal NOT NULL DEFAULT 0;
+
+COMMIT;
@@ -0,0 +1,21 @@
+BEGIN;
+
+CREATE TABLE carts
+(
+    user_id     int PRIMARY KEY REFERENCES users (id),
+    address_id  int REFERENCES address (id),
+    coupon_code text,
+    updated_at  timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE TABLE cart_items
+(
+    user_id    int         NOT NULL REFERENCES carts (user_id) ON DELETE CASCADE,
+    item_id    int         NOT NULL REFERENCES items (id),
+    quantity   int         NOT NULL CHECK (quantity > 0),
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, item_id)
+);
+
+COMMIT;
@@ -0,0 +1,23 @@
+BEGIN;
+
+CREATE TABLE baskets
+(
+    id          serial PRIMARY KEY,
+    user_id     int         NOT NULL REFERENCES users (id),
+    name        text        NOT NULL,
+    created_at  timestamptz NOT NULL DEFAULT now(),
+    updated_at  timestamptz NOT NULL DEFAULT now(),
+    archived_at timestamptz
+);
+
+CREATE UNIQUE INDEX baskets_user_id_name_idx ON baskets (user_id, lower(name)) WHERE archived_at IS NULL;
+
+CREATE TABLE basket_items
+(
+    basket_id int NOT NULL REFERENCES baskets (id) ON DELETE CASCADE,
+    item_id   int NOT NULL REFERENCES items (id),
+    quantity  int NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (basket_id, item_id)
+);
+
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX items_search_idx ON items
+    USING gin (to_tsvector('simple', name || ' ' || COALESCE(base_quantity, '')))
+    WHERE archived_at IS NULL;
+
+COMMIT;
@@ -0,0 +1,55 @@
+BEGIN;
+
+CREATE TYPE unit_of_measure AS ENUM ('g', 'kg', 'ml', 'l', 'pc');
+
+CREATE TABLE item_variants
+(
+    id                  serial PRIMARY KEY,
+    item_id             int             NOT NULL REFERENCES items (id),
+    name                text            NOT NULL,
+    quantity            decimal         NOT NULL CHECK (quantity > 0),
+    unit                unit_of_measure NOT NULL,
+    price               decimal(20, 3)  NOT NULL CHECK (price >= 0),
+    strikethrough_price decimal(20, 3),
+    in_stock            boolean         NOT NULL DEFAULT TRUE,
+    created_at          timestamptz     NOT NULL DEFAULT now(),
+    archived_at         timestamptz
+);
+
+CREATE UNIQUE INDEX item_variants_item_id_quantity_unit_idx ON item_variants (item_id, quantity, unit) WHERE archived_at IS NULL;
+
+CREATE TABLE item_variant_images
+(
+    id         serial PRIMARY KEY,
+    variant_id int         NOT NULL REFERENCES item_variants (id),
+    image_id   int         NOT NULL REFERENCES images (id),
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX item_variant_images_variant_id_idx ON item_variant_images (variant_id);
+
+-- the variant ordered is snapshot with its pack size
+ALTER TABLE order_items
+    ADD COLUMN variant_id    int REFERENCES item_variants (id),
+    ADD COLUMN unit_quantity decimal,
+    ADD COLUMN unit          unit_of_measure;
+
+ALTER TABLE scheduled_ordered_items
+    ADD COLUMN variant_id    int REFERENCES item_variants (id),
+    ADD COLUMN unit_quantity decimal,
+    ADD COLUMN unit          unit_of_measure;
+
+-- a cart or a basket can hold several pack sizes of the same item
+ALTER TABLE cart_items
+    ADD COLUMN variant_id int REFERENCES item_variants (id),
+    DROP CONSTRAINT cart_items_pkey;
+
+CREATE UNIQUE INDEX cart_items_user_id_item_id_variant_id_idx ON cart_items (user_id, item_id, COALESCE(variant_id, 0));
+
+ALTER TABLE basket_items
+    ADD COLUMN variant_id int REFERENCES item_variants (id),
+    DROP CONSTRAINT basket_items_pkey;
+
+CREATE UNIQUE INDEX basket_items_basket_id_item_id_variant_id_idx ON basket_items (basket_id, item_id, COALESCE(variant_id, 0));
+
+COMMIT;
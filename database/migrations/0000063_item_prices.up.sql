@@ -0,0 +1,35 @@
+BEGIN;
+
+-- every price an item or one of its variants had or will have, applied_at is set once it is the price of the catalogue
+CREATE TABLE item_prices
+(
+    id                  serial PRIMARY KEY,
+    item_id             int            NOT NULL REFERENCES items (id),
+    variant_id          int REFERENCES item_variants (id),
+    price               decimal(20, 3) NOT NULL CHECK (price >= 0),
+    strikethrough_price decimal(20, 3),
+    effective_from      timestamptz    NOT NULL,
+    applied_at          timestamptz,
+    created_at          timestamptz    NOT NULL DEFAULT now(),
+    cancelled_at        timestamptz
+);
+
+CREATE INDEX item_prices_item_id_variant_id_idx ON item_prices (item_id, variant_id, applied_at);
+CREATE INDEX item_prices_pending_idx ON item_prices (effective_from) WHERE applied_at IS NULL AND cancelled_at IS NULL;
+
+INSERT INTO item_prices (item_id, price, strikethrough_price, effective_from, applied_at)
+SELECT id, price, strikethrough_price, created_at, created_at
+FROM items;
+
+INSERT INTO item_prices (item_id, variant_id, price, strikethrough_price, effective_from, applied_at)
+SELECT item_id, id, price, strikethrough_price, created_at, created_at
+FROM item_variants;
+
+-- the price version an ordered item was priced with
+ALTER TABLE order_items
+    ADD COLUMN price_id int REFERENCES item_prices (id);
+
+ALTER TABLE scheduled_ordered_items
+    ADD COLUMN price_id int REFERENCES item_prices (id);
+
+COMMIT;
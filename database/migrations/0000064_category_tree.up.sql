@@ -0,0 +1,22 @@
+ALTER TYPE image_type ADD VALUE 'category';
+
+BEGIN;
+
+ALTER TABLE categories
+    ADD COLUMN parent_id     int REFERENCES categories (id),
+    ADD COLUMN position      int NOT NULL DEFAULT 0,
+    ADD COLUMN image_id      int REFERENCES images (id),
+    ADD COLUMN visible_from  timestamptz,
+    ADD COLUMN visible_until timestamptz,
+    ADD CONSTRAINT categories_parent_id_check CHECK (parent_id <> id),
+    ADD CONSTRAINT categories_visible_check CHECK (visible_until > visible_from);
+
+CREATE INDEX categories_parent_id_idx ON categories (parent_id) WHERE archived_at IS NULL;
+
+-- keep the current order, which was the order of creation
+UPDATE categories
+SET position = ordered.position
+FROM (SELECT id, row_number() OVER (ORDER BY id) AS position FROM categories) ordered
+WHERE ordered.id = categories.id;
+
+COMMIT;
@@ -0,0 +1,33 @@
+BEGIN;
+
+CREATE TYPE item_review_status AS ENUM ('pending', 'approved', 'rejected');
+
+CREATE TABLE item_reviews
+(
+    id              serial PRIMARY KEY,
+    item_id         int                NOT NULL REFERENCES items (id),
+    user_id         int                NOT NULL REFERENCES users (id),
+    order_id        int                NOT NULL REFERENCES orders (id),
+    rating          int                NOT NULL CHECK (rating >= 1 AND rating <= 5),
+    review          text               NOT NULL DEFAULT '',
+    status          item_review_status NOT NULL DEFAULT 'pending',
+    moderated_by    int REFERENCES users (id),
+    moderated_at    timestamptz,
+    moderation_note text,
+    created_at      timestamptz        NOT NULL DEFAULT now(),
+    updated_at      timestamptz,
+    archived_at     timestamptz
+);
+
+-- a user reviews an item once, a new review replaces the old one
+CREATE UNIQUE INDEX item_reviews_unique_user ON item_reviews (item_id, user_id) WHERE archived_at IS NULL;
+CREATE INDEX item_reviews_status_idx ON item_reviews (status) WHERE archived_at IS NULL;
+
+-- the aggregate of the approved reviews, kept on the item so the catalogue can sort by it
+ALTER TABLE items
+    ADD COLUMN rating       real NOT NULL DEFAULT 0,
+    ADD COLUMN rating_count int  NOT NULL DEFAULT 0;
+
+CREATE INDEX items_rating_idx ON items (rating DESC, id DESC) WHERE archived_at IS NULL;
+
+COMMIT;
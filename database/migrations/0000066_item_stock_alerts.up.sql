@@ -0,0 +1,19 @@
+BEGIN;
+
+CREATE TABLE item_stock_alerts
+(
+    id          serial PRIMARY KEY,
+    item_id     int         NOT NULL REFERENCES items (id),
+    variant_id  int REFERENCES item_variants (id),
+    user_id     int         NOT NULL REFERENCES users (id),
+    created_at  timestamptz NOT NULL DEFAULT now(),
+    notified_at timestamptz,
+    archived_at timestamptz
+);
+
+-- a user waits once for an item, a new alert can be set after being notified
+CREATE UNIQUE INDEX item_stock_alerts_unique_user ON item_stock_alerts (item_id, COALESCE(variant_id, 0), user_id)
+    WHERE notified_at IS NULL AND archived_at IS NULL;
+CREATE INDEX item_stock_alerts_user_idx ON item_stock_alerts (user_id) WHERE notified_at IS NULL AND archived_at IS NULL;
+
+COMMIT;
@@ -0,0 +1,15 @@
+BEGIN;
+
+-- a chat image can only be attached by its uploader in the chat of the order it was uploaded for
+ALTER TABLE images
+    ADD COLUMN order_id    int REFERENCES orders (id),
+    ADD COLUMN uploaded_by int REFERENCES users (id);
+
+UPDATE images
+SET order_id    = c.order_id,
+    uploaded_by = c.sender
+FROM chat c
+WHERE c.image_id = images.id
+  AND images.type = 'chat';
+
+COMMIT;
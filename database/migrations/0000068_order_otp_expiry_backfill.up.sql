@@ -0,0 +1,8 @@
+BEGIN;
+
+-- otps issued before they had an expiry would all read as expired, give them the full validity from now
+UPDATE order_otp
+SET expires_at = now() + interval '2 hours'
+WHERE expires_at IS NULL;
+
+COMMIT;
@@ -0,0 +1,12 @@
+BEGIN;
+
+-- misspelt searches match names and categories with the <% operator, served by these trigram indexes
+CREATE INDEX items_name_trgm_idx ON items
+    USING gin (name gin_trgm_ops)
+    WHERE archived_at IS NULL;
+
+CREATE INDEX categories_category_trgm_idx ON categories
+    USING gin (category gin_trgm_ops)
+    WHERE archived_at IS NULL;
+
+COMMIT;
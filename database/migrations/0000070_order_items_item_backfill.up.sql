@@ -0,0 +1,13 @@
+BEGIN;
+
+-- order items placed before they referenced the item get it back when their name matches a single item
+UPDATE order_items
+SET item_id = matched.id
+FROM (SELECT lower(trim(name)) AS name, min(id) AS id
+      FROM items
+      GROUP BY lower(trim(name))
+      HAVING count(*) = 1) matched
+WHERE order_items.item_id IS NULL
+  AND lower(trim(order_items.name)) = matched.name;
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+-- an alert is claimed while it is being sent and given up after too many failed sends
+ALTER TABLE item_stock_alerts
+    ADD COLUMN sending_at timestamptz,
+    ADD COLUMN attempts   int NOT NULL DEFAULT 0;
+
+COMMIT;
@@ -0,0 +1,149 @@
+package dbHelpers
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/RemoteState/yourdaily-server/database"
+	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var basketItemSelect = `SELECT bi.basket_id,
+				   bi.item_id,
+				   bi.variant_id,
+				   bi.quantity,
+				   ` + lineName + ` AS name,
+				   ` + lineBaseQuantity + ` AS base_quantity,
+				   ` + linePrice + ` AS price,
+				   ` + lineAvailable + ` AS available,
+				   ` + lineArchived + ` AS archived
+			FROM basket_items bi
+					 JOIN items ON items.id = bi.item_id
+					 ` + itemVariantJoin("bi.variant_id")
+
+//GetBaskets returns the baskets of the user with their items
+func GetBaskets(userID int) ([]models.Basket, error) {
+	baskets := make([]models.Basket, 0)
+	query := `SELECT id, name, created_at, updated_at FROM baskets WHERE user_id = $1 AND archived_at IS NULL ORDER BY name`
+	if err := database.YourDailyDB.Select(&baskets, query, userID); err != nil {
+		return baskets, err
+	}
+	basketIDs := make(pq.Int64Array, 0, len(baskets))
+	for _, basket := range baskets {
+		basketIDs = append(basketIDs, int64(basket.ID))
+	}
+	items := make([]models.BasketItem, 0)
+	if err := database.YourDailyDB.Select(&items, basketItemSelect+` WHERE bi.basket_id = ANY ($1) ORDER BY items.name, bi.variant_id`, basketIDs); err != nil {
+		return baskets, err
+	}
+	for i := range baskets {
+		baskets[i].Items = make([]models.BasketItem, 0)
+		for _, item := range items {
+			if item.BasketID == baskets[i].ID {
+				baskets[i].Items = append(baskets[i].Items, item)
+			}
+		}
+	}
+	return baskets, nil
+}
+
+//GetBasket returns the basket of the user with its items
+func GetBasket(basketID, userID int) (models.Basket, error) {
+	basket := models.Basket{Items: make([]models.BasketItem, 0)}
+	query := `SELECT id, name, created_at, updated_at FROM baskets WHERE id = $1 AND user_id = $2 AND archived_at IS NULL`
+	if err := database.YourDailyDB.Get(&basket, query, basketID, userID); err != nil {
+		return basket, err
+	}
+	err := database.YourDailyDB.Select(&basket.Items, basketItemSelect+` WHERE bi.basket_id = $1 ORDER BY items.name, bi.variant_id`, basketID)
+	return basket, err
+}
+
+//InsertBasket saves the items as a basket of the user
+func InsertBasket(userID int, name string, items []models.ItemInfo) (int, error) {
+	var basketID int
+	err := database.Tx(func(tx *sqlx.Tx) error {
+		query := `INSERT INTO baskets (user_id, name) VALUES ($1, $2) RETURNING id`
+		if err := tx.Get(&basketID, query, userID, name); err != nil {
+			return err
+		}
+		return insertBasketItems(tx, basketID, items)
+	})
+	return basketID, err
+}
+
+//UpdateBasket renames the basket and replaces its items, sql.ErrNoRows when the user has no such basket
+func UpdateBasket(basketID, userID int, name string, items []models.ItemInfo) error {
+	return database.Tx(func(tx *sqlx.Tx) error {
+		query := `UPDATE baskets SET name = $3, updated_at = now() WHERE id = $1 AND user_id = $2 AND archived_at IS NULL`
+		if err := execAffectingOne(tx, query, basketID, userID, name); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM basket_items WHERE basket_id = $1`, basketID); err != nil {
+			return err
+		}
+		return insertBasketItems(tx, basketID, items)
+	})
+}
+
+//ArchiveBasket removes the basket of the user, sql.ErrNoRows when the user has no such basket
+func ArchiveBasket(basketID, userID int) error {
+	query := `UPDATE baskets SET archived_at = now() WHERE id = $1 AND user_id = $2 AND archived_at IS NULL`
+	return execAffectingOne(database.YourDailyDB, query, basketID, userID)
+}
+
+func insertBasketItems(tx *sqlx.Tx, basketID int, items []models.ItemInfo) error {
+	query := `INSERT INTO basket_items (basket_id, item_id, variant_id, quantity)
+			SELECT $1, items.id, iv.id, $3
+			FROM items
+					 ` + itemVariantJoin("$4") + `
+			WHERE items.id = $2
+			  AND items.archived_at IS NULL
+			  AND ` + lineVariantFound("$4::int")
+	for _, item := range items {
+		result, err := tx.Exec(query, basketID, item.Id, item.Quantity, item.VariantID)
+		if err != nil {
+			return err
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return fmt.Errorf("%w: %d", ErrItemNotFound, item.Id)
+		}
+	}
+	return nil
+}
+
+//GetReorderItems returns the items the user ordered in the order with their current catalogue details,
+//substitutes the staff added are left out, sql.ErrNoRows when the user has no such order
+func GetReorderItems(orderID, userID int) ([]models.BasketItem, error) {
+	items := make([]models.BasketItem, 0)
+	var count int
+	if err := database.YourDailyDB.Get(&count, `SELECT count(*) FROM orders WHERE id = $1 AND user_id = $2`, orderID, userID); err != nil {
+		return items, err
+	}
+	if count == 0 {
+		return items, sql.ErrNoRows
+	}
+	// lines of orders placed before the items were referenced are kept by name with item id 0
+	query := `SELECT COALESCE(oi.item_id, 0) AS item_id,
+				   oi.variant_id,
+				   SUM(GREATEST(oi.original_quantity, oi.quantity)) AS quantity,
+				   COALESCE(` + lineName + `, MIN(oi.name)) AS name,
+				   ` + lineBaseQuantity + ` AS base_quantity,
+				   COALESCE(` + linePrice + `, 0) AS price,
+				   COALESCE(` + lineAvailable + `, FALSE) AS available,
+				   COALESCE(` + lineArchived + `, FALSE) AS archived
+			FROM order_items oi
+					 LEFT JOIN items ON items.id = oi.item_id
+					 ` + itemVariantJoin("oi.variant_id") + `
+			WHERE oi.order_id = $1
+			  AND oi.substitute_for IS NULL
+			GROUP BY oi.item_id, oi.variant_id, items.id, iv.id, CASE WHEN oi.item_id IS NULL THEN oi.name END
+			HAVING SUM(GREATEST(oi.original_quantity, oi.quantity)) > 0
+			ORDER BY MIN(oi.id)`
+	err := database.YourDailyDB.Select(&items, query, orderID)
+	return items, err
+}
@@ -0,0 +1,214 @@
+package dbHelpers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/RemoteState/yourdaily-server/database"
+	"github.com/RemoteState/yourdaily-server/firebase"
+	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/RemoteState/yourdaily-server/pricing"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"github.com/volatiletech/null"
+)
+
+var (
+	//ErrItemUnavailable is returned when an item is out of stock or no longer sold
+	ErrItemUnavailable = errors.New("item is not available")
+	//ErrCartEmpty is returned when an empty cart is ordered
+	ErrCartEmpty = errors.New("cart is empty")
+)
+
+var cartLineSelect = `SELECT ci.item_id,
+				   ci.variant_id,
+				   ci.quantity,
+				   ` + lineName + ` AS name,
+				   c.category,
+				   ` + lineBaseQuantity + ` AS base_quantity,
+				   ` + linePrice + ` AS price,
+				   ` + lineStrikeThroughPrice + ` AS strikethrough_price,
+				   iv.quantity AS unit_quantity,
+				   iv.unit,
+				   COALESCE(ts.rate, 0)         AS tax_rate,
+				   COALESCE(ts.inclusive, TRUE) AS tax_inclusive,
+				   ` + lineAvailable + ` AS available,
+				   img.bucket,
+				   img.path
+			FROM cart_items ci
+					 JOIN items ON items.id = ci.item_id
+					 JOIN categories c ON c.id = items.category
+					 ` + itemTaxSlabJoin + `
+					 ` + itemVariantJoin("ci.variant_id") + `
+					 ` + lineImageJoin + `
+			WHERE ci.user_id = $1
+			ORDER BY ci.created_at, ci.item_id`
+
+//GetCart returns the cart of the user priced with the active offer, an empty cart when the user has none
+func GetCart(userID int) (models.Cart, error) {
+	cart, err := getCart(database.YourDailyDB, userID, false)
+	if err != nil {
+		return cart, err
+	}
+	offer, err := GetActiveOffer()
+	if err != nil {
+		return cart, err
+	}
+	for i := range cart.Items {
+		cart.Items[i].Discount = null.IntFrom(offer.Discount)
+		pricing.Item(&cart.Items[i].ItemInfo)
+		if cart.Items[i].Bucket.Valid {
+			imageLink, err := firebase.GetURL(&models.Image{Bucket: cart.Items[i].Bucket.String, Path: cart.Items[i].Path.String})
+			if err != nil {
+				logrus.Errorf("GetCart: failed to fetch image url item id: %d error: %v", cart.Items[i].Id, err)
+				continue
+			}
+			cart.Items[i].ItemImageLinks = append(cart.Items[i].ItemImageLinks, imageLink)
+		}
+	}
+	return cart, nil
+}
+
+//getCart returns the cart with its lines, locking it against concurrent changes when lock is set
+func getCart(db sqlx.Queryer, userID int, lock bool) (models.Cart, error) {
+	cart := models.Cart{Items: make([]models.CartLine, 0)}
+	query := `SELECT address_id, coupon_code, updated_at FROM carts WHERE user_id = $1`
+	if lock {
+		query += ` FOR UPDATE`
+	}
+	err := sqlx.Get(db, &cart, query, userID)
+	if err == sql.ErrNoRows {
+		return cart, nil
+	}
+	if err != nil {
+		return cart, err
+	}
+	err = sqlx.Select(db, &cart.Items, cartLineSelect, userID)
+	return cart, err
+}
+
+//touchCart creates the cart of the user if there is none and marks it updated
+func touchCart(tx *sqlx.Tx, userID int) error {
+	query := `INSERT INTO carts (user_id) VALUES ($1)
+			ON CONFLICT (user_id) DO UPDATE SET updated_at = now()`
+	_, err := tx.Exec(query, userID)
+	return err
+}
+
+//SetCartItem adds the item, or its variant, to the cart or changes its quantity, ErrItemNotFound or ErrItemUnavailable
+//when it can't be ordered
+func SetCartItem(userID, itemID int, variantID null.Int, quantity int) error {
+	return database.Tx(func(tx *sqlx.Tx) error {
+		var available bool
+		query := `SELECT ` + lineAvailable + `
+				FROM items
+						 ` + itemVariantJoin("$2") + `
+				WHERE items.id = $1
+				  AND items.archived_at IS NULL
+				  AND ` + lineVariantFound("$2::int")
+		err := tx.Get(&available, query, itemID, variantID)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: %d", ErrItemNotFound, itemID)
+		}
+		if err != nil {
+			return err
+		}
+		if !available {
+			return fmt.Errorf("%w: %d", ErrItemUnavailable, itemID)
+		}
+
+		if err := touchCart(tx, userID); err != nil {
+			return err
+		}
+		query = `INSERT INTO cart_items (user_id, item_id, variant_id, quantity) VALUES ($1, $2, $3, $4)
+				ON CONFLICT (user_id, item_id, COALESCE(variant_id, 0)) DO UPDATE SET quantity   = excluded.quantity,
+																			  updated_at = now()`
+		_, err = tx.Exec(query, userID, itemID, variantID, quantity)
+		return err
+	})
+}
+
+//RemoveCartItem removes the item, or its variant, from the cart, sql.ErrNoRows when it is not in the cart
+func RemoveCartItem(userID, itemID int, variantID null.Int) error {
+	return database.Tx(func(tx *sqlx.Tx) error {
+		query := `DELETE FROM cart_items WHERE user_id = $1 AND item_id = $2 AND variant_id IS NOT DISTINCT FROM $3`
+		if err := execAffectingOne(tx, query, userID, itemID, variantID); err != nil {
+			return err
+		}
+		return touchCart(tx, userID)
+	})
+}
+
+//ClearCart empties the cart and detaches its address and coupon
+func ClearCart(userID int) error {
+	_, err := database.YourDailyDB.Exec(`DELETE FROM carts WHERE user_id = $1`, userID)
+	return err
+}
+
+//UpdateCartDetails attaches the address and the coupon to the cart
+func UpdateCartDetails(userID int, details models.CartDetails) error {
+	query := `INSERT INTO carts (user_id, address_id, coupon_code) VALUES ($1, $2, $3)
+			ON CONFLICT (user_id) DO UPDATE SET address_id  = excluded.address_id,
+												coupon_code = excluded.coupon_code,
+												updated_at  = now()`
+	_, err := database.YourDailyDB.Exec(query, userID, details.AddressID, details.CouponCode)
+	return err
+}
+
+//lockCartItems locks the cart and returns its items, ErrCartEmpty when it has none
+//and ErrItemUnavailable when one of them can't be ordered anymore
+func lockCartItems(tx *sqlx.Tx, userID int) (models.Cart, []models.ItemInfo, error) {
+	cart, err := getCart(tx, userID, true)
+	if err != nil {
+		return cart, nil, err
+	}
+	if len(cart.Items) == 0 {
+		return cart, nil, ErrCartEmpty
+	}
+	items := make([]models.ItemInfo, 0, len(cart.Items))
+	for _, line := range cart.Items {
+		if !line.Available {
+			return cart, nil, fmt.Errorf("%w: %s", ErrItemUnavailable, line.Name)
+		}
+		items = append(items, models.ItemInfo{Id: line.Id, VariantID: line.VariantID, Quantity: line.Quantity})
+	}
+	return cart, items, nil
+}
+
+//CheckoutCart places the order with the items and the coupon of the cart and empties the cart, in one transaction
+func CheckoutCart(data models.Order) (int, error) {
+	var orderID int
+	err := database.Tx(func(tx *sqlx.Tx) error {
+		cart, items, err := lockCartItems(tx, data.UserID)
+		if err != nil {
+			return err
+		}
+		data.Items, data.CouponCode = items, cart.CouponCode.String
+		orderID, err = insertIntoOrders(tx, data)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`DELETE FROM carts WHERE user_id = $1`, data.UserID)
+		return err
+	})
+	return orderID, err
+}
+
+//ScheduleCart creates the scheduled order with the items of the cart and empties the cart, in one transaction
+func ScheduleCart(newOrder models.ScheduledOrder) (int, error) {
+	var scheduledOrderID int
+	err := database.Tx(func(tx *sqlx.Tx) error {
+		var err error
+		_, newOrder.Items, err = lockCartItems(tx, newOrder.UserID)
+		if err != nil {
+			return err
+		}
+		scheduledOrderID, err = insertScheduledOrder(tx, newOrder)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`DELETE FROM carts WHERE user_id = $1`, newOrder.UserID)
+		return err
+	})
+	return scheduledOrderID, err
+}
@@ -0,0 +1,177 @@
+package dbHelpers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/RemoteState/yourdaily-server/database"
+	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/volatiletech/null"
+	"strings"
+)
+
+//errCatalogueRolledBack rolls back the import of a catalogue with errors or a dry run
+var errCatalogueRolledBack = errors.New("catalogue import rolled back")
+
+//GetCatalogue returns all the items with their category name and their first image, for an export
+func GetCatalogue() ([]models.CatalogueRow, error) {
+	query := `WITH RECURSIVE paths AS (SELECT id, category::TEXT AS path
+								   FROM categories
+								   WHERE parent_id IS NULL
+								   UNION ALL
+								   SELECT c.id, paths.path || $1 || c.category
+								   FROM categories c
+											JOIN paths ON paths.id = c.parent_id)
+			SELECT items.id,
+				   items.name,
+				   paths.path AS category,
+				   items.price,
+				   items.strikethrough_price,
+				   items.in_stock,
+				   COALESCE(items.base_quantity, '') AS base_quantity,
+				   img.bucket,
+				   img.path
+			FROM items
+					 JOIN paths ON paths.id = items.category
+					 LEFT JOIN LATERAL (SELECT i.bucket, i.path
+										FROM item_images ii
+												 JOIN images i ON i.id = ii.image_id
+										WHERE ii.item_id = items.id
+										ORDER BY ii.id
+										LIMIT 1) img ON TRUE
+			WHERE items.archived_at IS NULL
+			ORDER BY paths.path, items.name, items.id`
+	rows := make([]models.CatalogueRow, 0)
+	err := database.YourDailyDB.Select(&rows, query, models.CategoryPathSeparator)
+	return rows, err
+}
+
+//GetImageID returns the id of the image stored at the path of the bucket, sql.ErrNoRows when there is none
+func GetImageID(bucket, path string) (int, error) {
+	var imageID int
+	err := database.YourDailyDB.Get(&imageID, `SELECT id FROM images WHERE bucket = $1 AND path = $2 ORDER BY id LIMIT 1`, bucket, path)
+	return imageID, err
+}
+
+//ImportCatalogue creates the categories missing and creates or updates the items of the rows, linking their image, in
+//one transaction, nothing is applied when a row can't be applied or on a dry run
+func ImportCatalogue(rows []models.CatalogueRow, dryRun bool) (models.CatalogueImport, error) {
+	result := models.CatalogueImport{
+		DryRun:            dryRun,
+		CreatedCategories: make([]string, 0),
+		Errors:            make([]models.CatalogueRowError, 0),
+	}
+	err := database.Tx(func(tx *sqlx.Tx) error {
+		categories, err := importCategories(tx, rows, &result)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			itemID, err := importItem(tx, row, categories[strings.ToLower(row.Category)])
+			if err == sql.ErrNoRows {
+				result.Errors = append(result.Errors, models.CatalogueRowError{
+					Row:     row.Row,
+					Column:  "id",
+					Message: fmt.Sprintf("item %d not found", row.ID.Int),
+				})
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("row %d: %w", row.Row, err)
+			}
+			if err := recordItemPrice(tx, itemID, null.Int{}); err != nil {
+				return fmt.Errorf("row %d: %w", row.Row, err)
+			}
+			if row.ID.Valid {
+				result.Updated++
+			} else {
+				result.Created++
+			}
+			if row.ImageID.Valid {
+				if err := linkItemWithImage(tx, itemID, row.ImageID.Int); err != nil {
+					return fmt.Errorf("row %d: %w", row.Row, err)
+				}
+			}
+		}
+		if len(result.Errors) > 0 || dryRun {
+			return errCatalogueRolledBack
+		}
+		return nil
+	})
+	if err == errCatalogueRolledBack {
+		err = nil
+	}
+	return result, err
+}
+
+//importCategories resolves the category path of the rows against the tree, level by level from the top, and creates
+//the levels missing at the end of their siblings, the categories are returned by lowercase path
+func importCategories(tx *sqlx.Tx, rows []models.CatalogueRow, result *models.CatalogueImport) (map[string]int, error) {
+	existing := make([]models.ItemCategory, 0)
+	if err := tx.Select(&existing, `SELECT id, category, parent_id FROM categories WHERE archived_at IS NULL ORDER BY id`); err != nil {
+		return nil, err
+	}
+	type level struct {
+		parentID int
+		name     string
+	}
+	tree := make(map[level]int, len(existing))
+	for _, category := range existing {
+		key := level{parentID: int(category.ParentID.Int), name: strings.ToLower(category.Category)}
+		if _, ok := tree[key]; !ok {
+			tree[key] = category.ID
+		}
+	}
+	categories := make(map[string]int)
+	for _, row := range rows {
+		path := strings.ToLower(row.Category)
+		if _, ok := categories[path]; ok {
+			continue
+		}
+		names := strings.Split(row.Category, models.CategoryPathSeparator)
+		parentID := null.Int{}
+		for i, name := range names {
+			key := level{parentID: parentID.Int, name: strings.ToLower(name)}
+			categoryID, ok := tree[key]
+			if !ok {
+				query := `INSERT INTO categories(category, parent_id, position)
+						VALUES ($1, $2, (SELECT COALESCE(max(position), 0) + 1
+										 FROM categories
+										 WHERE parent_id IS NOT DISTINCT FROM $2
+										   AND archived_at IS NULL))
+						RETURNING id`
+				if err := tx.Get(&categoryID, query, name, parentID); err != nil {
+					return nil, err
+				}
+				tree[key] = categoryID
+				result.CreatedCategories = append(result.CreatedCategories, strings.Join(names[:i+1], models.CategoryPathSeparator))
+			}
+			parentID = null.IntFrom(categoryID)
+		}
+		categories[path] = parentID.Int
+	}
+	return categories, nil
+}
+
+//importItem updates the item of the row, or creates it when the row has no id, and returns its id
+func importItem(tx *sqlx.Tx, row models.CatalogueRow, categoryID int) (int, error) {
+	if !row.ID.Valid {
+		query := `INSERT INTO items(name, price, in_stock, category, base_quantity, strikethrough_price)
+				VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+		var itemID int
+		err := tx.Get(&itemID, query, row.Name, row.Price, row.InStock, categoryID, row.BaseQuantity, row.StrikeThroughPrice)
+		return itemID, err
+	}
+	query := `UPDATE items
+			SET name                = $2,
+				price               = $3,
+				in_stock            = $4,
+				category            = $5,
+				base_quantity       = $6,
+				strikethrough_price = $7,
+				updated_at          = now()
+			WHERE id = $1
+			  AND archived_at IS NULL`
+	return row.ID.Int, execAffectingOne(tx, query, row.ID.Int, row.Name, row.Price, row.InStock, categoryID, row.BaseQuantity, row.StrikeThroughPrice)
+}
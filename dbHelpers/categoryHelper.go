@@ -2,71 +2,177 @@ package dbHelpers
 
 import (
 	"database/sql"
+	"errors"
 	"github.com/RemoteState/yourdaily-server/database"
 	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/volatiletech/null"
 	"time"
 )
 
-// InsertCategory creates a new category entry in table
-func InsertCategory(category string) (int, error) {
-	SQL := `INSERT INTO categories(category) VALUES ($1) RETURNING id`
+//ErrInvalidCategoryParent is returned when the parent of a category is not a category or is inside the category
+var ErrInvalidCategoryParent = errors.New("invalid parent category")
+
+const categorySelect = `SELECT c.id,
+				   c.category,
+				   c.tax_slab_id,
+				   c.created_at,
+				   c.parent_id,
+				   c.position,
+				   c.image_id,
+				   c.visible_from,
+				   c.visible_until,
+				   i.bucket,
+				   i.path
+			FROM categories c
+					 LEFT JOIN images i ON i.id = c.image_id
+			WHERE c.archived_at IS NULL`
+
+//categoryVisible is true when the category c is shown to users now
+const categoryVisible = `(c.visible_from IS NULL OR c.visible_from <= now()) AND (c.visible_until IS NULL OR c.visible_until > now())`
+
+//itemCategoryVisible is true when neither the category of items nor any of its ancestors is hidden from users now
+const itemCategoryVisible = `COALESCE(items.category NOT IN (WITH RECURSIVE hidden AS (SELECT c.id
+																	   FROM categories c
+																	   WHERE NOT (` + categoryVisible + `)
+																	   UNION
+																	   SELECT child.id
+																	   FROM categories child
+																				JOIN hidden ON child.parent_id = hidden.id)
+										  SELECT id
+										  FROM hidden), TRUE)`
+
+// InsertCategory creates a new category entry in table, at the end of its siblings when it has no position
+func InsertCategory(category models.ItemCategory) (int, error) {
 	var categoryID int
-	err := database.YourDailyDB.Get(&categoryID, SQL, category)
+	err := database.Tx(func(tx *sqlx.Tx) error {
+		if err := checkCategoryParent(tx, 0, category.ParentID); err != nil {
+			return err
+		}
+		SQL := `INSERT INTO categories(category, parent_id, position, image_id, visible_from, visible_until)
+				VALUES ($1, $2, CASE
+									WHEN $3 > 0 THEN $3
+									ELSE (SELECT COALESCE(max(position), 0) + 1
+										  FROM categories
+										  WHERE parent_id IS NOT DISTINCT FROM $2
+											AND archived_at IS NULL) END, $4, $5, $6)
+				RETURNING id`
+		return tx.Get(&categoryID, SQL, category.Category, category.ParentID, category.Position, category.ImageID, category.VisibleFrom, category.VisibleUntil)
+	})
 	return categoryID, err
 }
 
-// GetCategories returns all categories
+// GetCategories returns all categories, children after their parent and siblings by position
 func GetCategories() ([]models.ItemCategory, error) {
-	SQL := `SELECT 
-				id,
-       			category,
-       			created_at
-			FROM categories 
-			WHERE  archived_at IS NULL`
-
 	categories := make([]models.ItemCategory, 0)
-
-	err := database.YourDailyDB.Select(&categories, SQL)
+	err := database.YourDailyDB.Select(&categories, categorySelect+` ORDER BY c.parent_id NULLS FIRST, c.position, c.id`)
 	if err != nil {
 		return nil, err
 	}
 	return categories, nil
 }
 
-// ModifyCategory modifies a given category in table
-func ModifyCategory(category string, categoryID int) error {
-	SQL := `UPDATE categories SET category = $1, updated_at = $2 WHERE id = $3`
-	_, err := database.YourDailyDB.Exec(SQL, category, time.Now(), categoryID)
-	return err
+// GetVisibleCategories returns the categories shown to users now, siblings by position
+func GetVisibleCategories() ([]models.ItemCategory, error) {
+	categories := make([]models.ItemCategory, 0)
+	err := database.YourDailyDB.Select(&categories, categorySelect+` AND `+categoryVisible+` ORDER BY c.parent_id NULLS FIRST, c.position, c.id`)
+	return categories, err
+}
+
+// ModifyCategory modifies a given category in table, it keeps its position when none is given,
+// sql.ErrNoRows when there is no such category
+func ModifyCategory(category models.ItemCategory) error {
+	return database.Tx(func(tx *sqlx.Tx) error {
+		if err := checkCategoryParent(tx, category.ID, category.ParentID); err != nil {
+			return err
+		}
+		SQL := `UPDATE categories
+				SET category      = $2,
+					parent_id     = $3,
+					position      = CASE WHEN $4 > 0 THEN $4 ELSE position END,
+					image_id      = $5,
+					visible_from  = $6,
+					visible_until = $7,
+					updated_at    = $8
+				WHERE id = $1
+				  AND archived_at IS NULL`
+		return execAffectingOne(tx, SQL, category.ID, category.Category, category.ParentID, category.Position, category.ImageID, category.VisibleFrom, category.VisibleUntil, time.Now())
+	})
+}
+
+// OrderCategories sets the display order of sibling categories to the order of the ids
+func OrderCategories(categoryIDs []int) error {
+	return database.Tx(func(tx *sqlx.Tx) error {
+		ids := make(pq.Int64Array, 0, len(categoryIDs))
+		for _, categoryID := range categoryIDs {
+			ids = append(ids, int64(categoryID))
+		}
+		var parents, count int
+		SQL := `SELECT count(DISTINCT COALESCE(parent_id, 0)), count(*) FROM categories WHERE id = ANY ($1) AND archived_at IS NULL`
+		if err := tx.QueryRow(SQL, ids).Scan(&parents, &count); err != nil {
+			return err
+		}
+		if count != len(categoryIDs) {
+			return sql.ErrNoRows
+		}
+		if parents != 1 {
+			return ErrInvalidCategoryParent
+		}
+		for i, categoryID := range categoryIDs {
+			if _, err := tx.Exec(`UPDATE categories SET position = $2, updated_at = $3 WHERE id = $1`, categoryID, i+1, time.Now()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
 // GetCategoryById gets the category details for a given id
 func GetCategoryById(categoryID int) (*models.ItemCategory, error) {
-	SQL := `SELECT 
-				id,
-       			category,
-       			created_at
-			FROM categories 
-			WHERE  archived_at IS NULL
-			AND id = $1`
-
 	var category models.ItemCategory
 
-	err := database.YourDailyDB.Get(&category, SQL, categoryID)
+	err := database.YourDailyDB.Get(&category, categorySelect+` AND c.id = $1`, categoryID)
 	if err != nil {
 		return nil, err
 	}
 	return &category, nil
 }
 
+//checkCategoryParent checks the parent is a category and not the category itself or one of its children
+func checkCategoryParent(tx *sqlx.Tx, categoryID int, parentID null.Int) error {
+	if !parentID.Valid {
+		return nil
+	}
+	SQL := `WITH RECURSIVE ancestors AS (SELECT id, parent_id
+									  FROM categories
+									  WHERE id = $1
+										AND archived_at IS NULL
+									  UNION
+									  SELECT c.id, c.parent_id
+									  FROM categories c
+											   JOIN ancestors a ON a.parent_id = c.id)
+			SELECT count(*) FILTER (WHERE id = $1), count(*) FILTER (WHERE id = $2)
+			FROM ancestors`
+	var found, cycle int
+	if err := tx.QueryRow(SQL, parentID, categoryID).Scan(&found, &cycle); err != nil {
+		return err
+	}
+	if found == 0 || cycle > 0 {
+		return ErrInvalidCategoryParent
+	}
+	return nil
+}
+
 // ArchiveCategory archives a given category
-// need to make sure that no item is assigned to this category
+// need to make sure that no item is assigned to this category, a category with sub categories can't be archived
 func ArchiveCategory(categoryID int) error {
 	SQL := `UPDATE categories
 			SET archived_at = $1
 			WHERE archived_at IS NULL
 			AND id = $2
-			AND NOT EXISTS (select 1 from items WHERE items.category = categories.id and items.archived_at is not null);`
+			AND NOT EXISTS (select 1 from items WHERE items.category = categories.id and items.archived_at is not null)
+			AND NOT EXISTS (SELECT 1 FROM categories child WHERE child.parent_id = categories.id AND child.archived_at IS NULL);`
 	result, err := database.YourDailyDB.Exec(SQL, time.Now(), categoryID)
 	if err != nil {
 		return err
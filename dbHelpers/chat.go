@@ -5,25 +5,168 @@ import (
 	"github.com/RemoteState/yourdaily-server/firebase"
 	"github.com/RemoteState/yourdaily-server/models"
 	"github.com/volatiletech/null"
+	"time"
 )
 
-func InsertMessage(chat models.Chat) error {
-	query := `Insert into chat (order_id, sender, message, created_at)
-				values ($1,$2,$3,now())`
+const chatSelect = `select c.id, c.order_id, c.sender, c.message, c.attachment_type, c.image_id, c.lat, c.long,
+				c.item_id, it.name as item_name, img.bucket, img.path, c.created_at, c.delivered_at, c.read_at
+			from chat c
+				left join images img on img.id = c.image_id
+				left join items it on it.id = c.item_id`
 
-	_, err := database.YourDailyDB.Exec(query, chat.OrderID, chat.Sender, chat.Message)
-	return err
+//InsertMessage stores the chat message with its attachment and returns it with its id
+func InsertMessage(chat models.Chat) (models.Chat, error) {
+	query := `Insert into chat (order_id, sender, message, attachment_type, image_id, lat, long, item_id, created_at)
+				values ($1,$2,$3,$4,$5,$6,$7,$8,now())
+				returning id`
+
+	var id int
+	err := database.YourDailyDB.Get(&id, query, chat.OrderID, chat.Sender, chat.Message, chat.AttachmentType,
+		chat.ImageID, chat.Lat, chat.Long, chat.ItemID)
+	if err != nil {
+		return models.Chat{}, err
+	}
+	return GetMessageByID(id)
+}
+
+//GetMessageByID returns a single chat message
+func GetMessageByID(messageID int) (models.Chat, error) {
+	query := chatSelect + ` where c.id = $1`
+	var chat models.Chat
+	if err := database.YourDailyDB.Get(&chat, query, messageID); err != nil {
+		return chat, err
+	}
+	chats := []models.Chat{chat}
+	err := fillChatImageURLs(chats)
+	return chats[0], err
 }
 
 func GetAllMessage(orderID int) ([]models.Chat, error) {
-	query := `select order_id, sender, message, created_at
-			from chat
-			where order_id = $1 order by created_at`
+	query := chatSelect + ` where c.order_id = $1 order by c.id`
 	chats := make([]models.Chat, 0)
-	err := database.YourDailyDB.Select(&chats, query, orderID)
+	if err := database.YourDailyDB.Select(&chats, query, orderID); err != nil {
+		return nil, err
+	}
+	err := fillChatImageURLs(chats)
 	return chats, err
 }
 
+//GetMessagesBefore returns a page of messages older than the given message id, oldest first
+func GetMessagesBefore(orderID, beforeID, limit int) ([]models.Chat, error) {
+	query := `select page.*
+			from (` + chatSelect + `
+				  where c.order_id = $1 and ($2 = 0 or c.id < $2)
+				  order by c.id desc
+				  limit $3) page
+			order by page.id`
+	chats := make([]models.Chat, 0)
+	if err := database.YourDailyDB.Select(&chats, query, orderID, beforeID, limit); err != nil {
+		return nil, err
+	}
+	err := fillChatImageURLs(chats)
+	return chats, err
+}
+
+//GetMessagesAfter returns all the messages newer than the given message id, used to resume a chat after reconnecting
+func GetMessagesAfter(orderID, afterID int) ([]models.Chat, error) {
+	query := chatSelect + ` where c.order_id = $1 and c.id > $2 order by c.id`
+	chats := make([]models.Chat, 0)
+	if err := database.YourDailyDB.Select(&chats, query, orderID, afterID); err != nil {
+		return nil, err
+	}
+	err := fillChatImageURLs(chats)
+	return chats, err
+}
+
+//fillChatImageURLs signs the urls of the image attachments
+func fillChatImageURLs(chats []models.Chat) error {
+	for i := range chats {
+		if !chats[i].Bucket.Valid || !chats[i].Path.Valid {
+			continue
+		}
+		url, err := firebase.GetURL(&models.Image{
+			Bucket: chats[i].Bucket.String,
+			Path:   chats[i].Path.String,
+		})
+		if err != nil {
+			return err
+		}
+		chats[i].ImageURL = url
+	}
+	return nil
+}
+
+//StoreChatImageInfo stores a chat image uploaded by the user for the order's chat
+func StoreChatImageInfo(bucket, path string, orderID, userID int) (int, error) {
+	query := `insert into images(type, bucket, path, created_at, order_id, uploaded_by) values ($1, $2, $3, $4, $5, $6) returning id`
+	var imageID int
+	err := database.YourDailyDB.Get(&imageID, query, models.ChatImage, bucket, path, time.Now(), orderID, userID)
+	return imageID, err
+}
+
+//IsChatImageOf checks if the image exists and was uploaded by the user for the order's chat
+func IsChatImageOf(imageID, orderID, userID int) (bool, error) {
+	query := `select exists(select 1
+						  from images
+						  where id = $1
+							and type = $2
+							and order_id = $3
+							and uploaded_by = $4
+							and archived_at is null)`
+	var exists bool
+	err := database.YourDailyDB.Get(&exists, query, imageID, models.ChatImage, orderID, userID)
+	return exists, err
+}
+
+//MarkMessageDelivered marks a single message as delivered
+func MarkMessageDelivered(messageID int) error {
+	query := `update chat set delivered_at = now() where id = $1 and delivered_at is null`
+	_, err := database.YourDailyDB.Exec(query, messageID)
+	return err
+}
+
+//MarkMessagesDelivered marks the messages received by the given user up to messageID as delivered
+func MarkMessagesDelivered(orderID, receiverID, messageID int) ([]int, error) {
+	query := `update chat
+			set delivered_at = now()
+			where order_id = $1 and sender <> $2 and id <= $3 and delivered_at is null
+			returning id`
+	ids := make([]int, 0)
+	err := database.YourDailyDB.Select(&ids, query, orderID, receiverID, messageID)
+	return ids, err
+}
+
+//MarkMessagesRead marks the messages received by the given user up to messageID as read
+func MarkMessagesRead(orderID, readerID, messageID int) ([]int, error) {
+	query := `update chat
+			set read_at = now(),
+				delivered_at = coalesce(delivered_at, now())
+			where order_id = $1 and sender <> $2 and id <= $3 and read_at is null
+			returning id`
+	ids := make([]int, 0)
+	err := database.YourDailyDB.Select(&ids, query, orderID, readerID, messageID)
+	return ids, err
+}
+
+//GetChatOrder returns the participants and status of the order a chat belongs to
+func GetChatOrder(orderID int) (models.ChatOrder, error) {
+	query := `select o.id,
+				   o.user_id,
+				   o.staff_id,
+				   o.sm_id,
+				   o.status,
+				   exists(select 1
+						  from disputed_orders dis
+						  where dis.order_id = o.id
+							and dis.resolved_at is null) as disputed
+			from orders o
+			where o.id = $1`
+	order := models.ChatOrder{}
+	err := database.YourDailyDB.Get(&order, query, orderID)
+	return order, err
+}
+
+//SendNotification notifies the user and the staff of the order about the message, except the one who sent it
 func SendNotification(chat models.Chat) error {
 	query := `
 		SELECT user_id, staff_id
@@ -35,16 +178,11 @@ func SendNotification(chat models.Chat) error {
 	if err != nil {
 		return err
 	}
-	var isStaff bool
-	if staffId.Valid {
-		isStaff = staffId.Int == chat.Sender
+	if userId.Valid && userId.Int != chat.Sender {
+		go firebase.NewMessageNotification(userId.Int, chat.OrderID, chat.Preview())
 	}
-	if isStaff {
-		go firebase.NewMessageNotification(userId.Int, chat.OrderID, chat.Message)
-	} else {
-		if staffId.Valid {
-			go firebase.NewMessageNotification(staffId.Int, chat.OrderID, chat.Message)
-		}
+	if staffId.Valid && staffId.Int != chat.Sender {
+		go firebase.NewMessageNotification(staffId.Int, chat.OrderID, chat.Preview())
 	}
 	return nil
 }
@@ -0,0 +1,148 @@
+package dbHelpers
+
+import (
+	"database/sql"
+	"github.com/RemoteState/yourdaily-server/database"
+	"github.com/RemoteState/yourdaily-server/models"
+)
+
+//GetChatSettings returns the chat moderation settings of the store manager, defaults when never configured
+func GetChatSettings(smID int) (models.ChatSettings, error) {
+	query := `select mask_phone, mask_email, blocked_words
+			from chat_settings
+			where sm_id = $1`
+	settings := models.ChatSettings{BlockedWords: make([]string, 0)}
+	err := database.YourDailyDB.Get(&settings, query, smID)
+	if err == sql.ErrNoRows {
+		return settings, nil
+	}
+	return settings, err
+}
+
+//UpsertChatSettings stores the chat moderation settings of the store manager
+func UpsertChatSettings(smID int, settings models.ChatSettings) error {
+	query := `insert into chat_settings (sm_id, mask_phone, mask_email, blocked_words)
+			values ($1, $2, $3, $4)
+			on conflict (sm_id) do update
+				set mask_phone    = excluded.mask_phone,
+					mask_email    = excluded.mask_email,
+					blocked_words = excluded.blocked_words,
+					updated_at    = now()`
+	_, err := database.YourDailyDB.Exec(query, smID, settings.MaskPhone, settings.MaskEmail, settings.BlockedWords)
+	return err
+}
+
+//IsChatMuted checks if the user has been muted in the order chat
+func IsChatMuted(orderID, userID int) (bool, error) {
+	query := `select exists(select 1
+						  from chat_mutes
+						  where order_id = $1
+							and user_id = $2
+							and unmuted_at is null)`
+	var muted bool
+	err := database.YourDailyDB.Get(&muted, query, orderID, userID)
+	return muted, err
+}
+
+//MuteChatUser mutes the user in the order chat, muting an already muted user is a no-op
+func MuteChatUser(orderID, userID, smID int) error {
+	query := `insert into chat_mutes (order_id, user_id, muted_by)
+			values ($1, $2, $3)
+			on conflict (order_id, user_id) where unmuted_at is null do nothing`
+	_, err := database.YourDailyDB.Exec(query, orderID, userID, smID)
+	return err
+}
+
+//UnmuteChatUser lifts the active mute of the user in the order chat
+func UnmuteChatUser(orderID, userID int) error {
+	query := `update chat_mutes
+			set unmuted_at = now()
+			where order_id = $1
+			  and user_id = $2
+			  and unmuted_at is null`
+	_, err := database.YourDailyDB.Exec(query, orderID, userID)
+	return err
+}
+
+//InsertChatReport reports a chat message, returns sql.ErrNoRows when the user already reported it
+func InsertChatReport(chatID, reporterID int, reason string) (int, error) {
+	query := `insert into chat_reports (chat_id, order_id, reported_by, reason)
+			select id, order_id, $2, $3
+			from chat
+			where id = $1
+			on conflict (chat_id, reported_by) do nothing
+			returning id`
+	var reportID int
+	err := database.YourDailyDB.Get(&reportID, query, chatID, reporterID, reason)
+	return reportID, err
+}
+
+//GetChatReports returns the open or resolved reports on the chats of the store manager's orders, newest first
+func GetChatReports(smID int, resolved bool, offset, limit int) ([]models.ChatReport, error) {
+	query := `select cr.id,
+				   cr.chat_id,
+				   cr.order_id,
+				   cr.reported_by,
+				   coalesce(reporter.name, '') as reporter_name,
+				   c.sender,
+				   coalesce(sender.name, '')   as sender_name,
+				   c.message,
+				   cr.reason,
+				   exists(select 1
+						  from chat_mutes cm
+						  where cm.order_id = cr.order_id
+							and cm.user_id = c.sender
+							and cm.unmuted_at is null) as sender_muted,
+				   cr.created_at,
+				   cr.resolved_at,
+				   cr.resolution_note
+			from chat_reports cr
+					 join chat c on c.id = cr.chat_id
+					 join orders o on o.id = cr.order_id
+					 join users reporter on reporter.id = cr.reported_by
+					 join users sender on sender.id = c.sender
+			where o.sm_id = $1
+			  and (cr.resolved_at is not null) = $2
+			order by cr.id desc
+			offset $3 limit $4`
+	reports := make([]models.ChatReport, 0)
+	err := database.YourDailyDB.Select(&reports, query, smID, resolved, offset, limit)
+	return reports, err
+}
+
+//GetOpenChatReportCount returns the number of unresolved chat reports on the store manager's orders
+func GetOpenChatReportCount(smID int) (int, error) {
+	query := `select count(*)
+			from chat_reports cr
+					 join orders o on o.id = cr.order_id
+			where o.sm_id = $1
+			  and cr.resolved_at is null`
+	var count int
+	err := database.YourDailyDB.Get(&count, query, smID)
+	return count, err
+}
+
+//ResolveChatReport marks a report on one of the store manager's orders as resolved
+func ResolveChatReport(reportID, smID int, note string) error {
+	query := `update chat_reports cr
+			set resolved_at     = now(),
+				resolved_by     = $2,
+				resolution_note = $3
+			from orders o
+			where o.id = cr.order_id
+			  and o.sm_id = $2
+			  and cr.id = $1
+			  and cr.resolved_at is null`
+	result, err := database.YourDailyDB.Exec(query, reportID, smID, note)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package dbHelpers
+
+import (
+	"github.com/RemoteState/yourdaily-server/database"
+	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/sirupsen/logrus"
+	"github.com/volatiletech/null"
+)
+
+//GetContactParties returns the user and staff of the order along with their phones
+func GetContactParties(orderID int) (models.ContactParties, error) {
+	query := `select o.id,
+				   o.status,
+				   o.user_id,
+				   u.phone as user_phone,
+				   o.staff_id,
+				   s.phone as staff_phone
+			from orders o
+					 join users u on u.id = o.user_id
+					 left join users s on s.id = o.staff_id
+			where o.id = $1`
+	parties := models.ContactParties{}
+	err := database.YourDailyDB.Get(&parties, query, orderID)
+	return parties, err
+}
+
+//GetActiveContactProxy returns the unreleased proxy issued to the caller for the order
+func GetActiveContactProxy(orderID, callerID int) (models.ContactProxy, error) {
+	query := `select id, order_id, caller_id, callee_id, provider, kind, value, provider_ref, expires_at
+			from contact_proxies
+			where order_id = $1
+			  and caller_id = $2
+			  and released_at is null`
+	proxy := models.ContactProxy{}
+	err := database.YourDailyDB.Get(&proxy, query, orderID, callerID)
+	return proxy, err
+}
+
+//InsertContactProxy stores a proxy issued by the telephony provider
+func InsertContactProxy(proxy models.ContactProxy) (int, error) {
+	query := `insert into contact_proxies (order_id, caller_id, callee_id, provider, kind, value, provider_ref, expires_at)
+			values ($1, $2, $3, $4, $5, $6, $7, $8)
+			returning id`
+	var id int
+	err := database.YourDailyDB.Get(&id, query, proxy.OrderID, proxy.CallerID, proxy.CalleeID, proxy.Provider,
+		proxy.Kind, proxy.Value, proxy.ProviderRef, proxy.ExpiresAt)
+	return id, err
+}
+
+//GetReleasableContactProxies returns the unreleased proxies that have expired or belong to a completed order,
+//pass an orderID to only look at a single order
+func GetReleasableContactProxies(orderID *int) ([]models.ContactProxy, error) {
+	query := `select cp.id, cp.order_id, cp.caller_id, cp.callee_id, cp.provider, cp.kind, cp.value, cp.provider_ref, cp.expires_at
+			from contact_proxies cp
+					 join orders o on o.id = cp.order_id
+			where cp.released_at is null
+			  and ($1::int is null or cp.order_id = $1)
+			  and (cp.expires_at <= now() or o.status in ($2, $3, $4))`
+	proxies := make([]models.ContactProxy, 0)
+	err := database.YourDailyDB.Select(&proxies, query, orderID, models.Delivered, models.Cancelled, models.Declined)
+	return proxies, err
+}
+
+//MarkContactProxyReleased marks the proxy as released so it is never served again
+func MarkContactProxyReleased(proxyID int) error {
+	query := `update contact_proxies set released_at = now() where id = $1 and released_at is null`
+	_, err := database.YourDailyDB.Exec(query, proxyID)
+	return err
+}
+
+//GetStaffProfile returns the public profile of the staff
+func GetStaffProfile(staffID int) (models.StaffProfile, error) {
+	query := `select id, name, profile_image
+			from users
+			where archived_at is null
+			  and id = $1`
+	staff := struct {
+		models.StaffProfile
+		ProfileImageID null.Int `db:"profile_image"`
+	}{}
+	if err := database.YourDailyDB.Get(&staff, query, staffID); err != nil {
+		return staff.StaffProfile, err
+	}
+	if staff.ProfileImageID.Valid {
+		url, err := GetImageUrl(staff.ProfileImageID.Int)
+		if err != nil {
+			logrus.Errorf("GetStaffProfile: failed to fetch image link err :%v", err)
+		}
+		staff.ProfileImageLink = url
+	}
+	return staff.StaffProfile, nil
+}
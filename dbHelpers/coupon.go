@@ -0,0 +1,213 @@
+package dbHelpers
+
+import (
+	"database/sql"
+	"github.com/RemoteState/yourdaily-server/database"
+	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/RemoteState/yourdaily-server/pricing"
+	"github.com/RemoteState/yourdaily-server/promotions"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/volatiletech/null"
+	"time"
+)
+
+const couponSelect = `SELECT c.id,
+				   c.code,
+				   c.description,
+				   c.discount_type,
+				   c.value,
+				   c.max_discount,
+				   c.scope,
+				   c.category_ids,
+				   c.item_ids,
+				   c.min_order_value,
+				   c.usage_limit,
+				   c.per_user_limit,
+				   c.stackable,
+				   c.starts_at,
+				   c.ends_at,
+				   c.created_at,
+				   (SELECT count(*)
+					FROM coupon_redemptions cr
+							 JOIN orders o ON o.id = cr.order_id
+					WHERE cr.coupon_id = c.id
+					  AND o.status NOT IN ('cancelled', 'declined')) AS used_count
+			FROM coupons c`
+
+//GetCoupons returns all the coupons which are not archived
+func GetCoupons() ([]models.Coupon, error) {
+	coupons := make([]models.Coupon, 0)
+	err := database.YourDailyDB.Select(&coupons, couponSelect+` WHERE c.archived_at IS NULL ORDER BY c.id DESC`)
+	return coupons, err
+}
+
+//GetCouponByID returns the coupon which is not archived
+func GetCouponByID(couponID int) (models.Coupon, error) {
+	coupon := models.Coupon{}
+	err := database.YourDailyDB.Get(&coupon, couponSelect+` WHERE c.archived_at IS NULL AND c.id = $1`, couponID)
+	return coupon, err
+}
+
+//GetCouponByCode returns the coupon with the code, promotions.ErrInvalidCoupon when there is none
+func GetCouponByCode(code string) (models.Coupon, error) {
+	coupon := models.Coupon{}
+	err := database.YourDailyDB.Get(&coupon, couponSelect+` WHERE c.archived_at IS NULL AND upper(c.code) = upper($1)`, code)
+	if err == sql.ErrNoRows {
+		err = promotions.ErrInvalidCoupon
+	}
+	return coupon, err
+}
+
+//InsertCoupon creates a coupon
+func InsertCoupon(coupon models.Coupon, smID int) (int, error) {
+	query := `INSERT INTO coupons (code, description, discount_type, value, max_discount, scope, category_ids, item_ids,
+							 min_order_value, usage_limit, per_user_limit, stackable, starts_at, ends_at, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+			RETURNING id`
+	var id int
+	err := database.YourDailyDB.Get(&id, query, coupon.Code, coupon.Description, coupon.DiscountType, coupon.Value,
+		coupon.MaxDiscount, coupon.Scope, coupon.CategoryIDs, coupon.ItemIDs, coupon.MinOrderValue, coupon.UsageLimit,
+		coupon.PerUserLimit, coupon.Stackable, coupon.StartsAt, coupon.EndsAt, smID)
+	return id, err
+}
+
+//UpdateCoupon updates the coupon, orders which already used it keep their discount
+func UpdateCoupon(coupon models.Coupon) error {
+	query := `UPDATE coupons
+			SET code            = $2,
+				description     = $3,
+				discount_type   = $4,
+				value           = $5,
+				max_discount    = $6,
+				scope           = $7,
+				category_ids    = $8,
+				item_ids        = $9,
+				min_order_value = $10,
+				usage_limit     = $11,
+				per_user_limit  = $12,
+				stackable       = $13,
+				starts_at       = $14,
+				ends_at         = $15,
+				updated_at      = now()
+			WHERE id = $1
+			  AND archived_at IS NULL`
+	return execAffectingOne(database.YourDailyDB, query, coupon.ID, coupon.Code, coupon.Description, coupon.DiscountType,
+		coupon.Value, coupon.MaxDiscount, coupon.Scope, coupon.CategoryIDs, coupon.ItemIDs, coupon.MinOrderValue,
+		coupon.UsageLimit, coupon.PerUserLimit, coupon.Stackable, coupon.StartsAt, coupon.EndsAt)
+}
+
+//ArchiveCoupon archives the coupon, its code can then be reused
+func ArchiveCoupon(couponID int) error {
+	return execAffectingOne(database.YourDailyDB, `UPDATE coupons SET archived_at = now() WHERE id = $1 AND archived_at IS NULL`, couponID)
+}
+
+//GetCouponUsage returns how many live orders used the coupon, in total and by the user
+func GetCouponUsage(couponID, userID int) (models.CouponUsage, error) {
+	return getCouponUsage(database.YourDailyDB, couponID, userID)
+}
+
+func getCouponUsage(db sqlx.Queryer, couponID, userID int) (models.CouponUsage, error) {
+	query := `SELECT count(*)                                 AS total,
+				   count(*) FILTER ( WHERE cr.user_id = $2 ) AS by_user
+			FROM coupon_redemptions cr
+					 JOIN orders o ON o.id = cr.order_id
+			WHERE cr.coupon_id = $1
+			  AND o.status NOT IN ($3, $4)`
+	usage := models.CouponUsage{}
+	err := sqlx.Get(db, &usage, query, couponID, userID, models.Cancelled, models.Declined)
+	return usage, err
+}
+
+//GetCartCouponLines prices the cart from the catalogue the same way its order items would be priced
+func GetCartCouponLines(items []models.ItemInfo, discount int) ([]models.CouponLine, error) {
+	itemIDs := make(pq.Int64Array, 0, len(items))
+	variantIDs := make(pq.Int64Array, 0, len(items))
+	quantities := make(pq.Int64Array, 0, len(items))
+	for _, item := range items {
+		itemIDs = append(itemIDs, int64(item.Id))
+		variantIDs = append(variantIDs, int64(item.VariantID.Int))
+		quantities = append(quantities, int64(item.Quantity))
+	}
+	// a variant id 0 stands for a line without a variant
+	query := `SELECT items.id       AS item_id,
+				   items.category AS category_id,
+				   lines.quantity,
+				   ` + linePrice + ` AS price,
+				   ` + lineStrikeThroughPrice + ` AS strikethrough_price,
+				   COALESCE(ts.rate, 0) AS tax_rate,
+				   COALESCE(ts.inclusive, TRUE) AS tax_inclusive
+			FROM unnest($1::int[], $2::int[], $3::int[]) AS lines(item_id, variant_id, quantity)
+					 JOIN items ON items.id = lines.item_id
+					 JOIN categories c ON c.id = items.category
+					 ` + itemTaxSlabJoin + `
+					 ` + itemVariantJoin("NULLIF(lines.variant_id, 0)") + `
+			WHERE items.archived_at IS NULL
+			  AND ` + lineVariantFound("NULLIF(lines.variant_id, 0)")
+	catalogue := make([]struct {
+		models.ItemInfo
+		CategoryID int `db:"category_id"`
+	}, 0, len(items))
+	lines := make([]models.CouponLine, 0, len(items))
+	if err := database.YourDailyDB.Select(&catalogue, query, itemIDs, variantIDs, quantities); err != nil {
+		return lines, err
+	}
+	if len(catalogue) != len(items) {
+		return lines, ErrItemNotFound
+	}
+	for _, row := range catalogue {
+		item := row.ItemInfo
+		item.Discount = null.IntFrom(discount)
+		pricing.Item(&item)
+		lines = append(lines, models.CouponLine{
+			ItemID:     item.Id,
+			CategoryID: row.CategoryID,
+			Quantity:   item.Quantity,
+			Amount:     pricing.Total(item),
+		})
+	}
+	return lines, nil
+}
+
+//applyCoupon evaluates the coupon against the priced order items, records its redemption and discounts the order
+func applyCoupon(tx *sqlx.Tx, orderID, userID, couponID int) error {
+	// lock the coupon so concurrent orders can't go over its usage limit
+	coupon := models.Coupon{}
+	err := tx.Get(&coupon, couponSelect+` WHERE c.archived_at IS NULL AND c.id = $1 FOR UPDATE OF c`, couponID)
+	if err == sql.ErrNoRows {
+		return promotions.ErrInvalidCoupon
+	}
+	if err != nil {
+		return err
+	}
+	usage, err := getCouponUsage(tx, couponID, userID)
+	if err != nil {
+		return err
+	}
+	query := `SELECT COALESCE(oi.item_id, 0)            AS item_id,
+				   COALESCE(items.category, 0)          AS category_id,
+				   oi.quantity,
+				   oi.taxable_amount + oi.tax_amount AS amount
+			FROM order_items oi
+					 LEFT JOIN items ON items.id = oi.item_id
+			WHERE oi.order_id = $1
+			  AND oi.quantity > 0`
+	lines := make([]models.CouponLine, 0)
+	if err := tx.Select(&lines, query, orderID); err != nil {
+		return err
+	}
+
+	discount, err := promotions.Discount(coupon, lines, usage, time.Now())
+	if err != nil {
+		return err
+	}
+	query = `INSERT INTO coupon_redemptions (coupon_id, user_id, order_id, discount) VALUES ($1, $2, $3, $4)`
+	if _, err := tx.Exec(query, couponID, userID, orderID, discount); err != nil {
+		return err
+	}
+	query = `UPDATE orders SET coupon_id = $2, coupon_discount = $3 WHERE id = $1`
+	if _, err := tx.Exec(query, orderID, couponID, discount); err != nil {
+		return err
+	}
+	return updateOrderAmount(tx, orderID)
+}
@@ -0,0 +1,114 @@
+package dbHelpers
+
+import (
+	"database/sql"
+	"github.com/RemoteState/yourdaily-server/database"
+	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/RemoteState/yourdaily-server/pricing"
+	"github.com/RemoteState/yourdaily-server/utils"
+	"github.com/jmoiron/sqlx"
+	"github.com/volatiletech/null"
+)
+
+//orderDelivery is where an order is delivered and the store it is delivered from
+type orderDelivery struct {
+	Mode      models.OrderMode `db:"mode"`
+	SmID      null.Int         `db:"sm_id"`
+	Lat       float64          `db:"lat"`
+	Long      float64          `db:"long"`
+	StoreLat  null.Float64     `db:"store_lat"`
+	StoreLong null.Float64     `db:"store_long"`
+}
+
+//orderDeliverySelect selects the orderDelivery of the orders or scheduled orders aliased o
+const orderDeliverySelect = `SELECT o.mode, o.sm_id, a.lat, a.long, l.lat AS store_lat, l.long AS store_long`
+
+//orderDeliveryJoin joins the address of the order and the location of its store
+const orderDeliveryJoin = `JOIN address a ON a.id = o.address_id
+						 LEFT JOIN location l ON l.staff_id = o.sm_id`
+
+//GetDeliverySettings returns the minimum order value and the delivery fee rules of the store
+func GetDeliverySettings(smID int) (models.DeliverySettings, error) {
+	return getDeliverySettings(database.YourDailyDB, smID)
+}
+
+func getDeliverySettings(db sqlx.Queryer, smID int) (models.DeliverySettings, error) {
+	settings := models.DeliverySettings{Rules: make([]models.DeliveryFeeRule, 0)}
+	err := sqlx.Get(db, &settings, `SELECT min_order_value FROM delivery_settings WHERE sm_id = $1`, smID)
+	if err != nil && err != sql.ErrNoRows {
+		return settings, err
+	}
+	query := `SELECT id, mode, min_distance, max_distance, fee, free_above
+			FROM delivery_fee_rules
+			WHERE sm_id = $1
+			ORDER BY mode, min_distance`
+	err = sqlx.Select(db, &settings.Rules, query, smID)
+	return settings, err
+}
+
+//UpdateDeliverySettings replaces the minimum order value and the delivery fee rules of the store,
+//placed orders keep the fee they were charged
+func UpdateDeliverySettings(smID int, settings models.DeliverySettings) error {
+	return database.Tx(func(tx *sqlx.Tx) error {
+		query := `INSERT INTO delivery_settings (sm_id, min_order_value) VALUES ($1, $2)
+				ON CONFLICT (sm_id) DO UPDATE SET min_order_value = excluded.min_order_value,
+												  updated_at      = now()`
+		if _, err := tx.Exec(query, smID, settings.MinOrderValue); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM delivery_fee_rules WHERE sm_id = $1`, smID); err != nil {
+			return err
+		}
+		query = `INSERT INTO delivery_fee_rules (sm_id, mode, min_distance, max_distance, fee, free_above)
+				VALUES ($1, $2, $3, $4, $5, $6)`
+		for _, rule := range settings.Rules {
+			if _, err := tx.Exec(query, smID, rule.Mode, rule.MinDistance, rule.MaxDistance, rule.Fee, rule.FreeAbove); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+//deliveryFee returns the delivery fee of the order worth the value and its distance from the store,
+//pricing.ErrMinOrderValue when enforceMin is set and a delivery order is worth less than the store's minimum
+func deliveryFee(db sqlx.Queryer, delivery orderDelivery, value float32, enforceMin bool) (float32, null.Float64, error) {
+	distance := null.Float64{}
+	if !delivery.SmID.Valid {
+		return 0, distance, nil
+	}
+	settings, err := getDeliverySettings(db, delivery.SmID.Int)
+	if err != nil {
+		return 0, distance, err
+	}
+	if enforceMin && delivery.Mode == models.DeliveryMode {
+		if err := pricing.CheckMinOrderValue(value, settings.MinOrderValue); err != nil {
+			return 0, distance, err
+		}
+	}
+	if delivery.StoreLat.Valid && delivery.StoreLong.Valid {
+		distance = null.Float64From(utils.GeoDistance(delivery.Long, delivery.Lat, delivery.StoreLong.Float64, delivery.StoreLat.Float64))
+	}
+	return pricing.DeliveryFee(settings.Rules, delivery.Mode, distance.Float64, value), distance, nil
+}
+
+//applyDeliveryFee charges the delivery fee on the order for what its items are worth and recalculates its amount
+func applyDeliveryFee(tx *sqlx.Tx, orderID int, enforceMin bool) error {
+	delivery := orderDelivery{}
+	if err := tx.Get(&delivery, orderDeliverySelect+` FROM orders o `+orderDeliveryJoin+` WHERE o.id = $1`, orderID); err != nil {
+		return err
+	}
+	items, err := priceOrderItems(tx, orderID)
+	if err != nil {
+		return err
+	}
+	fee, distance, err := deliveryFee(tx, delivery, pricing.OrderValue(items), enforceMin)
+	if err != nil {
+		return err
+	}
+	query := `UPDATE orders SET delivery_fee = $2, delivery_distance = $3 WHERE id = $1`
+	if _, err := tx.Exec(query, orderID, fee, distance); err != nil {
+		return err
+	}
+	return updateOrderAmount(tx, orderID)
+}
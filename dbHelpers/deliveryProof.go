@@ -0,0 +1,77 @@
+package dbHelpers
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/RemoteState/yourdaily-server/database"
+	"github.com/RemoteState/yourdaily-server/models"
+)
+
+//ErrDeliveryProofClosed is returned when the proof of delivery of an order is stored once it is no more out for delivery
+var ErrDeliveryProofClosed = errors.New("proof can only be captured while the order is out for delivery")
+
+//UpsertDeliveryProof stores the proof of delivery of an order out for delivery, images not sent again are kept from the
+//previous upload, ErrDeliveryProofClosed once the order is delivered so the proof can't be changed afterwards
+func UpsertDeliveryProof(proof models.DeliveryProof) error {
+	query := `INSERT INTO delivery_proofs (order_id, staff_id, photo_image_id, signature_image_id, lat, long, captured_at)
+			SELECT $1, $2, $3, $4, $5, $6, $7
+			FROM orders
+			WHERE orders.id = $1
+			  AND orders.status = $8
+			ON CONFLICT (order_id) DO UPDATE
+				SET staff_id           = excluded.staff_id,
+					photo_image_id     = COALESCE(excluded.photo_image_id, delivery_proofs.photo_image_id),
+					signature_image_id = COALESCE(excluded.signature_image_id, delivery_proofs.signature_image_id),
+					lat                = excluded.lat,
+					long               = excluded.long,
+					captured_at        = excluded.captured_at,
+					updated_at         = now()
+				WHERE EXISTS(SELECT 1 FROM orders WHERE orders.id = delivery_proofs.order_id AND orders.status = $8)`
+	err := execAffectingOne(database.YourDailyDB, query, proof.OrderID, proof.StaffID, proof.PhotoImageID, proof.SignatureImageID,
+		proof.Lat, proof.Long, proof.CapturedAt, models.OutForDelivery)
+	if err == sql.ErrNoRows {
+		return ErrDeliveryProofClosed
+	}
+	return err
+}
+
+//GetDeliveryProof returns the proof of delivery of the order with signed image urls, nil if none was captured
+func GetDeliveryProof(orderID int) (*models.DeliveryProof, error) {
+	query := `SELECT order_id, staff_id, photo_image_id, signature_image_id, lat, long, captured_at
+			FROM delivery_proofs
+			WHERE order_id = $1`
+	proof := models.DeliveryProof{}
+	err := database.YourDailyDB.Get(&proof, query, orderID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if proof.PhotoImageID.Valid {
+		if proof.PhotoURL, err = GetImageUrl(proof.PhotoImageID.Int); err != nil {
+			return nil, err
+		}
+	}
+	if proof.SignatureImageID.Valid {
+		if proof.SignatureURL, err = GetImageUrl(proof.SignatureImageID.Int); err != nil {
+			return nil, err
+		}
+	}
+	return &proof, nil
+}
+
+//IsDeliveryProofMissing tells if the order needs a proof of delivery and none was captured yet
+func IsDeliveryProofMissing(orderID int) (bool, error) {
+	query := `SELECT (o.mode = $2 OR o.amount >= $3)
+				   AND NOT EXISTS(SELECT 1
+								  FROM delivery_proofs dp
+								  WHERE dp.order_id = o.id
+									AND (dp.photo_image_id IS NOT NULL OR dp.signature_image_id IS NOT NULL))
+			FROM orders o
+			WHERE o.id = $1`
+	var missing bool
+	err := database.YourDailyDB.Get(&missing, query, orderID, models.CartMode, models.ProofRequiredAmount)
+	return missing, err
+}
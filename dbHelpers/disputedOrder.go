@@ -60,5 +60,9 @@ func GetDisputedOrderInfo(OrderId int) (models.DisputedOrderInfo, error) {
 	if err != nil {
 		return disOrder, err
 	}
+	disOrder.Proof, err = GetDeliveryProof(OrderId)
+	if err != nil {
+		return disOrder, err
+	}
 	return disOrder, nil
 }
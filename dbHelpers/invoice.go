@@ -0,0 +1,128 @@
+package dbHelpers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/RemoteState/yourdaily-server/database"
+	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/volatiletech/null"
+)
+
+//GetStoreDetails returns the store details of the store manager, falling back to their name when not set up yet
+func GetStoreDetails(smID int) (models.StoreDetails, error) {
+	query := `SELECT name, address, gstin, phone, email, invoice_prefix
+			FROM store_details
+			WHERE sm_id = $1`
+	details := models.StoreDetails{}
+	err := database.YourDailyDB.Get(&details, query, smID)
+	if err == sql.ErrNoRows {
+		details.InvoicePrefix = "YD"
+		err = database.YourDailyDB.Get(&details.Name, `SELECT COALESCE(name, '') FROM users WHERE id = $1`, smID)
+	}
+	return details, err
+}
+
+//UpsertStoreDetails saves the store details printed on new invoices
+func UpsertStoreDetails(smID int, details models.StoreDetails) error {
+	query := `INSERT INTO store_details (sm_id, name, address, gstin, phone, email, invoice_prefix)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (sm_id) DO UPDATE
+				SET name           = excluded.name,
+					address        = excluded.address,
+					gstin          = excluded.gstin,
+					phone          = excluded.phone,
+					email          = excluded.email,
+					invoice_prefix = excluded.invoice_prefix,
+					updated_at     = now()`
+	_, err := database.YourDailyDB.Exec(query, smID, details.Name, details.Address, details.GSTIN, details.Phone,
+		details.Email, details.InvoicePrefix)
+	return err
+}
+
+//GetInvoiceOrder returns the order details needed to build its invoice
+func GetInvoiceOrder(orderID int) (models.InvoiceOrder, error) {
+	query := `SELECT o.id,
+				   o.mode,
+				   o.status,
+				   o.user_id,
+				   o.sm_id,
+				   u.name AS user_name,
+				   a.address_data,
+				   o.coupon_discount,
+				   o.delivery_fee,
+				   o.amount
+			FROM orders o
+					 JOIN users u ON u.id = o.user_id
+					 JOIN address a ON a.id = o.address_id
+			WHERE o.id = $1`
+	order := models.InvoiceOrder{}
+	err := database.YourDailyDB.Get(&order, query, orderID)
+	return order, err
+}
+
+//InsertInvoice numbers the invoice with the next number of the store and stores it,
+//sql.ErrNoRows when the order is already invoiced, in which case the number is not used up
+func InsertInvoice(smID int, invoice models.Invoice) (models.Invoice, error) {
+	err := database.Tx(func(tx *sqlx.Tx) error {
+		var prefix string
+		err := tx.Get(&prefix, `SELECT invoice_prefix FROM store_details WHERE sm_id = $1`, smID)
+		if err == sql.ErrNoRows {
+			prefix, err = "YD", nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// the row lock on the sequence keeps the numbers of a store gapless
+		var sequence int
+		query := `INSERT INTO invoice_sequences (sm_id, last_number)
+				VALUES ($1, 1)
+				ON CONFLICT (sm_id) DO UPDATE SET last_number = invoice_sequences.last_number + 1
+				RETURNING last_number`
+		if err := tx.Get(&sequence, query, smID); err != nil {
+			return err
+		}
+		invoice.Number = fmt.Sprintf(models.InvoiceNumberFormat, prefix, smID, sequence)
+		if err := tx.Get(&invoice.IssuedAt, `SELECT now()`); err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(invoice)
+		if err != nil {
+			return err
+		}
+		query = `INSERT INTO invoices (order_id, sm_id, sequence, invoice_number, data, issued_at)
+				VALUES ($1, $2, $3, $4, $5, $6)
+				ON CONFLICT (order_id) DO NOTHING
+				RETURNING id`
+		return tx.Get(&invoice.ID, query, invoice.OrderID, smID, sequence, invoice.Number, data, invoice.IssuedAt)
+	})
+	return invoice, err
+}
+
+//GetInvoiceByOrderID returns the stored invoice of the order
+func GetInvoiceByOrderID(orderID int) (models.Invoice, error) {
+	query := `SELECT id, data, bucket, path FROM invoices WHERE order_id = $1`
+	record := struct {
+		ID     int         `db:"id"`
+		Data   []byte      `db:"data"`
+		Bucket null.String `db:"bucket"`
+		Path   null.String `db:"path"`
+	}{}
+	invoice := models.Invoice{}
+	if err := database.YourDailyDB.Get(&record, query, orderID); err != nil {
+		return invoice, err
+	}
+	err := json.Unmarshal(record.Data, &invoice)
+	invoice.ID, invoice.Bucket, invoice.Path = record.ID, record.Bucket, record.Path
+	return invoice, err
+}
+
+//SetInvoicePDF records where the pdf of the invoice got uploaded
+func SetInvoicePDF(invoiceID int, bucket, path string) error {
+	query := `UPDATE invoices SET bucket = $2, path = $3 WHERE id = $1`
+	_, err := database.YourDailyDB.Exec(query, invoiceID, bucket, path)
+	return err
+}
@@ -0,0 +1,146 @@
+package dbHelpers
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/RemoteState/yourdaily-server/database"
+	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/volatiletech/null"
+	"time"
+)
+
+//linePriceID is the price version of the catalogue for a line joined with itemVariantJoin, the one applied last
+const linePriceID = `(SELECT ip.id
+				 FROM item_prices ip
+				 WHERE ip.item_id = items.id
+				   AND ip.variant_id IS NOT DISTINCT FROM iv.id
+				   AND ip.applied_at IS NOT NULL
+				 ORDER BY ip.applied_at DESC, ip.effective_from DESC, ip.id DESC
+				 LIMIT 1)`
+
+const itemPriceSelect = `SELECT id,
+				   item_id,
+				   variant_id,
+				   price,
+				   strikethrough_price,
+				   effective_from,
+				   applied_at,
+				   cancelled_at,
+				   created_at,
+				   CASE
+					   WHEN applied_at IS NOT NULL THEN 'applied'
+					   WHEN cancelled_at IS NOT NULL THEN 'cancelled'
+					   ELSE 'scheduled' END AS status
+			FROM item_prices`
+
+//recordItemPrice adds the current price of the item, or its variant, to its price history unless it is the latest
+//one already
+func recordItemPrice(tx *sqlx.Tx, itemID int, variantID null.Int) error {
+	query := `INSERT INTO item_prices (item_id, variant_id, price, strikethrough_price, effective_from, applied_at)
+			SELECT items.id, iv.id, ` + linePrice + `, ` + lineStrikeThroughPrice + `, now(), clock_timestamp()
+			FROM items
+					 ` + itemVariantJoin("$2") + `
+			WHERE items.id = $1
+			  AND ` + lineVariantFound("$2::int") + `
+			  AND NOT EXISTS (SELECT 1
+							  FROM item_prices latest
+							  WHERE latest.id = ` + linePriceID + `
+								AND latest.price = ` + linePrice + `
+								AND latest.strikethrough_price IS NOT DISTINCT FROM ` + lineStrikeThroughPrice + `)`
+	_, err := tx.Exec(query, itemID, variantID)
+	return err
+}
+
+//GetItemPrices returns the price history of the item and its variants with the scheduled changes, latest first
+func GetItemPrices(itemID int) ([]models.ItemPrice, error) {
+	prices := make([]models.ItemPrice, 0)
+	query := itemPriceSelect + ` WHERE item_id = $1 ORDER BY effective_from DESC, id DESC`
+	err := database.YourDailyDB.Select(&prices, query, itemID)
+	return prices, err
+}
+
+//GetItemPrice returns the price version
+func GetItemPrice(priceID int) (models.ItemPrice, error) {
+	price := models.ItemPrice{}
+	err := database.YourDailyDB.Get(&price, itemPriceSelect+` WHERE id = $1`, priceID)
+	return price, err
+}
+
+//ScheduleItemPrice schedules the price change of the item, or its variant, a change effective already is applied
+//right away, ErrItemNotFound when the item or the variant is not sold anymore
+func ScheduleItemPrice(price models.ItemPrice) (int, error) {
+	var priceID int
+	err := database.Tx(func(tx *sqlx.Tx) error {
+		query := `INSERT INTO item_prices (item_id, variant_id, price, strikethrough_price, effective_from)
+				SELECT items.id, iv.id, $3, $4, $5
+				FROM items
+						 ` + itemVariantJoin("$2") + `
+				WHERE items.id = $1
+				  AND items.archived_at IS NULL
+				  AND ` + lineVariantFound("$2::int") + `
+				RETURNING id`
+		err := tx.Get(&priceID, query, price.ItemID, price.VariantID, price.Price, price.StrikeThroughPrice, price.EffectiveFrom)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: %d", ErrItemNotFound, price.ItemID)
+		}
+		if err != nil {
+			return err
+		}
+		if price.EffectiveFrom.After(time.Now()) {
+			return nil
+		}
+		price.ID = priceID
+		return applyItemPrice(tx, price)
+	})
+	return priceID, err
+}
+
+//CancelItemPrice cancels the scheduled price change, sql.ErrNoRows when there is no such change pending
+func CancelItemPrice(priceID int) error {
+	query := `UPDATE item_prices SET cancelled_at = now() WHERE id = $1 AND applied_at IS NULL AND cancelled_at IS NULL`
+	return execAffectingOne(database.YourDailyDB, query, priceID)
+}
+
+//ApplyDueItemPrices makes the scheduled price changes which are effective the prices of the catalogue,
+//in the order they take effect, and returns how many were applied
+func ApplyDueItemPrices() (int, error) {
+	var applied int
+	err := database.Tx(func(tx *sqlx.Tx) error {
+		prices := make([]models.ItemPrice, 0)
+		query := `SELECT id, item_id, variant_id, price, strikethrough_price, effective_from
+				FROM item_prices
+				WHERE applied_at IS NULL
+				  AND cancelled_at IS NULL
+				  AND effective_from <= now()
+				ORDER BY effective_from, id
+					FOR UPDATE SKIP LOCKED`
+		if err := tx.Select(&prices, query); err != nil {
+			return err
+		}
+		for _, price := range prices {
+			if err := applyItemPrice(tx, price); err != nil {
+				return err
+			}
+		}
+		applied = len(prices)
+		return nil
+	})
+	return applied, err
+}
+
+//applyItemPrice sets the price on the item, or its variant, and marks the price version applied
+func applyItemPrice(tx *sqlx.Tx, price models.ItemPrice) error {
+	query := `UPDATE items SET price = $2, strikethrough_price = $3, updated_at = now() WHERE id = $1`
+	args := []interface{}{price.ItemID, price.Price, price.StrikeThroughPrice}
+	if price.VariantID.Valid {
+		query = `UPDATE item_variants SET price = $2, strikethrough_price = $3 WHERE id = $1`
+		args[0] = price.VariantID.Int
+	}
+	if _, err := tx.Exec(query, args...); err != nil {
+		return err
+	}
+	// the time of the statement, not of the transaction, keeps the changes applied in one batch in order
+	_, err := tx.Exec(`UPDATE item_prices SET applied_at = clock_timestamp() WHERE id = $1`, price.ID)
+	return err
+}
@@ -0,0 +1,162 @@
+package dbHelpers
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/RemoteState/yourdaily-server/database"
+	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+//ErrReviewNotVerified is returned when the user never received the item in a delivered order
+var ErrReviewNotVerified = errors.New("item not received in a delivered order")
+
+const itemReviewSelect = `SELECT r.id,
+						   r.item_id,
+						   i.name                AS item_name,
+						   r.user_id,
+						   COALESCE(u.name, '') AS user_name,
+						   r.order_id,
+						   r.rating,
+						   r.review,
+						   r.status,
+						   r.moderation_note,
+						   r.moderated_at,
+						   r.created_at,
+						   r.updated_at
+					FROM item_reviews r
+							 JOIN items i ON i.id = r.item_id
+							 JOIN users u ON u.id = r.user_id
+					WHERE r.archived_at IS NULL`
+
+//SaveItemReview creates or replaces the user's review of an item received in their latest delivered order,
+//a review with text waits for moderation, a rating alone is approved
+func SaveItemReview(userID, itemID, rating int, review string) (int, error) {
+	status := models.ApprovedItemReview
+	if review != "" {
+		status = models.PendingItemReview
+	}
+	var reviewID int
+	err := database.Tx(func(tx *sqlx.Tx) error {
+		var orderID int
+		SQL := `SELECT o.id
+				FROM orders o
+						 JOIN order_items oi ON oi.order_id = o.id
+						 JOIN items i ON i.id = oi.item_id
+				WHERE o.user_id = $1
+				  AND oi.item_id = $2
+				  AND oi.quantity > 0
+				  AND o.status = $3
+				  AND i.archived_at IS NULL
+				ORDER BY o.created_at DESC
+				LIMIT 1`
+		if err := tx.Get(&orderID, SQL, userID, itemID, models.Delivered); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrReviewNotVerified
+			}
+			return err
+		}
+
+		SQL = `INSERT INTO item_reviews(item_id, user_id, order_id, rating, review, status)
+			   VALUES ($1, $2, $3, $4, $5, $6)
+			   ON CONFLICT (item_id, user_id) WHERE archived_at IS NULL DO UPDATE
+				   SET order_id        = excluded.order_id,
+					   rating          = excluded.rating,
+					   review          = excluded.review,
+					   status          = excluded.status,
+					   moderated_by    = NULL,
+					   moderated_at    = NULL,
+					   moderation_note = NULL,
+					   updated_at      = $7
+			   RETURNING id`
+		if err := tx.Get(&reviewID, SQL, itemID, userID, orderID, rating, review, status, time.Now()); err != nil {
+			return err
+		}
+		return refreshItemRating(tx, itemID)
+	})
+	return reviewID, err
+}
+
+//GetItemReview returns a review, sql.ErrNoRows when there is none
+func GetItemReview(reviewID int) (models.ItemReview, error) {
+	var review models.ItemReview
+	err := database.YourDailyDB.Get(&review, itemReviewSelect+` AND r.id = $1`, reviewID)
+	return review, err
+}
+
+//GetUserItemReview returns the user's review of the item whatever its status, sql.ErrNoRows when there is none
+func GetUserItemReview(userID, itemID int) (models.ItemReview, error) {
+	var review models.ItemReview
+	err := database.YourDailyDB.Get(&review, itemReviewSelect+` AND r.user_id = $1 AND r.item_id = $2`, userID, itemID)
+	return review, err
+}
+
+//GetItemReviews returns the approved reviews of an item, newest first
+func GetItemReviews(itemID, offset, limit int) ([]models.ItemReview, error) {
+	reviews := make([]models.ItemReview, 0)
+	SQL := itemReviewSelect + ` AND r.item_id = $1 AND r.status = $2 ORDER BY COALESCE(r.updated_at, r.created_at) DESC, r.id DESC OFFSET $3 LIMIT $4`
+	err := database.YourDailyDB.Select(&reviews, SQL, itemID, models.ApprovedItemReview, offset, limit)
+	return reviews, err
+}
+
+//GetItemReviewsByStatus returns the reviews of all items with the status, oldest first so moderation goes in order
+func GetItemReviewsByStatus(status models.ItemReviewStatus, offset, limit int) ([]models.ItemReview, error) {
+	reviews := make([]models.ItemReview, 0)
+	SQL := itemReviewSelect + ` AND r.status = $1 ORDER BY COALESCE(r.updated_at, r.created_at), r.id OFFSET $2 LIMIT $3`
+	err := database.YourDailyDB.Select(&reviews, SQL, status, offset, limit)
+	return reviews, err
+}
+
+//ModerateItemReview approves or rejects a review, sql.ErrNoRows when there is no such review
+func ModerateItemReview(reviewID, smID int, status models.ItemReviewStatus, note string) error {
+	return database.Tx(func(tx *sqlx.Tx) error {
+		var itemID int
+		SQL := `UPDATE item_reviews
+				SET status          = $2,
+					moderated_by    = $3,
+					moderated_at    = $4,
+					moderation_note = NULLIF($5, '')
+				WHERE id = $1
+				  AND archived_at IS NULL
+				RETURNING item_id`
+		if err := tx.Get(&itemID, SQL, reviewID, status, smID, time.Now(), note); err != nil {
+			return err
+		}
+		return refreshItemRating(tx, itemID)
+	})
+}
+
+//ArchiveItemReview removes the user's review of the item, sql.ErrNoRows when there is none
+func ArchiveItemReview(userID, itemID int) error {
+	return database.Tx(func(tx *sqlx.Tx) error {
+		SQL := `UPDATE item_reviews
+				SET archived_at = $3
+				WHERE user_id = $1
+				  AND item_id = $2
+				  AND archived_at IS NULL`
+		if err := execAffectingOne(tx, SQL, userID, itemID, time.Now()); err != nil {
+			return err
+		}
+		return refreshItemRating(tx, itemID)
+	})
+}
+
+//refreshItemRating sets the rating of the item to the average of its approved reviews, the item row is locked first
+//so a concurrent review change waits and then averages over the reviews committed by this one
+func refreshItemRating(tx *sqlx.Tx, itemID int) error {
+	if _, err := tx.Exec(`SELECT 1 FROM items WHERE id = $1 FOR UPDATE`, itemID); err != nil {
+		return err
+	}
+	SQL := `UPDATE items
+			SET rating       = COALESCE(approved.rating, 0),
+				rating_count = approved.count
+			FROM (SELECT round(avg(rating), 1)::real AS rating, count(*) AS count
+				  FROM item_reviews
+				  WHERE item_id = $1
+					AND status = $2
+					AND archived_at IS NULL) approved
+			WHERE items.id = $1`
+	_, err := tx.Exec(SQL, itemID, models.ApprovedItemReview)
+	return err
+}
@@ -0,0 +1,181 @@
+package dbHelpers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/RemoteState/yourdaily-server/database"
+	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"strings"
+	"unicode"
+)
+
+//ErrInvalidCursor is returned when the cursor of a page can't be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+//itemSortKey is the column a sort orders by, cast is its type to compare the cursor with
+type itemSortKey struct {
+	column, cast, direction string
+}
+
+var itemSortKeys = map[models.ItemSort]itemSortKey{
+	models.RelevanceItemSort: {"rank", "real", "DESC"},
+	models.PriceAscItemSort:  {"price", "numeric", "ASC"},
+	models.PriceDescItemSort: {"price", "numeric", "DESC"},
+	models.NameItemSort:      {"lower(name)", "text", "ASC"},
+	models.NewestItemSort:    {"created_at", "timestamptz", "DESC"},
+	models.RatingItemSort:    {"rating", "real", "DESC"},
+}
+
+//IsValidItemSort reports whether the catalogue can be sorted that way
+func IsValidItemSort(sort models.ItemSort) bool {
+	_, ok := itemSortKeys[sort]
+	return ok
+}
+
+//itemCursor is the sort key and id of the last item of a page, the next page starts after it
+type itemCursor struct {
+	Key string `json:"k"`
+	ID  int    `json:"id"`
+}
+
+//itemDocument is the text searched in an item, it matches the items_search_idx index
+const itemDocument = `to_tsvector('simple', items.name || ' ' || COALESCE(items.base_quantity, ''))`
+
+//SearchItems returns a page of the catalogue matching the search in name, category and base quantity,
+//words match by prefix and misspelt words by trigram similarity
+func SearchItems(search models.ItemSearch) (models.ItemPage, error) {
+	page := models.ItemPage{Items: make([]models.Item, 0)}
+	args := make([]interface{}, 0)
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	rank := `0::real`
+	conditions := []string{`items.archived_at IS NULL`}
+	if words := searchWords(search.Query); len(words) > 0 {
+		tsQuery := fmt.Sprintf(`to_tsquery('simple', %s)`, arg(strings.Join(words, ":* & ")+":*"))
+		text := arg(strings.Join(words, " "))
+		// <% matches from pg_trgm.word_similarity_threshold, set to ItemTypoSimilarity for the query
+		conditions = append(conditions, fmt.Sprintf(`(%[1]s @@ %[2]s
+					OR to_tsvector('simple', c.category) @@ %[2]s
+					OR %[3]s <%% items.name
+					OR %[3]s <%% c.category)`, itemDocument, tsQuery, text))
+		rank = fmt.Sprintf(`(ts_rank(%s, %s) + word_similarity(%s, items.name))::real`, itemDocument, tsQuery, text)
+	}
+	if len(search.CategoryIDs) > 0 {
+		conditions = append(conditions, fmt.Sprintf(`items.category IN (WITH RECURSIVE tree AS (SELECT id
+														  FROM categories
+														  WHERE id = ANY (%s)
+														  UNION
+														  SELECT child.id
+														  FROM categories child
+																   JOIN tree ON child.parent_id = tree.id)
+					SELECT id
+					FROM tree)`, arg(pq.Int64Array(search.CategoryIDs))))
+	}
+	if search.MinPrice.Valid {
+		conditions = append(conditions, fmt.Sprintf(`items.price >= %s`, arg(search.MinPrice)))
+	}
+	if search.MaxPrice.Valid {
+		conditions = append(conditions, fmt.Sprintf(`items.price <= %s`, arg(search.MaxPrice)))
+	}
+	if search.InStockOnly {
+		conditions = append(conditions, `items.in_stock = TRUE`)
+	}
+	if search.VisibleOnly {
+		conditions = append(conditions, itemCategoryVisible)
+	}
+
+	sortKey := itemSortKeys[search.Sort]
+	query := fmt.Sprintf(`SELECT id, name, price, in_stock, created_at, category, base_quantity, strikethrough_price, tax_slab_id, category_name,
+					   rating, rating_count,
+					   %[1]s AS sort_value,
+					   %[1]s::text AS sort_key
+				FROM (SELECT items.id,
+							 items.name,
+							 items.price,
+							 items.in_stock,
+							 items.created_at,
+							 items.category,
+							 items.base_quantity,
+							 items.strikethrough_price,
+							 items.tax_slab_id,
+							 items.rating,
+							 items.rating_count,
+							 c.category AS category_name,
+							 %[2]s      AS rank
+					  FROM items
+							   JOIN categories c ON c.id = items.category
+					  WHERE %[3]s) matches`, sortKey.column, rank, strings.Join(conditions, "\n\t\t\t\t\t\tAND "))
+	if search.Cursor != "" {
+		cursor, err := decodeItemCursor(search.Cursor)
+		if err != nil {
+			return page, err
+		}
+		operator := ">"
+		if sortKey.direction == "DESC" {
+			operator = "<"
+		}
+		query += fmt.Sprintf(` WHERE (%s, id) %s (%s::%s, %s)`, sortKey.column, operator, arg(cursor.Key), sortKey.cast, arg(cursor.ID))
+	}
+	query += fmt.Sprintf(` ORDER BY %[1]s %[2]s, id %[2]s LIMIT %[3]s`, sortKey.column, sortKey.direction, arg(search.Limit+1))
+	// the images are aggregated for the items of the page only
+	query = fmt.Sprintf(`SELECT page.id, page.name, page.price, page.in_stock, page.created_at, page.category, page.base_quantity,
+					   page.strikethrough_price, page.tax_slab_id, page.category_name, page.rating,
+					   page.rating_count, page.sort_key,
+					   %[1]s
+				FROM (%[2]s) page
+						 %[3]s
+				ORDER BY page.sort_value %[4]s, page.id %[4]s`, itemImagesColumns, query, itemImagesJoin("page.id"), sortKey.direction)
+
+	rows := make([]struct {
+		itemWithImages
+		SortKey string `db:"sort_key"`
+	}, 0, search.Limit+1)
+	err := database.Tx(func(tx *sqlx.Tx) error {
+		if _, err := tx.Exec(`SELECT set_config('pg_trgm.word_similarity_threshold', $1, TRUE)`, fmt.Sprint(models.ItemTypoSimilarity)); err != nil {
+			return err
+		}
+		return tx.Select(&rows, query, args...)
+	})
+	if err != nil {
+		return page, err
+	}
+	for i, row := range rows {
+		if i == search.Limit {
+			page.NextCursor = encodeItemCursor(itemCursor{Key: rows[i-1].SortKey, ID: rows[i-1].ID})
+			break
+		}
+		page.Items = append(page.Items, row.item())
+	}
+	return page, nil
+}
+
+//searchWords splits the search into lower case words of letters and digits, so it is safe to use in a tsquery
+func searchWords(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func encodeItemCursor(cursor itemCursor) string {
+	encoded, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+func decodeItemCursor(encoded string) (itemCursor, error) {
+	cursor := itemCursor{}
+	decoded, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return cursor, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(decoded, &cursor); err != nil || cursor.ID == 0 {
+		return cursor, ErrInvalidCursor
+	}
+	return cursor, nil
+}
@@ -0,0 +1,172 @@
+package dbHelpers
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/RemoteState/yourdaily-server/database"
+	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/volatiletech/null"
+)
+
+//itemVariantJoin joins the variant iv of items picked by the given variant id column, a line without a variant
+//is priced from the item itself
+func itemVariantJoin(variantID string) string {
+	return `LEFT JOIN item_variants iv ON iv.id = ` + variantID + ` AND iv.item_id = items.id`
+}
+
+//the catalogue details of a line joined with itemVariantJoin, taken from the variant when the line has one
+const (
+	lineName               = `items.name`
+	linePrice              = `COALESCE(iv.price, items.price)`
+	lineStrikeThroughPrice = `CASE WHEN iv.id IS NULL THEN items.strikethrough_price ELSE iv.strikethrough_price END`
+	lineBaseQuantity       = `COALESCE(iv.name, items.base_quantity)`
+	lineAvailable          = `COALESCE(items.in_stock, FALSE) AND items.archived_at IS NULL AND COALESCE(iv.in_stock AND iv.archived_at IS NULL, TRUE) AND ` + itemCategoryVisible
+	lineArchived           = `(items.archived_at IS NOT NULL OR iv.archived_at IS NOT NULL)`
+)
+
+//lineVariantFound is true when the line has no variant or the variant is still sold
+func lineVariantFound(variantID string) string {
+	return `(` + variantID + ` IS NULL OR (iv.id IS NOT NULL AND iv.archived_at IS NULL))`
+}
+
+//lineImageJoin joins the first image img of the line, the one of its variant when it has any
+const lineImageJoin = `LEFT JOIN LATERAL (SELECT i.bucket, i.path
+										FROM (SELECT vi.image_id, 0 AS rank, vi.id
+											  FROM item_variant_images vi
+											  WHERE vi.variant_id = iv.id
+											  UNION ALL
+											  SELECT ii.image_id, 1 AS rank, ii.id
+											  FROM item_images ii
+											  WHERE ii.item_id = items.id) line_images
+												 JOIN images i ON i.id = line_images.image_id
+										ORDER BY line_images.rank, line_images.id
+										LIMIT 1) img ON TRUE`
+
+const itemVariantSelect = `SELECT iv.id,
+				   iv.item_id,
+				   iv.name,
+				   iv.quantity,
+				   iv.unit,
+				   iv.price,
+				   iv.strikethrough_price,
+				   iv.in_stock,
+				   ` + itemImagesColumns + `
+			FROM item_variants iv
+					 LEFT JOIN LATERAL (SELECT array_agg(i.bucket ORDER BY i.created_at DESC) AS buckets,
+											   array_agg(i.path ORDER BY i.created_at DESC)   AS paths
+										FROM item_variant_images vi
+												 JOIN images i ON i.id = vi.image_id
+										WHERE vi.variant_id = iv.id) item_images ON TRUE
+			WHERE iv.archived_at IS NULL`
+
+//GetItemVariants returns the variants of the items by item id along with their images, smallest pack first
+func GetItemVariants(itemIDs []int) (map[int][]models.ItemVariant, error) {
+	ids := make(pq.Int64Array, 0, len(itemIDs))
+	for _, itemID := range itemIDs {
+		ids = append(ids, int64(itemID))
+	}
+	rows := make([]struct {
+		models.ItemVariant
+		ImageBuckets pq.StringArray `db:"image_buckets"`
+		ImagePaths   pq.StringArray `db:"image_paths"`
+	}, 0)
+	query := itemVariantSelect + ` AND iv.item_id = ANY ($1) ORDER BY iv.item_id, iv.unit, iv.quantity, iv.id`
+	if err := database.YourDailyDB.Select(&rows, query, ids); err != nil {
+		return nil, err
+	}
+	variants := make(map[int][]models.ItemVariant, len(itemIDs))
+	for _, row := range rows {
+		variant := row.ItemVariant
+		variant.Images = make([]models.Image, 0, len(row.ImagePaths))
+		for i := range row.ImagePaths {
+			variant.Images = append(variant.Images, models.Image{Bucket: row.ImageBuckets[i], Path: row.ImagePaths[i]})
+		}
+		variants[variant.ItemID] = append(variants[variant.ItemID], variant)
+	}
+	return variants, nil
+}
+
+//InsertItemVariant adds the variant to its item along with its image, ErrItemNotFound when the item is not sold anymore
+func InsertItemVariant(variant models.ItemVariant, imageID null.Int) (int, error) {
+	var variantID int
+	err := database.Tx(func(tx *sqlx.Tx) error {
+		query := `INSERT INTO item_variants (item_id, name, quantity, unit, price, strikethrough_price, in_stock)
+				SELECT id, $2, $3, $4, $5, $6, $7 FROM items WHERE id = $1 AND archived_at IS NULL
+				RETURNING id`
+		err := tx.Get(&variantID, query, variant.ItemID, variant.Name, variant.Quantity, variant.Unit, variant.Price, variant.StrikeThroughPrice, variant.InStock)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: %d", ErrItemNotFound, variant.ItemID)
+		}
+		if err != nil {
+			return err
+		}
+		if err := recordItemPrice(tx, variant.ItemID, null.IntFrom(variantID)); err != nil {
+			return err
+		}
+		if !imageID.Valid {
+			return nil
+		}
+		return linkVariantWithImage(tx, variantID, imageID.Int)
+	})
+	return variantID, err
+}
+
+//ModifyItemVariant updates the variant, recording its price, and replaces its image when one is given,
+//sql.ErrNoRows when there is no such variant
+func ModifyItemVariant(variant models.ItemVariant, imageID null.Int) error {
+	return database.Tx(func(tx *sqlx.Tx) error {
+		query := `UPDATE item_variants
+				SET name                = $2,
+					quantity            = $3,
+					unit                = $4,
+					price               = $5,
+					strikethrough_price = $6,
+					in_stock            = $7
+				WHERE id = $1
+				  AND archived_at IS NULL
+				RETURNING item_id`
+		var itemID int
+		if err := tx.Get(&itemID, query, variant.ID, variant.Name, variant.Quantity, variant.Unit, variant.Price, variant.StrikeThroughPrice, variant.InStock); err != nil {
+			return err
+		}
+		if err := recordItemPrice(tx, itemID, null.IntFrom(variant.ID)); err != nil || !imageID.Valid {
+			return err
+		}
+		return linkVariantWithImage(tx, variant.ID, imageID.Int)
+	})
+}
+
+//ArchiveItemVariant stops selling the variant, sql.ErrNoRows when there is no such variant
+func ArchiveItemVariant(variantID int) error {
+	query := `UPDATE item_variants SET archived_at = now() WHERE id = $1 AND archived_at IS NULL`
+	return execAffectingOne(database.YourDailyDB, query, variantID)
+}
+
+//GetItemVariant returns the variant with its images
+func GetItemVariant(variantID int) (models.ItemVariant, error) {
+	variant := models.ItemVariant{}
+	var itemID int
+	if err := database.YourDailyDB.Get(&itemID, `SELECT item_id FROM item_variants WHERE id = $1 AND archived_at IS NULL`, variantID); err != nil {
+		return variant, err
+	}
+	variants, err := GetItemVariants([]int{itemID})
+	if err != nil {
+		return variant, err
+	}
+	for _, itemVariant := range variants[itemID] {
+		if itemVariant.ID == variantID {
+			return itemVariant, nil
+		}
+	}
+	return variant, sql.ErrNoRows
+}
+
+func linkVariantWithImage(tx *sqlx.Tx, variantID, imageID int) error {
+	if _, err := tx.Exec(`DELETE FROM item_variant_images WHERE variant_id = $1`, variantID); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`INSERT INTO item_variant_images (variant_id, image_id) VALUES ($1, $2)`, variantID, imageID)
+	return err
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/RemoteState/yourdaily-server/database"
 	"github.com/RemoteState/yourdaily-server/models"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/volatiletech/null"
 	"time"
 )
@@ -12,43 +13,53 @@ import (
 // InsertItem creates a new item entry in table
 
 func InsertItem(name string, price float32, inStock bool, categoryID int, baseQuantity string, strikeThroughPrice null.Float32) (int, error) {
-	SQL := `INSERT INTO items(name, price, in_stock, category, base_quantity,strikethrough_price) VALUES ($1, $2, $3, $4,$5, null) RETURNING id`
-	if strikeThroughPrice.Valid {
-		SQL = `INSERT INTO items(name, price, in_stock, category, base_quantity,strikethrough_price) VALUES ($1, $2, $3, $4, $5,$6) RETURNING id`
-		var itemID int
-		err := database.YourDailyDB.Get(&itemID, SQL, name, price, inStock, categoryID, baseQuantity, strikeThroughPrice)
-		return itemID, err
-	}
+	SQL := `INSERT INTO items(name, price, in_stock, category, base_quantity,strikethrough_price) VALUES ($1, $2, $3, $4, $5,$6) RETURNING id`
 	var itemID int
-	err := database.YourDailyDB.Get(&itemID, SQL, name, price, inStock, categoryID, baseQuantity)
+	err := database.Tx(func(tx *sqlx.Tx) error {
+		if err := tx.Get(&itemID, SQL, name, price, inStock, categoryID, baseQuantity, strikeThroughPrice); err != nil {
+			return err
+		}
+		return recordItemPrice(tx, itemID, null.Int{})
+	})
 	return itemID, err
-
 }
 
-// GetItems returns all items
-func GetItems(OutOfStock bool) ([]models.Item, error) {
+// GetItems returns all items along with their images
+func GetItems(OutOfStock, visibleOnly bool) ([]models.Item, error) {
 	SQL := `SELECT
-			id,
-			name,
-			price,
-			in_stock,
-			created_at,
-			category,
-       		base_quantity,
-     		strikethrough_price
+			items.id,
+			items.name,
+			items.price,
+			items.in_stock,
+			items.created_at,
+			items.category,
+       		items.base_quantity,
+     		items.strikethrough_price,
+     		items.tax_slab_id,
+     		items.rating,
+     		items.rating_count,
+     		` + itemImagesColumns + `
 		FROM items
-		WHERE archived_at IS NULL 
+		` + itemImagesJoin("items.id") + `
+		WHERE items.archived_at IS NULL 
 `
 	if !OutOfStock {
-		SQL += `AND in_stock = TRUE `
+		SQL += `AND items.in_stock = TRUE `
 	}
-	SQL += `ORDER BY created_at DESC `
-	items := make([]models.Item, 0)
+	if visibleOnly {
+		SQL += `AND ` + itemCategoryVisible + ` `
+	}
+	SQL += `ORDER BY items.created_at DESC `
+	rows := make([]itemWithImages, 0)
 
-	err := database.YourDailyDB.Select(&items, SQL)
+	err := database.YourDailyDB.Select(&rows, SQL)
 	if err != nil {
 		return nil, err
 	}
+	items := make([]models.Item, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, row.item())
+	}
 	return items, nil
 }
 
@@ -72,8 +83,12 @@ func ModifyItem(name string, price float32, inStock bool, categoryID, itemID int
 				base_quantity = $7,
 			    strikethrough_price = $8
 			WHERE id = $6`
-	_, err := database.YourDailyDB.Exec(SQL, name, price, inStock, time.Now(), categoryID, itemID, baseQuantity, strikeThroughPrice)
-	return err
+	return database.Tx(func(tx *sqlx.Tx) error {
+		if _, err := tx.Exec(SQL, name, price, inStock, time.Now(), categoryID, itemID, baseQuantity, strikeThroughPrice); err != nil {
+			return err
+		}
+		return recordItemPrice(tx, itemID, null.Int{})
+	})
 }
 
 // GetItemById gets the item details for a given id
@@ -86,7 +101,10 @@ func GetItemById(itemID int) (*models.Item, error) {
 			created_at,
 			category,
        		base_quantity,
-     		strikethrough_price
+     		strikethrough_price,
+     		tax_slab_id,
+     		rating,
+     		rating_count
 		FROM items
 		WHERE archived_at IS NULL
 		AND id = $1`
@@ -101,6 +119,18 @@ func GetItemById(itemID int) (*models.Item, error) {
 	return &item, nil
 }
 
+//IsItemVisible tells if the item is in the catalogue and its category is shown to users now
+func IsItemVisible(itemID int) (bool, error) {
+	SQL := `SELECT EXISTS(SELECT 1
+						  FROM items
+						  WHERE items.archived_at IS NULL
+							AND items.id = $1
+							AND ` + itemCategoryVisible + `)`
+	var visible bool
+	err := database.YourDailyDB.Get(&visible, SQL, itemID)
+	return visible, err
+}
+
 // ArchiveItem archives a given item
 func ArchiveItem(itemID int) error {
 	SQL := `UPDATE items 
@@ -124,16 +154,20 @@ func ArchiveItem(itemID int) error {
 // LinkItemWithImage stores relation between an image and item
 func LinkItemWithImage(itemID, imageID int) error {
 	err := database.Tx(func(tx *sqlx.Tx) error {
-		SQL := `DELETE FROM item_images where item_id = $1`
-		_, err := tx.Exec(SQL, itemID)
-		if err != nil {
-			return err
-		}
+		return linkItemWithImage(tx, itemID, imageID)
+	})
+	return err
+}
 
-		SQL = `INSERT INTO item_images(item_id, image_id) VALUES ($1, $2)`
-		_, err = tx.Exec(SQL, itemID, imageID)
+func linkItemWithImage(tx *sqlx.Tx, itemID, imageID int) error {
+	SQL := `DELETE FROM item_images where item_id = $1`
+	_, err := tx.Exec(SQL, itemID)
+	if err != nil {
 		return err
-	})
+	}
+
+	SQL = `INSERT INTO item_images(item_id, image_id) VALUES ($1, $2)`
+	_, err = tx.Exec(SQL, itemID, imageID)
 	return err
 }
 
@@ -156,3 +190,32 @@ func GetImageInfoByItemID(itemID int) ([]models.Image, error) {
 	}
 	return imagesInfo, nil
 }
+
+//itemImagesColumns are the buckets and paths of the images of an item, latest first, joined by itemImagesJoin
+const itemImagesColumns = `COALESCE(item_images.buckets, '{}') AS image_buckets,
+					   COALESCE(item_images.paths, '{}')   AS image_paths`
+
+//itemImagesJoin aggregates the images of the item with the given id column, so items and their images load in one query
+func itemImagesJoin(itemID string) string {
+	return `LEFT JOIN LATERAL (SELECT array_agg(i.bucket ORDER BY i.created_at DESC) AS buckets,
+									  array_agg(i.path ORDER BY i.created_at DESC)   AS paths
+							   FROM item_images ii
+										JOIN images i ON i.id = ii.image_id
+							   WHERE ii.item_id = ` + itemID + `) item_images ON TRUE`
+}
+
+//itemWithImages is an item selected along with itemImagesColumns
+type itemWithImages struct {
+	models.Item
+	ImageBuckets pq.StringArray `db:"image_buckets"`
+	ImagePaths   pq.StringArray `db:"image_paths"`
+}
+
+func (row itemWithImages) item() models.Item {
+	item := row.Item
+	item.Images = make([]models.Image, 0, len(row.ImagePaths))
+	for i := range row.ImagePaths {
+		item.Images = append(item.Images, models.Image{Bucket: row.ImageBuckets[i], Path: row.ImagePaths[i]})
+	}
+	return item
+}
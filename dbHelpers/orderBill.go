@@ -0,0 +1,68 @@
+package dbHelpers
+
+import (
+	"database/sql"
+	"github.com/RemoteState/yourdaily-server/database"
+	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/jmoiron/sqlx"
+)
+
+//GetOrderBill returns the bill state of the order
+func GetOrderBill(orderID int) (models.OrderBill, error) {
+	query := `SELECT id, mode, status, user_id, staff_id, amount, bill_submitted_at, bill_confirmed_at
+			FROM orders
+			WHERE id = $1`
+	bill := models.OrderBill{}
+	err := database.YourDailyDB.Get(&bill, query, orderID)
+	return bill, err
+}
+
+//SubmitOrderBill stores the items the staff sold from the cart as the order's items, priced by the server,
+//and waits for the user to confirm it
+func SubmitOrderBill(orderID int, items []models.ItemInfo) error {
+	return database.Tx(func(tx *sqlx.Tx) error {
+		if err := replaceOrderItems(tx, orderID, items); err != nil {
+			return err
+		}
+		query := `UPDATE orders
+				SET bill_submitted_at = now(),
+					bill_confirmed_at = NULL,
+					updated_at        = now()
+				WHERE id = $1`
+		_, err := tx.Exec(query, orderID)
+		return err
+	})
+}
+
+//ConfirmOrderBill confirms or rejects the pending bill of the user's order, sql.ErrNoRows when there is none
+func ConfirmOrderBill(orderID, userID int, confirm bool) error {
+	query := `UPDATE orders
+			SET bill_confirmed_at = now(),
+				updated_at        = now()
+			WHERE id = $1
+			  AND user_id = $2
+			  AND bill_submitted_at IS NOT NULL
+			  AND bill_confirmed_at IS NULL`
+	if !confirm {
+		// the staff has to submit the bill again
+		query = `UPDATE orders
+			SET bill_submitted_at = NULL,
+				updated_at        = now()
+			WHERE id = $1
+			  AND user_id = $2
+			  AND bill_submitted_at IS NOT NULL
+			  AND bill_confirmed_at IS NULL`
+	}
+	result, err := database.YourDailyDB.Exec(query, orderID, userID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -2,10 +2,12 @@ package dbHelpers
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/RemoteState/yourdaily-server/database"
 	"github.com/RemoteState/yourdaily-server/firebase"
 	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/RemoteState/yourdaily-server/pricing"
 	"github.com/RemoteState/yourdaily-server/utils"
 	"github.com/jmoiron/sqlx"
 	"github.com/sirupsen/logrus"
@@ -28,59 +30,116 @@ func InsertIntoOrders(data models.Order) (int, error) {
 	var orderID int
 
 	err := database.Tx(func(tx *sqlx.Tx) error {
-		insertOrder := `INSERT INTO orders (mode, user_id,address_id,amount, delivery_time,sm_id) 
-						VALUES ($1,$2,$3,$4,$5,$6) RETURNING id`
-		err := database.YourDailyDB.Get(&orderID, insertOrder,
-			data.Mode,
-			data.UserID,
-			data.AddressID,
-			data.Amount,
-			data.DeliveryTime, data.StoreMangerID)
+		var err error
+		orderID, err = insertIntoOrders(tx, data)
+		return err
+	})
+
+	return orderID, err
+}
+
+func insertIntoOrders(tx *sqlx.Tx, data models.Order) (int, error) {
+	orderID, err := insertOrder(tx, data)
+	if err != nil {
+		return orderID, err
+	}
+
+	otp, err := utils.GenerateOTP()
+	if err != nil {
+		return orderID, err
+	}
+	InsertOTPQuery := `INSERT INTO order_otp (order_id,otp) VALUES($1,$2) `
+	_, err = tx.Exec(InsertOTPQuery, orderID, otp)
+	if err != nil {
+		return orderID, err
+	}
+
+	return orderID, priceOrder(tx, orderID, data)
+}
+
+func insertOrder(tx *sqlx.Tx, data models.Order) (int, error) {
+	insertOrder := `INSERT INTO orders (mode, user_id,address_id,amount, delivery_time,sm_id) 
+					VALUES ($1,$2,$3,$4,$5,$6) RETURNING id`
+	var orderID int
+	err := tx.Get(&orderID, insertOrder,
+		data.Mode,
+		data.UserID,
+		data.AddressID,
+		data.Amount,
+		data.DeliveryTime, data.StoreMangerID)
+	return orderID, err
+}
 
+//priceOrder adds the items to the order with the active offer, the delivery fee and the coupon of the order applied
+func priceOrder(tx *sqlx.Tx, orderID int, data models.Order) error {
+	offer, err := GetActiveOffer()
+	if err != nil {
+		return err
+	}
+	discount := offer.Discount
+
+	var coupon models.Coupon
+	if data.CouponCode != "" {
+		coupon, err = GetCouponByCode(data.CouponCode)
 		if err != nil {
 			return err
 		}
+		// a coupon which doesn't stack replaces the active offer
+		if !coupon.Stackable {
+			discount = 0
+		}
+	}
 
-		InsertOTPQuery := `INSERT INTO order_otp (order_id,otp) VALUES($1,$2) `
-		_, err = database.YourDailyDB.Exec(InsertOTPQuery, orderID, utils.GenerateOTP())
+	if err := insertOrderItems(tx, orderID, data.Items, discount, false); err != nil {
+		return err
+	}
+	if err := applyDeliveryFee(tx, orderID, true); err != nil {
+		return err
+	}
+	if data.CouponCode != "" {
+		return applyCoupon(tx, orderID, data.UserID, coupon.ID)
+	}
+	return nil
+}
+
+//errQuoted rolls back the draft order of a quote once it is priced
+var errQuoted = errors.New("order quoted")
+
+//QuoteOrder prices the order exactly like InsertIntoOrders does, on a draft order which is rolled back
+func QuoteOrder(data models.Order) (models.OrderQuote, error) {
+	quote := models.OrderQuote{}
+	err := database.Tx(func(tx *sqlx.Tx) error {
+		orderID, err := insertOrder(tx, data)
 		if err != nil {
 			return err
 		}
-
-		offer, err := GetActiveOffer()
-		if err != nil {
+		if err := priceOrder(tx, orderID, data); err != nil {
 			return err
 		}
 
-		for _, v := range data.Items {
-			query := `INSERT INTO order_items(order_id,name,price,category,base_quantity,strikethrough_price,bucket,path,quantity, discount) (
-						SELECT $1 AS order_id ,name, price, c.category, base_quantity,items.strikethrough_price, bucket, path, $2 AS quantity, $4 AS discount
-												FROM items
-														 JOIN categories c ON c.id = items.category
-														 LEFT JOIN item_images ii ON items.id = ii.item_id
-														 LEFT JOIN images i ON i.id = ii.image_id
-												WHERE items.id = $3)`
-
-			_, err = tx.Exec(query, orderID, v.Quantity, v.Id, offer.Discount)
-		}
-		if err != nil {
+		if err := tx.Get(&quote, `SELECT coupon_discount, delivery_fee, amount FROM orders WHERE id = $1`, orderID); err != nil {
 			return err
 		}
-
-		query := `
-			UPDATE orders
-			SET amount = (SELECT COALESCE(SUM((price - (price * discount/100)) * quantity),0)
-						  FROM order_items
-						  WHERE order_id = $1)
-			WHERE id = $1`
-		_, err = tx.Exec(query, orderID)
+		quote.Items, err = getOrderItems(tx, orderID)
 		if err != nil {
 			return err
 		}
-		return nil
+		for i := range quote.Items {
+			pricing.Item(&quote.Items[i])
+			quote.ItemTotal += pricing.Gross(quote.Items[i])
+			quote.OfferDiscount += pricing.Discount(quote.Items[i])
+			quote.TaxableAmount += quote.Items[i].TaxableAmount
+			quote.TaxAmount += quote.Items[i].TaxAmount
+		}
+		quote.ItemTotal, quote.OfferDiscount = pricing.Round(quote.ItemTotal), pricing.Round(quote.OfferDiscount)
+		quote.TaxableAmount, quote.TaxAmount = pricing.Round(quote.TaxableAmount), pricing.Round(quote.TaxAmount)
+		return errQuoted
 	})
-
-	return orderID, err
+	if err == errQuoted {
+		err = nil
+	}
+	quote.CouponCode = data.CouponCode
+	return quote, err
 }
 
 //SelectLocationOfStaff returns the list of all the staff with given mode type(cart-boy/delivery-boy)
@@ -110,12 +169,17 @@ func SelectOrder(orderID, userID int) (*models.Order, error) {
 					   delivery_time,
 					   created_at,
 					   updated_at,
-					   otp
+					   otp,
+					   order_otp.expires_at AS otp_expires_at,
+					   bill_submitted_at,
+					   bill_confirmed_at,
+					   coupon_discount,
+					   delivery_fee
 				FROM orders
 						 LEFT JOIN order_otp ON orders.id = order_otp.order_id
 				WHERE orders.id= $1
 				  AND user_id = $2
-				  GROUP BY orders.id, order_otp.otp`
+				  GROUP BY orders.id, order_otp.otp, order_otp.expires_at`
 
 	orderDetails := models.Order{}
 	err := database.YourDailyDB.Get(&orderDetails, query, orderID, userID)
@@ -185,12 +249,13 @@ func SelectActiveOrders(userID, offset, limit int) ([]models.Order, error) {
 				   delivery_time,
 				   created_at,
 				   updated_at,
-				   otp
+				   otp,
+				   order_otp.expires_at AS otp_expires_at
 			FROM orders
 					 LEFT JOIN order_otp ON orders.id = order_otp.order_id
 			WHERE user_id = $1 AND (status =$4 OR status = $5 OR status = $6)
 				AND orders.order_type = $7
-			  GROUP BY orders.id, order_otp.otp, created_at 
+			  GROUP BY orders.id, order_otp.otp, order_otp.expires_at, created_at 
 			ORDER BY created_at 
 			DESC OFFSET $2 LIMIT $3`
 
@@ -222,14 +287,15 @@ func SelectActiveOrders(userID, offset, limit int) ([]models.Order, error) {
 				   delivery_time,
 				   created_at,
 				   updated_at,
-				   otp
+				   otp,
+				   order_otp.expires_at AS otp_expires_at
 			FROM orders
 					 LEFT JOIN order_otp ON orders.id = order_otp.order_id
 			WHERE user_id = $1
 			  AND (status = $4 OR status = $5)
 			  AND (delivery_time - NOW()) <= ($7 ||' second')::INTERVAL
 			  AND orders.order_type = $6
-			GROUP BY orders.id, order_otp.otp, created_at
+			GROUP BY orders.id, order_otp.otp, order_otp.expires_at, created_at
 			ORDER BY created_at DESC
 			OFFSET $2 LIMIT $3`
 
@@ -257,63 +323,113 @@ func InsertScheduledOrder(newOrder models.ScheduledOrder) (int, error) {
 
 	var scheduleOrderID int
 	txError := database.Tx(func(tx *sqlx.Tx) error {
-		SQL := `INSERT INTO scheduled_orders(user_id, address_id, mode, created_at, start_date, end_date,sm_id) VALUES ($1, $2, $3, $4, $5, $6,$7) RETURNING id`
-		err := tx.Get(&scheduleOrderID, SQL, newOrder.UserID, newOrder.AddressID, newOrder.Mode, time.Now(), newOrder.StartDate, newOrder.EndDate, newOrder.StoreMangerID)
-		if err != nil {
-			return err
-		}
+		var err error
+		scheduleOrderID, err = insertScheduledOrder(tx, newOrder)
+		return err
+	})
+	return scheduleOrderID, txError
+}
 
-		for _, weekday := range newOrder.Weekdays {
-			SQL = `INSERT INTO scheduled_orders_days(weekday, delivery_time, scheduled_order_id, created_at) VALUES ($1, $2, $3, $4)`
-			_, err = tx.Exec(SQL, weekday, newOrder.DeliveryTime, scheduleOrderID, time.Now())
-			if err != nil {
-				return err
-			}
-		}
+func insertScheduledOrder(tx *sqlx.Tx, newOrder models.ScheduledOrder) (int, error) {
+	var scheduleOrderID int
+	SQL := `INSERT INTO scheduled_orders(user_id, address_id, mode, created_at, start_date, end_date,sm_id) VALUES ($1, $2, $3, $4, $5, $6,$7) RETURNING id`
+	err := tx.Get(&scheduleOrderID, SQL, newOrder.UserID, newOrder.AddressID, newOrder.Mode, time.Now(), newOrder.StartDate, newOrder.EndDate, newOrder.StoreMangerID)
+	if err != nil {
+		return scheduleOrderID, err
+	}
 
-		for _, itemInfo := range newOrder.Items {
-			SQL = `INSERT INTO scheduled_ordered_items(item_id, order_id, name, price, category,strikethrough_price, base_quantity, bucket, path, quantity) (
-				   SELECT 
-				          items.id,
-				          $1 AS order_id,
-				          name, 
-				          price, 
-				          c.category,
-				          items.strikethrough_price,
-				          base_quantity, 
-				          bucket, 
-				          path, 
-				          $2 AS quantity
-				   FROM items
-				   LEFT JOIN categories c ON c.id = items.category
-				   LEFT JOIN item_images ii ON items.id = ii.item_id
-				   LEFT JOIN images i ON i.id = ii.image_id
-				   WHERE items.id = $3
-				   AND i.archived_at IS NULL
-				   ORDER BY i.created_at DESC LIMIT 1)`
-			_, err = tx.Exec(SQL, scheduleOrderID, itemInfo.Quantity, itemInfo.Id)
-			if err != nil {
-				return err
-			}
+	for _, weekday := range newOrder.Weekdays {
+		SQL = `INSERT INTO scheduled_orders_days(weekday, delivery_time, scheduled_order_id, created_at) VALUES ($1, $2, $3, $4)`
+		_, err = tx.Exec(SQL, weekday, newOrder.DeliveryTime, scheduleOrderID, time.Now())
+		if err != nil {
+			return scheduleOrderID, err
 		}
-		//todo order amount fix in future
-		SQL = `UPDATE scheduled_orders 
-			   SET amount = (SELECT COALESCE(SUM(price * quantity),0) FROM scheduled_ordered_items WHERE order_id = $1) 
-			   WHERE id = $1`
-		result, err := tx.Exec(SQL, scheduleOrderID)
+	}
+
+	for _, itemInfo := range newOrder.Items {
+		SQL = `INSERT INTO scheduled_ordered_items(item_id, order_id, name, price, category,strikethrough_price, base_quantity, bucket, path, quantity, variant_id, unit_quantity, unit, price_id) (
+			   SELECT 
+			          items.id,
+			          $1 AS order_id,
+			          ` + lineName + `, 
+			          ` + linePrice + `, 
+			          c.category,
+			          ` + lineStrikeThroughPrice + `,
+			          ` + lineBaseQuantity + `, 
+			          img.bucket, 
+			          img.path, 
+			          $2 AS quantity,
+			          iv.id,
+			          iv.quantity,
+			          iv.unit,
+			          ` + linePriceID + `
+			   FROM items
+			   LEFT JOIN categories c ON c.id = items.category
+			   ` + itemVariantJoin("$4") + `
+			   ` + lineImageJoin + `
+			   WHERE items.id = $3
+			   AND items.archived_at IS NULL
+			   AND ` + lineVariantFound("$4::int") + `
+			   AND ` + lineAvailable + `)`
+		result, err := tx.Exec(SQL, scheduleOrderID, itemInfo.Quantity, itemInfo.Id, itemInfo.VariantID)
 		if err != nil {
-			return err
+			return scheduleOrderID, err
 		}
-		affectedCount, err := result.RowsAffected()
+		rows, err := result.RowsAffected()
 		if err != nil {
-			return err
+			return scheduleOrderID, err
 		}
-		if affectedCount == 0 {
-			return sql.ErrNoRows
+		if rows == 0 {
+			return scheduleOrderID, orderedItemError(tx, itemInfo)
 		}
-		return nil
-	})
-	return scheduleOrderID, txError
+	}
+	return scheduleOrderID, updateScheduledOrderAmount(tx, scheduleOrderID, true)
+}
+
+//updateScheduledOrderAmount prices the scheduled items from the catalogue with the active offer and the delivery fee,
+//the way MoveScheduledOrders will price the orders it places, and stores the amount on the scheduled order
+func updateScheduledOrderAmount(tx *sqlx.Tx, scheduledOrderID int, enforceMin bool) error {
+	offer, err := GetActiveOffer()
+	if err != nil {
+		return err
+	}
+	query := `SELECT soi.id                       AS order_item_id,
+				   ` + linePrice + `              AS price,
+				   ` + lineStrikeThroughPrice + ` AS strikethrough_price,
+				   soi.quantity,
+				   ` + linePriceID + ` AS price_id,
+				   COALESCE(ts.rate, 0)         AS tax_rate,
+				   COALESCE(ts.inclusive, TRUE) AS tax_inclusive
+			FROM scheduled_ordered_items soi
+					 JOIN items ON items.id = soi.item_id
+					 JOIN categories c ON c.id = items.category
+					 ` + itemTaxSlabJoin + `
+					 ` + itemVariantJoin("soi.variant_id") + `
+			WHERE soi.order_id = $1
+			ORDER BY soi.id`
+	items := make([]models.ItemInfo, 0)
+	if err := tx.Select(&items, query, scheduledOrderID); err != nil {
+		return err
+	}
+	for i := range items {
+		query = `UPDATE scheduled_ordered_items SET price = $2, strikethrough_price = $3, discount = $4, price_id = $5 WHERE id = $1`
+		if _, err := tx.Exec(query, items[i].OrderItemID, items[i].Price, items[i].StrikeThroughPrice, offer.Discount, items[i].PriceID); err != nil {
+			return err
+		}
+		items[i].Discount = null.IntFrom(offer.Discount)
+		pricing.Item(&items[i])
+	}
+
+	delivery := orderDelivery{}
+	query = orderDeliverySelect + ` FROM scheduled_orders o ` + orderDeliveryJoin + ` WHERE o.id = $1`
+	if err := tx.Get(&delivery, query, scheduledOrderID); err != nil {
+		return err
+	}
+	fee, _, err := deliveryFee(tx, delivery, pricing.OrderValue(items), enforceMin)
+	if err != nil {
+		return err
+	}
+	return execAffectingOne(tx, `UPDATE scheduled_orders SET amount = $2 WHERE id = $1`, scheduledOrderID, pricing.OrderAmount(items, 0, fee))
 }
 
 // GetScheduledOrderById returns a scheduled order details from scheduledOrder id, user id
@@ -794,8 +910,12 @@ func MoveScheduledOrders() error {
 			}
 
 			// generate OTP for these newly moved order
+			otp, err := utils.GenerateOTP()
+			if err != nil {
+				return err
+			}
 			InsertOTPQuery := `INSERT INTO order_otp(order_id, otp) VALUES($1, $2)`
-			_, err = tx.Exec(InsertOTPQuery, newlyMovedOrder.OrderID, utils.GenerateOTP())
+			_, err = tx.Exec(InsertOTPQuery, newlyMovedOrder.OrderID, otp)
 			if err != nil {
 				return err
 			}
@@ -809,44 +929,46 @@ func MoveScheduledOrders() error {
 				}
 
 				// move item details
-				SQL := `INSERT INTO order_items(name, order_id, price, category, base_quantity,strikethrough_price, quantity, bucket, path, discount)
+				SQL := `INSERT INTO order_items(name, order_id, item_id, price, category, base_quantity,strikethrough_price, quantity, original_quantity, bucket, path, discount, tax_name, tax_rate, tax_inclusive, variant_id, unit_quantity, unit, price_id)
                  SELECT
                      soi.name,
                      $1 AS order_id,
-                     i.price,
+                     items.id,
+                     ` + linePrice + `,
                      soi.category,
                      soi.base_quantity,
-                     i.strikethrough_price,
+                     ` + lineStrikeThroughPrice + `,
+                     quantity,
                      quantity,
                      bucket,
                      path,
-                     $2 AS discount
+                     $2 AS discount,
+                     ts.name,
+                     COALESCE(ts.rate, 0),
+                     COALESCE(ts.inclusive, TRUE),
+                     soi.variant_id,
+                     soi.unit_quantity,
+                     soi.unit,
+                     ` + linePriceID + `
                  FROM scheduled_ordered_items soi
-                 JOIN items i ON soi.item_id = i.id
-                 AND soi.order_id = $3`
+                 JOIN items ON soi.item_id = items.id
+                 JOIN categories c ON c.id = items.category
+                 ` + itemTaxSlabJoin + `
+                 ` + itemVariantJoin("soi.variant_id") + `
+                 WHERE soi.order_id = $3`
 
 				_, err = tx.Exec(SQL, newlyMovedOrder.OrderID, offer.Discount, eligibleScheduledOrders[i].OrderID)
 				if err != nil {
 					return err
 				}
 
-				// calculate amount
-				SQL = `UPDATE orders
-                  SET amount = (SELECT (SUM((price - (price * discount/100)) * quantity)) FROM order_items WHERE order_id = $1) 
-                  WHERE id = $1`
-				result, err := tx.Exec(SQL, newlyMovedOrder.OrderID)
-				if err != nil {
-					return err
-				}
-				affectedCount, err := result.RowsAffected()
-				if err != nil {
+				// keep the amount shown on the schedule in line with what is charged
+				if err := updateScheduledOrderAmount(tx, eligibleScheduledOrders[i].OrderID, false); err != nil {
 					return err
 				}
-				if affectedCount == 0 {
-					return sql.ErrNoRows
-				}
 			}
-			return nil
+			// calculate amount
+			return applyDeliveryFee(tx, newlyMovedOrder.OrderID, false)
 		})
 		if txError != nil {
 			logrus.Errorf("failed to move an scheduled order having id: %d with error: %s, skipped!", eligibleScheduledOrders[i].OrderID, txError)
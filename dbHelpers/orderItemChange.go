@@ -0,0 +1,147 @@
+package dbHelpers
+
+import (
+	"fmt"
+	"github.com/RemoteState/yourdaily-server/database"
+	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/jmoiron/sqlx"
+)
+
+const orderItemChangeSelect = `SELECT oic.id,
+				   oic.order_id,
+				   oic.order_item_id,
+				   oi.name              AS item_name,
+				   oi.original_quantity,
+				   oic.change_type,
+				   oic.substitute_item_id,
+				   it.name              AS substitute_name,
+				   it.price             AS substitute_price,
+				   oic.quantity,
+				   oic.proposed_by,
+				   oic.status,
+				   oic.expires_at,
+				   oic.resolved_at,
+				   oic.created_at
+			FROM order_item_changes oic
+					 JOIN order_items oi ON oi.id = oic.order_item_id
+					 LEFT JOIN items it ON it.id = oic.substitute_item_id`
+
+//InsertOrderItemChange stores a change proposed by the staff, sql.ErrNoRows when the item already has a pending change
+func InsertOrderItemChange(change models.OrderItemChange) (int, error) {
+	query := `INSERT INTO order_item_changes (order_id, order_item_id, change_type, substitute_item_id, quantity, proposed_by, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6, now() + ($7 || ' second')::INTERVAL)
+			ON CONFLICT (order_item_id) WHERE status = 'pending' DO NOTHING
+			RETURNING id`
+	var id int
+	err := database.YourDailyDB.Get(&id, query, change.OrderID, change.OrderItemID, change.Type, change.SubstituteItemID,
+		change.Quantity, change.ProposedBy, int(models.ItemChangeTimeout.Seconds()))
+	return id, err
+}
+
+//GetOrderItemChange returns the change by its id
+func GetOrderItemChange(changeID int) (models.OrderItemChange, error) {
+	change := models.OrderItemChange{}
+	err := database.YourDailyDB.Get(&change, orderItemChangeSelect+` WHERE oic.id = $1`, changeID)
+	return change, err
+}
+
+//GetOrderItemChanges returns all the changes proposed for the order, latest first
+func GetOrderItemChanges(orderID int) ([]models.OrderItemChange, error) {
+	changes := make([]models.OrderItemChange, 0)
+	err := database.YourDailyDB.Select(&changes, orderItemChangeSelect+` WHERE oic.order_id = $1 ORDER BY oic.id DESC`, orderID)
+	return changes, err
+}
+
+//GetPendingItemChangeCount returns the number of changes of the order still waiting on the user
+func GetPendingItemChangeCount(orderID int) (int, error) {
+	query := `SELECT count(*) FROM order_item_changes WHERE order_id = $1 AND status = $2`
+	var count int
+	err := database.YourDailyDB.Get(&count, query, orderID, models.PendingItemChange)
+	return count, err
+}
+
+//GetExpiredItemChanges returns the pending changes the user didn't answer in time
+func GetExpiredItemChanges() ([]models.OrderItemChange, error) {
+	changes := make([]models.OrderItemChange, 0)
+	err := database.YourDailyDB.Select(&changes, orderItemChangeSelect+` WHERE oic.status = $1 AND oic.expires_at <= now()`,
+		models.PendingItemChange)
+	return changes, err
+}
+
+//ResolveOrderItemChange closes the pending change with the given status, applies it to the order items
+//and recalculates the amount of the order, sql.ErrNoRows when the change is not pending anymore
+func ResolveOrderItemChange(changeID int, status models.OrderItemChangeStatus) error {
+	return database.Tx(func(tx *sqlx.Tx) error {
+		query := `UPDATE order_item_changes
+				SET status      = $2,
+					resolved_at = now()
+				WHERE id = $1
+				  AND status = $3
+				RETURNING order_id, order_item_id, change_type, substitute_item_id, quantity`
+		change := models.OrderItemChange{}
+		if err := tx.Get(&change, query, changeID, status, models.PendingItemChange); err != nil {
+			return err
+		}
+		if status == models.RejectedItemChange {
+			return nil
+		}
+
+		switch {
+		case change.Type == models.ReduceItemChange:
+			if err := setOrderItemQuantity(tx, change.OrderItemID, change.Quantity); err != nil {
+				return err
+			}
+		case status == models.ApprovedItemChange:
+			if err := substituteOrderItem(tx, change); err != nil {
+				return err
+			}
+		default:
+			// the user didn't answer, the unavailable item is dropped without a substitute
+			if err := setOrderItemQuantity(tx, change.OrderItemID, 0); err != nil {
+				return err
+			}
+		}
+		return updateOrderAmount(tx, change.OrderID)
+	})
+}
+
+//setOrderItemQuantity sets the fulfilled quantity of the order item, original_quantity keeps what was ordered
+func setOrderItemQuantity(tx *sqlx.Tx, orderItemID, quantity int) error {
+	_, err := tx.Exec(`UPDATE order_items SET quantity = $2 WHERE id = $1`, orderItemID, quantity)
+	return err
+}
+
+//substituteOrderItem drops the order item and adds the substitute priced from the catalogue with the same discount
+func substituteOrderItem(tx *sqlx.Tx, change models.OrderItemChange) error {
+	if err := setOrderItemQuantity(tx, change.OrderItemID, 0); err != nil {
+		return err
+	}
+	query := `INSERT INTO order_items(order_id,item_id,name,price,category,base_quantity,strikethrough_price,bucket,path,quantity,original_quantity,discount,substitute_for,tax_name,tax_rate,tax_inclusive,price_id) (
+				SELECT oi.order_id, items.id, items.name, items.price, c.category, items.base_quantity, items.strikethrough_price, img.bucket, img.path, $3, 0, oi.discount, oi.id,
+					   ts.name, COALESCE(ts.rate, 0), COALESCE(ts.inclusive, TRUE), ` + linePriceID + `
+				FROM items
+						 JOIN categories c ON c.id = items.category
+						 ` + itemTaxSlabJoin + `
+						 ` + itemVariantJoin("NULL") + `
+						 JOIN order_items oi ON oi.id = $1
+						 LEFT JOIN LATERAL (SELECT i.bucket, i.path
+											FROM item_images ii
+													 JOIN images i ON i.id = ii.image_id
+											WHERE ii.item_id = items.id
+											ORDER BY ii.id
+											LIMIT 1) img ON TRUE
+				WHERE items.id = $2
+				  AND items.archived_at IS NULL)`
+	result, err := tx.Exec(query, change.OrderItemID, change.SubstituteItemID, change.Quantity)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: %d", ErrItemNotFound, change.SubstituteItemID.Int)
+	}
+	return nil
+}
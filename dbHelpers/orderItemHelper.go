@@ -1,28 +1,163 @@
 package dbHelpers
 
 import (
+	"errors"
+	"fmt"
 	"github.com/RemoteState/yourdaily-server/database"
 	"github.com/RemoteState/yourdaily-server/firebase"
 	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/RemoteState/yourdaily-server/pricing"
+	"github.com/jmoiron/sqlx"
 	"github.com/sirupsen/logrus"
 )
 
+//ErrItemNotFound is returned when an ordered item is not in the catalogue
+var ErrItemNotFound = errors.New("item not found")
+
+//insertOrderItems snapshots the given catalogue items, or their variants, into the order with the discount applied,
+//ErrItemUnavailable when an item can't be ordered now unless anyAvailability is set for the items sold by the staff
+func insertOrderItems(tx *sqlx.Tx, orderID int, items []models.ItemInfo, discount int, anyAvailability bool) error {
+	query := `INSERT INTO order_items(order_id,item_id,name,price,category,base_quantity,strikethrough_price,bucket,path,quantity,original_quantity, discount,tax_name,tax_rate,tax_inclusive,variant_id,unit_quantity,unit,price_id) (
+				SELECT $1 AS order_id, items.id, ` + lineName + `, ` + linePrice + `, c.category, ` + lineBaseQuantity + `, ` + lineStrikeThroughPrice + `, img.bucket, img.path, $2 AS quantity, $2 AS original_quantity, $4 AS discount,
+					   ts.name, COALESCE(ts.rate, 0), COALESCE(ts.inclusive, TRUE), iv.id, iv.quantity, iv.unit, ` + linePriceID + `
+				FROM items
+						 JOIN categories c ON c.id = items.category
+						 ` + itemTaxSlabJoin + `
+						 ` + itemVariantJoin("$5") + `
+						 ` + lineImageJoin + `
+				WHERE items.id = $3
+				  AND items.archived_at IS NULL
+				  AND ` + lineVariantFound("$5::int") + `
+				  AND ($6 OR ` + lineAvailable + `))`
+
+	for _, item := range items {
+		result, err := tx.Exec(query, orderID, item.Quantity, item.Id, discount, item.VariantID, anyAvailability)
+		if err != nil {
+			return err
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return orderedItemError(tx, item)
+		}
+	}
+	return nil
+}
+
+//orderedItemError tells why the item, or its variant, could not be ordered, ErrItemUnavailable when it is still sold
+//and ErrItemNotFound otherwise
+func orderedItemError(tx *sqlx.Tx, item models.ItemInfo) error {
+	query := `SELECT EXISTS(SELECT 1
+						  FROM items
+								   ` + itemVariantJoin("$2") + `
+						  WHERE items.id = $1
+							AND items.archived_at IS NULL
+							AND ` + lineVariantFound("$2::int") + `)`
+	var sold bool
+	if err := tx.Get(&sold, query, item.Id, item.VariantID); err != nil {
+		return err
+	}
+	if sold {
+		return fmt.Errorf("%w: %d", ErrItemUnavailable, item.Id)
+	}
+	return fmt.Errorf("%w: %d", ErrItemNotFound, item.Id)
+}
+
+//itemTaxSlabJoin joins the tax slab of the item as ts, falling back to the one of its category c
+const itemTaxSlabJoin = `LEFT JOIN tax_slabs ts ON ts.id = COALESCE(items.tax_slab_id, c.tax_slab_id)`
+
+//updateOrderAmount prices the order items again and recalculates the amount of the order from them,
+//the coupon discount and the delivery fee stay as they were given when the order was placed
+func updateOrderAmount(tx *sqlx.Tx, orderID int) error {
+	items, err := priceOrderItems(tx, orderID)
+	if err != nil {
+		return err
+	}
+	order := struct {
+		CouponDiscount float32 `db:"coupon_discount"`
+		DeliveryFee    float32 `db:"delivery_fee"`
+	}{}
+	if err := tx.Get(&order, `SELECT coupon_discount, delivery_fee FROM orders WHERE id = $1`, orderID); err != nil {
+		return err
+	}
+	amount := pricing.OrderAmount(items, order.CouponDiscount, order.DeliveryFee)
+	_, err = tx.Exec(`UPDATE orders SET amount = $2 WHERE id = $1`, orderID, amount)
+	return err
+}
+
+//priceOrderItems prices the order items again and stores their tax breakup
+func priceOrderItems(tx *sqlx.Tx, orderID int) ([]models.ItemInfo, error) {
+	query := `SELECT id AS order_item_id, price, strikethrough_price, quantity, discount, tax_rate, tax_inclusive
+			FROM order_items
+			WHERE order_id = $1
+			ORDER BY id`
+	items := make([]models.ItemInfo, 0)
+	if err := tx.Select(&items, query, orderID); err != nil {
+		return items, err
+	}
+	for i := range items {
+		pricing.Item(&items[i])
+		query = `UPDATE order_items SET taxable_amount = $2, tax_amount = $3 WHERE id = $1`
+		if _, err := tx.Exec(query, items[i].OrderItemID, items[i].TaxableAmount, items[i].TaxAmount); err != nil {
+			return items, err
+		}
+	}
+	return items, nil
+}
+
+//replaceOrderItems replaces the items of the order, priced from the catalogue with the active offer, and recalculates
+//its delivery fee and amount
+func replaceOrderItems(tx *sqlx.Tx, orderID int, items []models.ItemInfo) error {
+	offer, err := GetActiveOffer()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM order_items WHERE order_id = $1`, orderID); err != nil {
+		return err
+	}
+	// the staff bill what they sold from the cart, whatever the catalogue shows
+	if err := insertOrderItems(tx, orderID, items, offer.Discount, true); err != nil {
+		return err
+	}
+	return applyDeliveryFee(tx, orderID, false)
+}
+
 func GetOrderItems(orderId int) ([]models.ItemInfo, error) {
+	return getOrderItems(database.YourDailyDB, orderId)
+}
+
+func getOrderItems(db sqlx.Queryer, orderId int) ([]models.ItemInfo, error) {
 	SQL := `SELECT
+				id AS order_item_id,
+				COALESCE(item_id, 0) AS item_id,
 				name,
 				category,
 				price,
 				base_quantity,
 				quantity,
+				original_quantity,
+				substitute_for,
+				tax_name,
+				tax_rate,
+				tax_inclusive,
+				taxable_amount,
+				tax_amount,
        			discount,
        			bucket,
        			path,
-       			strikethrough_price
+       			strikethrough_price,
+       			variant_id,
+       			unit_quantity,
+       			unit,
+       			price_id
 			 FROM order_items
-			 WHERE order_id = $1`
+			 WHERE order_id = $1
+			 ORDER BY id`
 
 	items := make([]models.ItemInfo, 0)
-	err := database.YourDailyDB.Select(&items, SQL, orderId)
+	err := sqlx.Select(db, &items, SQL, orderId)
 	for i := range items {
 		if items[i].Bucket.Valid {
 			imageInfo := models.Image{
@@ -47,7 +182,11 @@ func GetScheduledOrderItems(orderId int) ([]models.ItemInfo, error) {
 				base_quantity,
 				quantity,
        			bucket,
-       			path
+       			path,
+       			variant_id,
+       			unit_quantity,
+       			unit,
+       			price_id
 			 FROM scheduled_ordered_items
 			 WHERE order_id = $1`
 
@@ -0,0 +1,166 @@
+package dbHelpers
+
+import (
+	"database/sql"
+	"github.com/RemoteState/yourdaily-server/database"
+	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/RemoteState/yourdaily-server/utils"
+	"github.com/jmoiron/sqlx"
+	"github.com/volatiletech/null"
+)
+
+//GetOrderOTP returns the otp of the order along with its expiry and lock state
+func GetOrderOTP(orderID int) (models.OrderOTP, error) {
+	query := `SELECT order_id, otp, expires_at, attempts, locked_at, resend_count
+			FROM order_otp
+			WHERE order_id = $1`
+	otp := models.OrderOTP{}
+	err := database.YourDailyDB.Get(&otp, query, orderID)
+	return otp, err
+}
+
+//ArmOTP regenerates the otp of the order when it goes out for delivery and starts its validity
+func ArmOTP(orderID int) (int, error) {
+	otp, err := utils.GenerateOTP()
+	if err != nil {
+		return 0, err
+	}
+	query := `INSERT INTO order_otp (order_id, otp, expires_at)
+			VALUES ($1, $2, now() + ($3 || ' second')::INTERVAL)
+			ON CONFLICT (order_id) DO UPDATE
+				SET otp          = excluded.otp,
+					expires_at   = excluded.expires_at,
+					attempts     = 0,
+					locked_at    = NULL,
+					resend_count = 0,
+					updated_at   = now()`
+	_, err = database.YourDailyDB.Exec(query, orderID, otp, models.OTPValidity.Seconds())
+	return otp, err
+}
+
+//ResendOTP regenerates the otp on user request, returns sql.ErrNoRows when the otp is locked or out of resends
+func ResendOTP(orderID int) (models.OrderOTP, error) {
+	newOTP, err := utils.GenerateOTP()
+	if err != nil {
+		return models.OrderOTP{}, err
+	}
+	// failed attempts are kept so resending can't be used to get around the lock
+	query := `UPDATE order_otp
+			SET otp          = $2,
+				expires_at   = now() + ($3 || ' second')::INTERVAL,
+				resend_count = resend_count + 1,
+				updated_at   = now()
+			WHERE order_id = $1
+			  AND locked_at IS NULL
+			  AND resend_count < $4
+			RETURNING order_id, otp, expires_at, attempts, locked_at, resend_count`
+	otp := models.OrderOTP{}
+	err = database.YourDailyDB.Get(&otp, query, orderID, newOTP, models.OTPValidity.Seconds(), models.MaxOTPResends)
+	return otp, err
+}
+
+//RecordOTPAttempt audits a verification attempt, a failed one counts towards the lock.
+//Returns the otp state after the attempt.
+func RecordOTPAttempt(orderID, staffID int, success bool) (models.OrderOTP, error) {
+	otp := models.OrderOTP{}
+	txErr := database.Tx(func(tx *sqlx.Tx) error {
+		var err error
+		if success {
+			query := `SELECT order_id, otp, expires_at, attempts, locked_at, resend_count
+					FROM order_otp
+					WHERE order_id = $1`
+			err = tx.Get(&otp, query, orderID)
+		} else {
+			query := `UPDATE order_otp
+					SET attempts   = attempts + 1,
+						locked_at  = CASE WHEN attempts + 1 >= $2 THEN now() END,
+						updated_at = now()
+					WHERE order_id = $1
+					  AND locked_at IS NULL
+					RETURNING order_id, otp, expires_at, attempts, locked_at, resend_count`
+			err = tx.Get(&otp, query, orderID, models.MaxOTPAttempts)
+		}
+		if err != nil {
+			return err
+		}
+
+		query := `INSERT INTO order_otp_attempts (order_id, staff_id, success, locked) VALUES ($1, $2, $3, $4)`
+		_, err = tx.Exec(query, orderID, staffID, success, otp.LockedAt.Valid)
+		return err
+	})
+	return otp, txErr
+}
+
+//UnlockOTP clears the lock of an order owned by the store manager and issues a fresh otp
+func UnlockOTP(orderID, smID int) (int, error) {
+	newOTP, err := utils.GenerateOTP()
+	if err != nil {
+		return 0, err
+	}
+	query := `UPDATE order_otp oo
+			SET otp        = $3,
+				expires_at = now() + ($4 || ' second')::INTERVAL,
+				attempts   = 0,
+				locked_at  = NULL,
+				updated_at = now()
+			FROM orders o
+			WHERE o.id = oo.order_id
+			  AND oo.order_id = $1
+			  AND o.sm_id = $2
+			  AND oo.locked_at IS NOT NULL`
+	result, err := database.YourDailyDB.Exec(query, orderID, smID, newOTP, models.OTPValidity.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if rows == 0 {
+		return 0, sql.ErrNoRows
+	}
+	return newOTP, nil
+}
+
+//GetLockedOTPOrders returns the store manager's orders whose otp is locked
+func GetLockedOTPOrders(smID int) ([]models.LockedOTPOrder, error) {
+	query := `SELECT o.id    AS order_id,
+				   o.mode,
+				   u.name  AS user_name,
+				   o.staff_id,
+				   s.name  AS staff_name,
+				   a.address_data,
+				   oo.attempts,
+				   oo.locked_at
+			FROM order_otp oo
+					 JOIN orders o ON o.id = oo.order_id
+					 JOIN users u ON u.id = o.user_id
+					 JOIN address a ON a.id = o.address_id
+					 LEFT JOIN users s ON s.id = o.staff_id
+			WHERE o.sm_id = $1
+			  AND oo.locked_at IS NOT NULL
+			ORDER BY oo.locked_at DESC`
+	orders := make([]models.LockedOTPOrder, 0)
+	err := database.YourDailyDB.Select(&orders, query, smID)
+	return orders, err
+}
+
+//GetLockedOTPOrderCount returns the number of the store manager's orders whose otp is locked
+func GetLockedOTPOrderCount(smID int) (int, error) {
+	query := `SELECT count(*)
+			FROM order_otp oo
+					 JOIN orders o ON o.id = oo.order_id
+			WHERE o.sm_id = $1
+			  AND oo.locked_at IS NOT NULL`
+	var count int
+	err := database.YourDailyDB.Get(&count, query, smID)
+	return count, err
+}
+
+//GetOrderStoreManager returns the store manager the order belongs to
+func GetOrderStoreManager(orderID int) (null.Int, error) {
+	query := `SELECT sm_id FROM orders WHERE id = $1`
+	var smID null.Int
+	err := database.YourDailyDB.Get(&smID, query, orderID)
+	return smID, err
+}
@@ -12,19 +12,20 @@ func SelectAllActiveOrdersForStaff(staffID int, mode models.OrderMode) ([]models
 				   o.id as order_id,
 				   o.order_type,
 				   o.amount,
-				   u.phone,
 				   o.status as status,
 				   a.address_data,
 				   a.lat,
-				   a.long
+				   a.long,
+				   (o.mode = $5 or o.amount >= $6) as proof_required
 			from orders o
 					 join users u on u.id = o.user_id
 					 join address a on o.address_id = a.id
 			where o.staff_id = $1 and o.mode = $2::order_mode and (o.status=$3 or status = $4)
-			group by order_id,u.name,u.phone, o.id, o.order_type, a.address_data, a.lat, a.long,o.created_at order by o.created_at`
+			group by order_id,u.name, o.id, o.order_type, a.address_data, a.lat, a.long,o.created_at order by o.created_at`
 
 	nowOrder := make([]models.StaffOrder, 0)
-	err := database.YourDailyDB.Select(&nowOrder, query, staffID, mode, models.Accepted, models.OutForDelivery)
+	err := database.YourDailyDB.Select(&nowOrder, query, staffID, mode, models.Accepted, models.OutForDelivery,
+		models.CartMode, models.ProofRequiredAmount)
 	for i, order := range nowOrder {
 		nowOrder[i].Items, err = GetOrderItems(order.OrderId)
 		if err != nil {
@@ -79,22 +80,22 @@ func GetAllGuest() ([]models.UnapprovedStaff, error) {
 //GetOrderByID returns and object of order for given orderId assigned to given staffID
 func GetOrderByID(orderID, staffID int) (models.StaffOrder, error) {
 	query := `select u.name,
-       				u.phone as phone,
 					o.user_id as user_id,
 					o.id as order_id,
 					o.order_type,
 					a.address_data,
        				o.status,
 					a.lat,
-					a.long
+					a.long,
+					(o.mode = $3 or o.amount >= $4) as proof_required
 				from orders o
 						 join users u on u.id = o.user_id
 						 join address a on o.address_id = a.id
 				where  o.staff_id = $1 and o.id=$2
-				group by u.name, u.phone, o.user_id, o.id, o.order_type, a.address_data, o.status, a.lat, a.long`
+				group by u.name, o.user_id, o.id, o.order_type, a.address_data, o.status, a.lat, a.long`
 
 	orderDetails := models.StaffOrder{}
-	err := database.YourDailyDB.Get(&orderDetails, query, staffID, orderID)
+	err := database.YourDailyDB.Get(&orderDetails, query, staffID, orderID, models.CartMode, models.ProofRequiredAmount)
 	if err != nil {
 		return orderDetails, err
 	}
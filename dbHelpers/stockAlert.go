@@ -0,0 +1,202 @@
+package dbHelpers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/RemoteState/yourdaily-server/database"
+	"github.com/RemoteState/yourdaily-server/firebase"
+	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+	"github.com/volatiletech/null"
+	"time"
+)
+
+//ErrItemInStock is returned when an alert is set for an item that can be ordered now
+var ErrItemInStock = errors.New("item is in stock")
+
+//activeStockAlert is true for the alerts a of users still waiting
+const activeStockAlert = `a.notified_at IS NULL AND a.archived_at IS NULL`
+
+//SetStockAlert makes the user wait for the out of stock item, or the variant of it, setting it again does nothing,
+//ErrItemNotFound when there is no such item and ErrItemInStock when it can be ordered now
+func SetStockAlert(userID, itemID int, variantID null.Int) error {
+	var available bool
+	SQL := `SELECT ` + lineAvailable + `
+			FROM items
+					 ` + itemVariantJoin("$2::int") + `
+			WHERE items.id = $1
+			  AND items.archived_at IS NULL
+			  AND ` + lineVariantFound("$2::int")
+	if err := database.YourDailyDB.Get(&available, SQL, itemID, variantID); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrItemNotFound
+		}
+		return err
+	}
+	if available {
+		return ErrItemInStock
+	}
+
+	SQL = `INSERT INTO item_stock_alerts(item_id, variant_id, user_id)
+		   VALUES ($1, $2, $3)
+		   ON CONFLICT (item_id, COALESCE(variant_id, 0), user_id) WHERE notified_at IS NULL AND archived_at IS NULL DO NOTHING`
+	_, err := database.YourDailyDB.Exec(SQL, itemID, variantID, userID)
+	return err
+}
+
+//RemoveStockAlert stops the user waiting for the item, or the variant of it, sql.ErrNoRows when they were not
+func RemoveStockAlert(userID, itemID int, variantID null.Int) error {
+	SQL := `UPDATE item_stock_alerts a
+			SET archived_at = $4
+			WHERE a.user_id = $1
+			  AND a.item_id = $2
+			  AND a.variant_id IS NOT DISTINCT FROM $3
+			  AND ` + activeStockAlert
+	return execAffectingOne(database.YourDailyDB, SQL, userID, itemID, variantID, time.Now())
+}
+
+//GetStockAlerts returns the items the user waits for, newest first
+func GetStockAlerts(userID int) ([]models.StockAlert, error) {
+	alerts := make([]models.StockAlert, 0)
+	SQL := `SELECT a.id,
+				   a.item_id,
+				   a.variant_id,
+				   items.name AS item_name,
+				   iv.name    AS variant_name,
+				   ` + lineAvailable + ` AS in_stock,
+				   a.created_at
+			FROM item_stock_alerts a
+					 JOIN items ON items.id = a.item_id
+					 ` + itemVariantJoin("a.variant_id") + `
+			WHERE a.user_id = $1
+			  AND ` + activeStockAlert + `
+			  AND NOT ` + lineArchived + `
+			ORDER BY a.created_at DESC, a.id DESC`
+	err := database.YourDailyDB.Select(&alerts, SQL, userID)
+	return alerts, err
+}
+
+//GetStockDemand returns the out of stock items, and variants, users wait for, the most awaited first
+func GetStockDemand(offset, limit int) ([]models.StockDemand, error) {
+	demand := make([]models.StockDemand, 0)
+	SQL := `SELECT a.item_id,
+				   a.variant_id,
+				   items.name        AS item_name,
+				   iv.name           AS variant_name,
+				   count(*)          AS subscribers,
+				   min(a.created_at) AS waiting_from
+			FROM item_stock_alerts a
+					 JOIN items ON items.id = a.item_id
+					 ` + itemVariantJoin("a.variant_id") + `
+			WHERE ` + activeStockAlert + `
+			  AND NOT ` + lineArchived + `
+			  AND NOT (` + lineAvailable + `)
+			GROUP BY a.item_id, a.variant_id, items.name, iv.name
+			ORDER BY subscribers DESC, waiting_from, a.item_id
+			OFFSET $1 LIMIT $2`
+	err := database.YourDailyDB.Select(&demand, SQL, offset, limit)
+	return demand, err
+}
+
+//the sending of back in stock alerts, an alert is claimed while it is sent, a claim not released after
+//stockAlertClaimTimeout is taken as lost, and an alert not sent after maxStockAlertAttempts is given up
+const (
+	maxStockAlertAttempts  = 5
+	stockAlertClaimTimeout = "10 minutes"
+)
+
+//SendBackInStockAlerts notifies the users waiting for items that are back in stock. The alerts are claimed first so
+//they are sent once, each alert is marked notified as soon as the batch it is sent in succeeds and the alerts not
+//sent are released to be sent again next time, or archived once they failed maxStockAlertAttempts times
+func SendBackInStockAlerts() error {
+	SQL := `WITH claimed AS (
+				UPDATE item_stock_alerts a
+					SET sending_at = now(),
+						attempts   = a.attempts + 1
+					WHERE ` + activeStockAlert + `
+						AND a.attempts < $1
+						AND (a.sending_at IS NULL OR a.sending_at < now() - $2::interval)
+						AND EXISTS(SELECT 1
+								   FROM items
+											` + itemVariantJoin("a.variant_id") + `
+								   WHERE items.id = a.item_id
+									 AND ` + lineAvailable + `)
+					RETURNING a.id, a.item_id, a.variant_id, a.user_id)
+			SELECT claimed.id,
+				   claimed.item_id,
+				   claimed.variant_id,
+				   claimed.user_id,
+				   items.name || COALESCE(' ' || iv.name, '') AS name
+			FROM claimed
+					 JOIN items ON items.id = claimed.item_id
+					 ` + itemVariantJoin("claimed.variant_id") + `
+			ORDER BY claimed.item_id, claimed.variant_id NULLS FIRST, claimed.id`
+	alerts := make([]struct {
+		ID        int64    `db:"id"`
+		ItemID    int      `db:"item_id"`
+		VariantID null.Int `db:"variant_id"`
+		UserID    int64    `db:"user_id"`
+		Name      string   `db:"name"`
+	}, 0)
+	if err := database.YourDailyDB.Select(&alerts, SQL, maxStockAlertAttempts, stockAlertClaimTimeout); err != nil {
+		return err
+	}
+
+	var sendErr error
+	for start := 0; start < len(alerts); {
+		item := alerts[start]
+		pending := make(map[int64]int64)
+		userIDs := make([]int64, 0)
+		for ; start < len(alerts) && alerts[start].ItemID == item.ItemID && alerts[start].VariantID == item.VariantID; start++ {
+			pending[alerts[start].UserID] = alerts[start].ID
+			userIDs = append(userIDs, alerts[start].UserID)
+		}
+		err := firebase.BackInStockNotification(userIDs, item.ItemID, item.VariantID, item.Name, func(sentTo []int64) error {
+			alertIDs := make(pq.Int64Array, 0, len(sentTo))
+			for _, userID := range sentTo {
+				alertIDs = append(alertIDs, pending[userID])
+			}
+			if _, err := database.YourDailyDB.Exec(`UPDATE item_stock_alerts SET notified_at = now(), sending_at = NULL WHERE id = ANY ($1)`, alertIDs); err != nil {
+				return err
+			}
+			for _, userID := range sentTo {
+				delete(pending, userID)
+			}
+			return nil
+		})
+		if err == nil {
+			continue
+		}
+		sendErr = fmt.Errorf("item %d: %w", item.ItemID, err)
+		alertIDs := make(pq.Int64Array, 0, len(pending))
+		for _, alertID := range pending {
+			alertIDs = append(alertIDs, alertID)
+		}
+		if err := releaseStockAlerts(alertIDs); err != nil {
+			return err
+		}
+	}
+	return sendErr
+}
+
+//releaseStockAlerts releases the claimed alerts which could not be sent, archiving the ones which ran out of attempts
+func releaseStockAlerts(alertIDs pq.Int64Array) error {
+	SQL := `UPDATE item_stock_alerts
+			SET sending_at  = NULL,
+				archived_at = CASE WHEN attempts >= $2 THEN now() END
+			WHERE id = ANY ($1)
+			RETURNING archived_at IS NOT NULL`
+	givenUp := make([]bool, 0, len(alertIDs))
+	if err := database.YourDailyDB.Select(&givenUp, SQL, alertIDs, maxStockAlertAttempts); err != nil {
+		return err
+	}
+	for _, archived := range givenUp {
+		if archived {
+			logrus.Errorf("releaseStockAlerts: gave up back in stock alerts %v after %d attempts", alertIDs, maxStockAlertAttempts)
+			break
+		}
+	}
+	return nil
+}
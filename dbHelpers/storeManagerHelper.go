@@ -483,6 +483,7 @@ func GetOrderInDateRange(startDate time.Time, endDate time.Time, smID int) ([]mo
 				   a.address_data AS user_address,
 				   o.delivery_time,
 				   o.order_type,
+				   COALESCE(o.amount, 0) AS amount,
 				   o.created_at
 			FROM orders o
 					 JOIN address a ON a.id = o.address_id
@@ -0,0 +1,94 @@
+package dbHelpers
+
+import (
+	"database/sql"
+	"github.com/RemoteState/yourdaily-server/database"
+	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/volatiletech/null"
+)
+
+//GetTaxSlabs returns all the active tax slabs
+func GetTaxSlabs() ([]models.TaxSlab, error) {
+	query := `SELECT id, name, rate, inclusive, created_at, updated_at
+			FROM tax_slabs
+			WHERE archived_at IS NULL
+			ORDER BY rate, id`
+	slabs := make([]models.TaxSlab, 0)
+	err := database.YourDailyDB.Select(&slabs, query)
+	return slabs, err
+}
+
+//GetTaxSlabByID returns the active tax slab
+func GetTaxSlabByID(slabID int) (models.TaxSlab, error) {
+	query := `SELECT id, name, rate, inclusive, created_at, updated_at
+			FROM tax_slabs
+			WHERE archived_at IS NULL
+			  AND id = $1`
+	slab := models.TaxSlab{}
+	err := database.YourDailyDB.Get(&slab, query, slabID)
+	return slab, err
+}
+
+//InsertTaxSlab creates a tax slab
+func InsertTaxSlab(slab models.TaxSlab) (int, error) {
+	query := `INSERT INTO tax_slabs (name, rate, inclusive) VALUES ($1, $2, $3) RETURNING id`
+	var id int
+	err := database.YourDailyDB.Get(&id, query, slab.Name, slab.Rate, slab.Inclusive)
+	return id, err
+}
+
+//UpdateTaxSlab updates the tax slab, orders placed earlier keep the tax they were placed with
+func UpdateTaxSlab(slab models.TaxSlab) error {
+	query := `UPDATE tax_slabs
+			SET name       = $2,
+				rate       = $3,
+				inclusive  = $4,
+				updated_at = now()
+			WHERE id = $1
+			  AND archived_at IS NULL`
+	return execAffectingOne(database.YourDailyDB, query, slab.ID, slab.Name, slab.Rate, slab.Inclusive)
+}
+
+//ArchiveTaxSlab archives the tax slab and unassigns it from its categories and items
+func ArchiveTaxSlab(slabID int) error {
+	return database.Tx(func(tx *sqlx.Tx) error {
+		err := execAffectingOne(tx, `UPDATE tax_slabs SET archived_at = now() WHERE id = $1 AND archived_at IS NULL`, slabID)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE categories SET tax_slab_id = NULL WHERE tax_slab_id = $1`, slabID); err != nil {
+			return err
+		}
+		_, err = tx.Exec(`UPDATE items SET tax_slab_id = NULL WHERE tax_slab_id = $1`, slabID)
+		return err
+	})
+}
+
+//SetCategoryTaxSlab assigns the tax slab to the category, a null slab removes it
+func SetCategoryTaxSlab(categoryID int, slabID null.Int) error {
+	query := `UPDATE categories SET tax_slab_id = $2, updated_at = now() WHERE id = $1 AND archived_at IS NULL`
+	return execAffectingOne(database.YourDailyDB, query, categoryID, slabID)
+}
+
+//SetItemTaxSlab overrides the tax slab of the item's category, a null slab falls back to the category
+func SetItemTaxSlab(itemID int, slabID null.Int) error {
+	query := `UPDATE items SET tax_slab_id = $2, updated_at = now() WHERE id = $1 AND archived_at IS NULL`
+	return execAffectingOne(database.YourDailyDB, query, itemID, slabID)
+}
+
+//execAffectingOne runs the update and returns sql.ErrNoRows when it didn't match any row
+func execAffectingOne(db sqlx.Execer, query string, args ...interface{}) error {
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
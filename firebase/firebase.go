@@ -11,6 +11,7 @@ import (
 	"github.com/google/uuid"
 	"golang.org/x/oauth2/google"
 	"io"
+	"net/url"
 	"os"
 	"strings"
 	"time"
@@ -178,13 +179,19 @@ func UploadToFirebase(file []byte, downloadedFileName string) (string, error) {
 
 // GetURL returns public URL of given bucket and path of an image
 func GetURL(imageInfo *models.Image) (string, error) {
+	now := time.Now()
+	cacheKey := imageInfo.Bucket + "/" + imageInfo.Path
+	if url, ok := getCachedURL(cacheKey, now); ok {
+		return url, nil
+	}
+
 	cfg, err := google.JWTConfigFromJSON([]byte(fireKey))
 	if err != nil {
 		return "", err
 	}
 
 	method := "GET"
-	expires := time.Now().Add(time.Minute * 60)
+	expires := now.Add(signedURLExpiry)
 
 	url, err := storage.SignedURL(imageInfo.Bucket, imageInfo.Path, &storage.SignedURLOptions{
 		GoogleAccessID: cfg.Email,
@@ -195,5 +202,19 @@ func GetURL(imageInfo *models.Image) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	setCachedURL(cacheKey, url, now)
 	return url, nil
 }
+
+// ImageFromURL returns the bucket and path of an image from a URL given by GetURL, false for any other URL
+func ImageFromURL(imageURL string) (models.Image, bool) {
+	parsed, err := url.Parse(imageURL)
+	if err != nil || parsed.Host != "storage.googleapis.com" {
+		return models.Image{}, false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(parsed.Path, "/"), "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return models.Image{}, false
+	}
+	return models.Image{Bucket: parts[0], Path: parts[1]}, true
+}
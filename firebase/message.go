@@ -8,6 +8,7 @@ import (
 	"github.com/RemoteState/yourdaily-server/models"
 	"github.com/lib/pq"
 	"github.com/sirupsen/logrus"
+	"github.com/volatiletech/null"
 	"time"
 )
 
@@ -18,6 +19,11 @@ const (
 	MessageTypeOrderStatusUpdate       = "OrderStatusUpdateNotification"
 	MessageTypeChatNotification        = "ChatNotification"
 	MessageTypeScheduledOrderCancelled = "ScheduledOrderCancelled"
+	MessageTypeOTPLocked               = "OTPLocked"
+	MessageTypeOrderBill               = "OrderBill"
+	MessageTypeOrderItemChange         = "OrderItemChange"
+	MessageTypeBackInStock             = "BackInStock"
+	MessageTypeIncomingCall            = "IncomingCall"
 )
 
 func SendNewOrderNotificationToStaff(userIds []int64, orderId int, lat, long float64, addressData string) error {
@@ -170,3 +176,222 @@ func ScheduledOrderCanceledNotification(userID, orderID int, message string) {
 	}
 	logrus.Infof("notification chat message succesfull to user %d with message %+v", userID, message)
 }
+
+//OTPLockedNotification alerts the store manager that the delivery otp of an order got locked
+func OTPLockedNotification(smID, orderID, attempts int) {
+	logrus.Infof("sending otp locked notification to %+v", smID)
+
+	// language=SQL
+	SQL := `
+	SELECT token
+	FROM fcm_token
+	WHERE user_id = $1
+`
+	var registrationToken string
+	database.YourDailyDB.Get(&registrationToken, SQL, smID)
+	if registrationToken == "" {
+		logrus.Errorf("no token found for userID  %d orderid = %d", smID, orderID)
+		return
+	}
+
+	payLoad := &messaging.MulticastMessage{
+		Data: map[string]string{
+			"type":     MessageTypeOTPLocked,
+			"title":    "Delivery OTP locked",
+			"message":  fmt.Sprintf("OTP of order %d locked after %d wrong attempts", orderID, attempts),
+			"attempts": fmt.Sprintf("%d", attempts),
+			"orderId":  fmt.Sprintf("%d", orderID)},
+		Tokens: []string{registrationToken},
+	}
+
+	_, err := FirebaseClient.SendMulticast(context.Background(), payLoad)
+	if err != nil {
+		logrus.Errorf("OTPLockedNotification: Error while sending push notifications message %+v and error %v", payLoad, err)
+		return
+	}
+	logrus.Infof("otp locked notification succesfull to store manager %d for order %d", smID, orderID)
+}
+
+//OrderBillNotification lets the user review the bill submitted by the staff, or the staff know the user rejected it
+func OrderBillNotification(userID, orderID int, title, message string) {
+	logrus.Infof("sending order bill notification to %+v", userID)
+
+	// language=SQL
+	SQL := `
+	SELECT token
+	FROM fcm_token
+	WHERE user_id = $1
+`
+	var registrationToken string
+	database.YourDailyDB.Get(&registrationToken, SQL, userID)
+	if registrationToken == "" {
+		logrus.Errorf("no token found for userID  %d orderid = %d", userID, orderID)
+		return
+	}
+
+	payLoad := &messaging.MulticastMessage{
+		Data: map[string]string{
+			"type":    MessageTypeOrderBill,
+			"title":   title,
+			"message": message,
+			"orderId": fmt.Sprintf("%d", orderID)},
+		Tokens: []string{registrationToken},
+	}
+
+	_, err := FirebaseClient.SendMulticast(context.Background(), payLoad)
+	if err != nil {
+		logrus.Errorf("OrderBillNotification: Error while sending push notifications message %+v and error %v", payLoad, err)
+		return
+	}
+	logrus.Infof("order bill notification succesfull to user %d for order %d", userID, orderID)
+}
+
+//OrderItemChangeNotification lets the user answer an item change proposed by the staff, or the staff know its outcome
+func OrderItemChangeNotification(userID, orderID, changeID int, title, message string) {
+	logrus.Infof("sending order item change notification to %+v", userID)
+
+	// language=SQL
+	SQL := `
+	SELECT token
+	FROM fcm_token
+	WHERE user_id = $1
+`
+	var registrationToken string
+	database.YourDailyDB.Get(&registrationToken, SQL, userID)
+	if registrationToken == "" {
+		logrus.Errorf("no token found for userID  %d orderid = %d", userID, orderID)
+		return
+	}
+
+	payLoad := &messaging.MulticastMessage{
+		Data: map[string]string{
+			"type":     MessageTypeOrderItemChange,
+			"title":    title,
+			"message":  message,
+			"changeId": fmt.Sprintf("%d", changeID),
+			"orderId":  fmt.Sprintf("%d", orderID)},
+		Tokens: []string{registrationToken},
+	}
+
+	_, err := FirebaseClient.SendMulticast(context.Background(), payLoad)
+	if err != nil {
+		logrus.Errorf("OrderItemChangeNotification: Error while sending push notifications message %+v and error %v", payLoad, err)
+		return
+	}
+	logrus.Infof("order item change notification succesfull to user %d for order %d", userID, orderID)
+}
+
+//maxMulticastTokens is the most tokens a multicast message can be sent to
+const maxMulticastTokens = 500
+
+//BackInStockNotification tells the users waiting for the item, or the variant of it, that it is back in stock, sent is
+//called with the users of each batch as soon as it is sent, first with the users who have no token to send to, and
+//the error is set when a batch could not be sent, the users of the batches after it are not sent to
+func BackInStockNotification(userIDs []int64, itemID int, variantID null.Int, name string, sent func(userIDs []int64) error) error {
+	logrus.Infof("sending back in stock notification to %+v", userIDs)
+
+	// language=SQL
+	SQL := `
+	SELECT user_id, token
+	FROM fcm_token
+	WHERE user_id = ANY ($1)
+	  AND token IS NOT NULL
+	ORDER BY user_id
+`
+	tokens := make([]struct {
+		UserID int64  `db:"user_id"`
+		Token  string `db:"token"`
+	}, 0)
+	if err := database.YourDailyDB.Select(&tokens, SQL, pq.Int64Array(userIDs)); err != nil {
+		logrus.Errorf("BackInStockNotification: unable to get tokens of users %+v error %v", userIDs, err)
+		return err
+	}
+	withToken := make(map[int64]bool, len(tokens))
+	for _, token := range tokens {
+		withToken[token.UserID] = true
+	}
+	withoutToken := make([]int64, 0)
+	for _, userID := range userIDs {
+		if !withToken[userID] {
+			withoutToken = append(withoutToken, userID)
+		}
+	}
+	if len(withoutToken) > 0 {
+		if err := sent(withoutToken); err != nil {
+			return err
+		}
+	}
+
+	data := map[string]string{
+		"type":    MessageTypeBackInStock,
+		"title":   "Back in stock",
+		"message": fmt.Sprintf("%s is back in stock", name),
+		"itemId":  fmt.Sprintf("%d", itemID),
+	}
+	if variantID.Valid {
+		data["variantId"] = fmt.Sprintf("%d", variantID.Int)
+	}
+	// a user has a single token so a batch of tokens is a batch of users
+	for start := 0; start < len(tokens); start += maxMulticastTokens {
+		end := start + maxMulticastTokens
+		if end > len(tokens) {
+			end = len(tokens)
+		}
+		batchUserIDs := make([]int64, 0, end-start)
+		payLoad := &messaging.MulticastMessage{
+			Data:   data,
+			Tokens: make([]string, 0, end-start),
+		}
+		for _, token := range tokens[start:end] {
+			batchUserIDs = append(batchUserIDs, token.UserID)
+			payLoad.Tokens = append(payLoad.Tokens, token.Token)
+		}
+		if _, err := FirebaseClient.SendMulticast(context.Background(), payLoad); err != nil {
+			logrus.Errorf("BackInStockNotification: Error while sending push notifications message %+v and error %v", payLoad, err)
+			return err
+		}
+		if err := sent(batchUserIDs); err != nil {
+			return err
+		}
+	}
+	logrus.Infof("back in stock notification succesfull to users %+v for item %d", userIDs, itemID)
+	return nil
+}
+
+//IncomingCallNotification rings the callee of an in-app call placed by the other participant of the order
+func IncomingCallNotification(calleeID, callerID, orderID int) error {
+	logrus.Infof("sending incoming call notification to %+v", calleeID)
+
+	// language=SQL
+	SQL := `
+	SELECT token
+	FROM fcm_token
+	WHERE user_id = $1
+`
+	var registrationToken string
+	database.YourDailyDB.Get(&registrationToken, SQL, calleeID)
+	if registrationToken == "" {
+		return fmt.Errorf("no token found for userID %d", calleeID)
+	}
+
+	payLoad := &messaging.MulticastMessage{
+		Data: map[string]string{
+			"type":     MessageTypeIncomingCall,
+			"title":    "Incoming call",
+			"message":  fmt.Sprintf("Call about order %d", orderID),
+			"callerId": fmt.Sprintf("%d", callerID),
+			"orderId":  fmt.Sprintf("%d", orderID)},
+		Tokens: []string{registrationToken},
+	}
+
+	resp, err := FirebaseClient.SendMulticast(context.Background(), payLoad)
+	if err != nil {
+		logrus.Errorf("IncomingCallNotification: Error while sending push notifications message %+v and error %v", payLoad, err)
+		return err
+	}
+	if resp.FailureCount > 0 {
+		return fmt.Errorf("unable to reach userID %d", calleeID)
+	}
+	logrus.Infof("incoming call notification succesfull to user %d for order %d", calleeID, orderID)
+	return nil
+}
@@ -0,0 +1,60 @@
+package firebase
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// signedURLExpiry is how long a signed url is valid
+	signedURLExpiry = 60 * time.Minute
+	// signedURLReuse is how long a signed url is handed out again, so every url handed out stays valid
+	// for at least signedURLExpiry - signedURLReuse
+	signedURLReuse = 30 * time.Minute
+	// maxCachedURLs is the most urls cached, from it expired urls are dropped, or the oldest when none expired
+	maxCachedURLs = 10000
+)
+
+type cachedURL struct {
+	url      string
+	signedAt time.Time
+}
+
+//urlCache keeps the signed urls of images by bucket and path so the catalogue is not signed again on every request
+var urlCache = struct {
+	sync.RWMutex
+	urls map[string]cachedURL
+}{urls: make(map[string]cachedURL)}
+
+func getCachedURL(key string, now time.Time) (string, bool) {
+	urlCache.RLock()
+	defer urlCache.RUnlock()
+	cached, ok := urlCache.urls[key]
+	if !ok || now.Sub(cached.signedAt) >= signedURLReuse {
+		return "", false
+	}
+	return cached.url, true
+}
+
+func setCachedURL(key, url string, signedAt time.Time) {
+	urlCache.Lock()
+	defer urlCache.Unlock()
+	if _, ok := urlCache.urls[key]; !ok && len(urlCache.urls) >= maxCachedURLs {
+		var oldestKey string
+		var oldest time.Time
+		for cachedKey, cached := range urlCache.urls {
+			if signedAt.Sub(cached.signedAt) >= signedURLReuse {
+				delete(urlCache.urls, cachedKey)
+				continue
+			}
+			if oldestKey == "" || cached.signedAt.Before(oldest) {
+				oldestKey, oldest = cachedKey, cached.signedAt
+			}
+		}
+		// every url is still fresh, the oldest makes room so the cache never grows past maxCachedURLs
+		if len(urlCache.urls) >= maxCachedURLs {
+			delete(urlCache.urls, oldestKey)
+		}
+	}
+	urlCache.urls[key] = cachedURL{url: url, signedAt: signedAt}
+}
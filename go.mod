@@ -13,8 +13,10 @@ require (
 	github.com/gogo/protobuf v1.3.2 // indirect
 	github.com/golang-migrate/migrate/v4 v4.14.1
 	github.com/google/uuid v1.3.0
+	github.com/gorilla/websocket v1.4.2
 	github.com/hashicorp/go-multierror v1.1.1 // indirect
 	github.com/jmoiron/sqlx v1.3.4
+	github.com/jung-kurt/gofpdf v1.16.2
 	github.com/lib/pq v1.10.2
 	github.com/pkg/errors v0.9.1
 	github.com/robfig/cron v1.2.0
@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/RemoteState/yourdaily-server/dbHelpers"
+	"github.com/RemoteState/yourdaily-server/middlewares"
+	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/RemoteState/yourdaily-server/pricing"
+	"github.com/RemoteState/yourdaily-server/promotions"
+	"github.com/RemoteState/yourdaily-server/utils"
+	"github.com/go-chi/chi"
+	"github.com/volatiletech/null"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//GetCart GET /api/user/cart returns the cart of the user
+func GetCart(w http.ResponseWriter, r *http.Request) {
+	userID := middlewares.UserContext(r).ID
+	respondCart(w, userID)
+}
+
+//SetCartItem PUT /api/user/cart/item/{id} adds the item, or the variant given, to the cart or sets its quantity,
+//quantity 0 removes it
+func SetCartItem(w http.ResponseWriter, r *http.Request) {
+	userID := middlewares.UserContext(r).ID
+	itemID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid item id")
+		return
+	}
+	reqBody := struct {
+		VariantID null.Int `json:"variantId"`
+		Quantity  int      `json:"quantity"`
+	}{}
+	if err := utils.ParseBody(r.Body, &reqBody); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "unable to parse req body")
+		return
+	}
+	if reqBody.Quantity < 0 {
+		err := fmt.Errorf("quantity can't be negative")
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
+
+	if reqBody.Quantity == 0 {
+		err = dbHelpers.RemoveCartItem(userID, itemID, reqBody.VariantID)
+		if err == sql.ErrNoRows {
+			err = nil
+		}
+	} else {
+		err = dbHelpers.SetCartItem(userID, itemID, reqBody.VariantID, reqBody.Quantity)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, dbHelpers.ErrItemNotFound):
+			utils.RespondError(w, http.StatusNotFound, err, err.Error())
+		case errors.Is(err, dbHelpers.ErrItemUnavailable):
+			utils.RespondError(w, http.StatusConflict, err, err.Error())
+		default:
+			utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to update cart")
+		}
+		return
+	}
+	respondCart(w, userID)
+}
+
+//RemoveCartItem DELETE /api/user/cart/item/{id}?variantId= removes the item, or its variant, from the cart
+func RemoveCartItem(w http.ResponseWriter, r *http.Request) {
+	userID := middlewares.UserContext(r).ID
+	itemID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid item id")
+		return
+	}
+	var variantID null.Int
+	if variant := r.URL.Query().Get("variantId"); variant != "" {
+		id, err := strconv.Atoi(variant)
+		if err != nil {
+			utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid variant id")
+			return
+		}
+		variantID = null.IntFrom(id)
+	}
+	if err := dbHelpers.RemoveCartItem(userID, itemID, variantID); err != nil {
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, http.StatusNotFound, err, "item not in cart")
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to update cart")
+		return
+	}
+	respondCart(w, userID)
+}
+
+//ClearCart DELETE /api/user/cart empties the cart
+func ClearCart(w http.ResponseWriter, r *http.Request) {
+	userID := middlewares.UserContext(r).ID
+	if err := dbHelpers.ClearCart(userID); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to clear cart")
+		return
+	}
+	respondCart(w, userID)
+}
+
+//UpdateCartDetails PUT /api/user/cart attaches the address and the coupon to the cart, null detaches them
+func UpdateCartDetails(w http.ResponseWriter, r *http.Request) {
+	userID := middlewares.UserContext(r).ID
+	details := models.CartDetails{}
+	if err := utils.ParseBody(r.Body, &details); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "unable to parse req body")
+		return
+	}
+	if details.AddressID.Valid {
+		if _, err := dbHelpers.SelectAddressWithID(userID, details.AddressID.Int, false); err != nil {
+			utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid address id")
+			return
+		}
+	}
+	details.CouponCode.String = strings.TrimSpace(details.CouponCode.String)
+	details.CouponCode.Valid = details.CouponCode.String != ""
+	if details.CouponCode.Valid {
+		coupon, err := dbHelpers.GetCouponByCode(details.CouponCode.String)
+		if err != nil {
+			if err == promotions.ErrInvalidCoupon {
+				utils.RespondError(w, http.StatusNotFound, err, err.Error())
+				return
+			}
+			utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch coupon")
+			return
+		}
+		details.CouponCode.String = coupon.Code
+	}
+
+	if err := dbHelpers.UpdateCartDetails(userID, details); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to update cart")
+		return
+	}
+	respondCart(w, userID)
+}
+
+//CheckoutCart POST /api/user/cart/checkout places an order now with the cart and empties it
+func CheckoutCart(w http.ResponseWriter, r *http.Request) {
+	userID := middlewares.UserContext(r).ID
+	cart, ok := cartToOrder(w, userID)
+	if !ok {
+		return
+	}
+	smID, ok := storeManagerForOrder(w, userID, cart.AddressID.Int)
+	if !ok {
+		return
+	}
+
+	newOrder := models.Order{
+		UserID:        userID,
+		Mode:          models.DeliveryMode,
+		AddressID:     cart.AddressID.Int,
+		DeliveryTime:  time.Now().Format(time.RFC3339Nano),
+		StoreMangerID: smID,
+	}
+	orderID, err := dbHelpers.CheckoutCart(newOrder)
+	if err != nil {
+		respondOrderError(w, err)
+		return
+	}
+	go FindAndPing(newOrder.Mode, newOrder.AddressID, newOrder.UserID, orderID)
+
+	utils.RespondJSON(w, http.StatusOK, struct {
+		OrderID int `json:"order_id"`
+	}{orderID})
+}
+
+//ScheduleCart POST /api/user/cart/schedule creates a scheduled order with the cart and empties it
+func ScheduleCart(w http.ResponseWriter, r *http.Request) {
+	userID := middlewares.UserContext(r).ID
+	newOrder := models.ScheduledOrder{}
+	if err := utils.ParseBody(r.Body, &newOrder); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, "Failed to decode request body")
+		return
+	}
+	if len(newOrder.Weekdays) == 0 {
+		err := fmt.Errorf("weekdays are required")
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
+	cart, ok := cartToOrder(w, userID)
+	if !ok {
+		return
+	}
+	if cart.CouponCode.Valid {
+		err := fmt.Errorf("coupons can't be applied to scheduled orders, remove the coupon from the cart")
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
+	smID, ok := storeManagerForOrder(w, userID, cart.AddressID.Int)
+	if !ok {
+		return
+	}
+
+	newOrder.UserID, newOrder.Mode = userID, models.DeliveryMode
+	newOrder.AddressID, newOrder.StoreMangerID = cart.AddressID.Int, smID
+	scheduledOrderID, err := dbHelpers.ScheduleCart(newOrder)
+	if err != nil {
+		respondOrderError(w, err)
+		return
+	}
+	scheduledOrder, err := dbHelpers.GetScheduledOrderById(scheduledOrderID, userID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, "Failed to get scheduled order details")
+		return
+	}
+	utils.RespondJSON(w, http.StatusCreated, scheduledOrder)
+}
+
+//cartToOrder returns the cart when it has items and an address to be ordered
+func cartToOrder(w http.ResponseWriter, userID int) (models.Cart, bool) {
+	cart, err := dbHelpers.GetCart(userID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch cart")
+		return cart, false
+	}
+	if len(cart.Items) == 0 {
+		utils.RespondError(w, http.StatusBadRequest, dbHelpers.ErrCartEmpty, dbHelpers.ErrCartEmpty.Error())
+		return cart, false
+	}
+	if !cart.AddressID.Valid {
+		err := fmt.Errorf("add an address to the cart")
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return cart, false
+	}
+	return cart, true
+}
+
+//storeManagerForOrder returns the store manager delivering to the address if the user is allowed to order
+func storeManagerForOrder(w http.ResponseWriter, userID, addressID int) (int, bool) {
+	flagCount, _, err := dbHelpers.GetFlagCountAndLastOrderStatus(userID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), err.Error())
+		return 0, false
+	}
+	if flagCount >= models.MaxFlagCount {
+		err = fmt.Errorf("Account Blocked")
+		utils.RespondError(w, http.StatusNotAcceptable, err, err.Error(), err.Error())
+		return 0, false
+	}
+	addressLocation, err := dbHelpers.SelectAddressWithID(userID, addressID, false)
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid address id")
+		return 0, false
+	}
+	smID, err := dbHelpers.StoreManagerNearMe(addressLocation.Lat, addressLocation.Long)
+	if err != nil {
+		utils.RespondError(w, http.StatusNotAcceptable, err, err.Error(), err.Error())
+		return 0, false
+	}
+	return smID, true
+}
+
+func respondOrderError(w http.ResponseWriter, err error) {
+	switch {
+	case err == dbHelpers.ErrCartEmpty:
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+	case errors.Is(err, dbHelpers.ErrItemUnavailable), errors.Is(err, dbHelpers.ErrItemNotFound):
+		utils.RespondError(w, http.StatusConflict, err, err.Error())
+	case promotions.IsCouponError(err), errors.Is(err, pricing.ErrMinOrderValue):
+		utils.RespondError(w, http.StatusUnprocessableEntity, err, err.Error())
+	default:
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to place order")
+	}
+}
+
+func respondCart(w http.ResponseWriter, userID int) {
+	cart, err := dbHelpers.GetCart(userID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch cart")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, cart)
+}
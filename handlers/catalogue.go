@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"github.com/RemoteState/yourdaily-server/catalogue"
+	"github.com/RemoteState/yourdaily-server/dbHelpers"
+	"github.com/RemoteState/yourdaily-server/firebase"
+	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/RemoteState/yourdaily-server/utils"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+)
+
+//ImportCatalogue POST /api/store-manager/item/import?dryRun=true creates and updates items from the CSV file sent as
+//file, every row is checked first and nothing is applied when one of them is invalid or on a dry run
+func ImportCatalogue(w http.ResponseWriter, r *http.Request) {
+	dryRun := false
+	if value := r.URL.Query().Get("dryRun"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			utils.RespondError(w, http.StatusBadRequest, err, "invalid value for dryRun")
+			return
+		}
+		dryRun = parsed
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, models.MaxCatalogueImportSize)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, "a csv file is required as file")
+		return
+	}
+	defer file.Close()
+
+	rows, rowErrors, err := catalogue.Parse(file)
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
+	result := models.CatalogueImport{DryRun: dryRun, CreatedCategories: make([]string, 0), Errors: rowErrors}
+	if len(result.Errors) == 0 {
+		result.Errors, err = setCatalogueImages(rows, false)
+		if err != nil {
+			utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to check images")
+			return
+		}
+	}
+	if len(result.Errors) > 0 {
+		respondCatalogueImport(w, result)
+		return
+	}
+
+	// try the rows before any image is downloaded
+	result, err = dbHelpers.ImportCatalogue(rows, true)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to import catalogue")
+		return
+	}
+	if len(result.Errors) > 0 || dryRun {
+		respondCatalogueImport(w, result)
+		return
+	}
+
+	result.Errors, err = setCatalogueImages(rows, true)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to upload images")
+		return
+	}
+	if len(result.Errors) > 0 {
+		respondCatalogueImport(w, result)
+		return
+	}
+	result, err = dbHelpers.ImportCatalogue(rows, false)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to import catalogue")
+		return
+	}
+	go notifyBackInStock()
+	respondCatalogueImport(w, result)
+}
+
+//ExportCatalogue GET /api/store-manager/item/export downloads all the items as a CSV file ImportCatalogue accepts
+func ExportCatalogue(w http.ResponseWriter, r *http.Request) {
+	rows, err := dbHelpers.GetCatalogue()
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch catalogue")
+		return
+	}
+	for i := range rows {
+		if !rows[i].Bucket.Valid {
+			continue
+		}
+		rows[i].ImageURL, err = firebase.GetURL(&models.Image{Bucket: rows[i].Bucket.String, Path: rows[i].Path.String})
+		if err != nil {
+			utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "Failed in getting image URL")
+			return
+		}
+	}
+
+	var file bytes.Buffer
+	if err := catalogue.Write(&file, rows); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to create csv file for catalogue")
+		return
+	}
+	w.Header().Set("Content-Disposition", "attachment; filename=Catalogue.csv")
+	w.Header().Set("Content-Type", "text/csv")
+	if _, err := io.Copy(w, &file); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to download csv file for catalogue")
+	}
+}
+
+//setCatalogueImages sets the image of the rows with an image url, images of the catalogue are linked again and
+//others are downloaded and uploaded when download is set, a url is fetched once however many rows have it
+func setCatalogueImages(rows []models.CatalogueRow, download bool) ([]models.CatalogueRowError, error) {
+	rowErrors := make([]models.CatalogueRowError, 0)
+	imageIDs := make(map[string]int)
+	for i := range rows {
+		imageURL := rows[i].ImageURL
+		if imageURL == "" {
+			continue
+		}
+		if imageID, ok := imageIDs[imageURL]; ok {
+			rows[i].ImageID.SetValid(imageID)
+			continue
+		}
+
+		if image, ok := firebase.ImageFromURL(imageURL); ok {
+			imageID, err := dbHelpers.GetImageID(image.Bucket, image.Path)
+			if err == sql.ErrNoRows {
+				rowErrors = append(rowErrors, models.CatalogueRowError{Row: rows[i].Row, Column: catalogue.ImageURLColumn, Message: "image not found"})
+				continue
+			}
+			if err != nil {
+				return rowErrors, err
+			}
+			imageIDs[imageURL] = imageID
+			rows[i].ImageID.SetValid(imageID)
+			continue
+		}
+		if !download {
+			continue
+		}
+
+		fileBytes, err := utils.DownloadImage(imageURL, models.MaxItemImageSize)
+		if err != nil {
+			rowErrors = append(rowErrors, models.CatalogueRowError{Row: rows[i].Row, Column: catalogue.ImageURLColumn, Message: fmt.Sprintf("unable to download image: %v", err)})
+			continue
+		}
+		fileName := "image"
+		if parsed, err := url.Parse(imageURL); err == nil {
+			fileName = path.Base(parsed.Path)
+		}
+		uploadedFileName, err := firebase.UploadToFirebase(fileBytes, fileName)
+		if err != nil {
+			return rowErrors, err
+		}
+		imageID, err := dbHelpers.StoreImageInfo(models.BucketLink, uploadedFileName, string(models.ItemImage))
+		if err != nil {
+			return rowErrors, err
+		}
+		imageIDs[imageURL] = imageID
+		rows[i].ImageID.SetValid(imageID)
+	}
+	return rowErrors, nil
+}
+
+func respondCatalogueImport(w http.ResponseWriter, result models.CatalogueImport) {
+	if len(result.Errors) > 0 {
+		utils.RespondJSON(w, http.StatusUnprocessableEntity, result)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, result)
+}
@@ -2,36 +2,33 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 	"github.com/RemoteState/yourdaily-server/dbHelpers"
+	"github.com/RemoteState/yourdaily-server/firebase"
 	"github.com/RemoteState/yourdaily-server/models"
 	"github.com/RemoteState/yourdaily-server/utils"
 	"github.com/go-chi/chi"
+	"github.com/lib/pq"
+	"github.com/volatiletech/null"
 	"net/http"
+	"strings"
 )
 
 func CreateCategory(w http.ResponseWriter, r *http.Request) {
 
-	reqBody := struct {
-		Category string `json:"category"`
-	}{}
-
-	if err := utils.ParseBody(r.Body, &reqBody); err != nil {
-		utils.RespondError(w, http.StatusBadRequest, err, "Failed to decode request body")
-		return
-	}
-
-	categoryID, err := dbHelpers.InsertCategory(reqBody.Category)
+	reqBody, err := parseCategory(r)
 	if err != nil {
-		utils.RespondError(w, http.StatusInternalServerError, err, "Failed to store category entry")
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
 		return
 	}
 
-	category, err := dbHelpers.GetCategoryById(categoryID)
+	categoryID, err := dbHelpers.InsertCategory(reqBody)
 	if err != nil {
-		utils.RespondError(w, http.StatusInternalServerError, err, "Failed to get category")
+		respondCategorySaveError(w, err, "Failed to store category entry")
 		return
 	}
-	utils.RespondJSON(w, http.StatusCreated, category)
+	respondCategory(w, http.StatusCreated, categoryID)
 }
 
 func GetAllCategories(w http.ResponseWriter, r *http.Request) {
@@ -41,9 +38,28 @@ func GetAllCategories(w http.ResponseWriter, r *http.Request) {
 		utils.RespondError(w, http.StatusInternalServerError, err, "Failed to get category entries")
 		return
 	}
+	if err := setCategoryImageLinks(categories); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, "Failed in getting image URL")
+		return
+	}
 	utils.RespondJSON(w, http.StatusOK, categories)
 }
 
+//GetCategoryTree returns the categories visible now as a tree, a hidden category hides its sub categories
+func GetCategoryTree(w http.ResponseWriter, r *http.Request) {
+
+	categories, err := dbHelpers.GetVisibleCategories()
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, "Failed to get category entries")
+		return
+	}
+	if err := setCategoryImageLinks(categories); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, "Failed in getting image URL")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, categoryTree(categories, null.Int{}))
+}
+
 func ModifyCategory(w http.ResponseWriter, r *http.Request) {
 
 	categoryID, err := utils.StringToInt(chi.URLParam(r, "id"))
@@ -52,25 +68,49 @@ func ModifyCategory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	reqBody, err := parseCategory(r)
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
+	reqBody.ID = categoryID
+	if err := dbHelpers.ModifyCategory(reqBody); err != nil {
+		respondCategorySaveError(w, err, "Failed to update category entry")
+		return
+	}
+	respondCategory(w, http.StatusCreated, categoryID)
+}
+
+//OrderCategories sets the display order of sibling categories to the order of the given ids
+func OrderCategories(w http.ResponseWriter, r *http.Request) {
+
 	reqBody := struct {
-		Category string `json:"category"`
+		CategoryIDs []int `json:"categoryIds"`
 	}{}
 
 	if err := utils.ParseBody(r.Body, &reqBody); err != nil {
 		utils.RespondError(w, http.StatusBadRequest, err, "Failed to decode request body")
 		return
 	}
-	if err := dbHelpers.ModifyCategory(reqBody.Category, categoryID); err != nil {
-		utils.RespondError(w, http.StatusInternalServerError, err, "Failed to update category entry")
+	if len(reqBody.CategoryIDs) == 0 {
+		utils.RespondError(w, http.StatusBadRequest, nil, "categoryIds can't be empty")
 		return
 	}
 
-	category, err := dbHelpers.GetCategoryById(categoryID)
-	if err != nil {
-		utils.RespondError(w, http.StatusInternalServerError, err, "Failed to get category")
+	if err := dbHelpers.OrderCategories(reqBody.CategoryIDs); err != nil {
+		switch {
+		case err == sql.ErrNoRows:
+			utils.RespondError(w, http.StatusNotFound, err, "category not found")
+		case errors.Is(err, dbHelpers.ErrInvalidCategoryParent):
+			utils.RespondError(w, http.StatusBadRequest, err, "categories must have the same parent")
+		default:
+			utils.RespondError(w, http.StatusInternalServerError, err, "Failed to order categories")
+		}
 		return
 	}
-	utils.RespondJSON(w, http.StatusCreated, category)
+	utils.RespondJSON(w, http.StatusOK, models.Response{
+		Success: true,
+	})
 }
 
 func ArchiveCategory(w http.ResponseWriter, r *http.Request) {
@@ -93,3 +133,90 @@ func ArchiveCategory(w http.ResponseWriter, r *http.Request) {
 		Success: true,
 	})
 }
+
+func parseCategory(r *http.Request) (models.ItemCategory, error) {
+	reqBody := struct {
+		Category     string    `json:"category"`
+		ParentID     null.Int  `json:"parentId"`
+		Position     int       `json:"position"`
+		ImageID      null.Int  `json:"imageId"`
+		VisibleFrom  null.Time `json:"visibleFrom"`
+		VisibleUntil null.Time `json:"visibleUntil"`
+	}{}
+
+	if err := utils.ParseBody(r.Body, &reqBody); err != nil {
+		return models.ItemCategory{}, err
+	}
+	category := models.ItemCategory{
+		Category:     strings.TrimSpace(reqBody.Category),
+		ParentID:     reqBody.ParentID,
+		Position:     reqBody.Position,
+		ImageID:      reqBody.ImageID,
+		VisibleFrom:  reqBody.VisibleFrom,
+		VisibleUntil: reqBody.VisibleUntil,
+	}
+	switch {
+	case category.Category == "":
+		return category, fmt.Errorf("category can't be empty")
+	case category.Position < 0:
+		return category, fmt.Errorf("position can't be negative")
+	case category.VisibleFrom.Valid && category.VisibleUntil.Valid && !category.VisibleUntil.Time.After(category.VisibleFrom.Time):
+		return category, fmt.Errorf("visibleUntil must be after visibleFrom")
+	}
+	return category, nil
+}
+
+func respondCategorySaveError(w http.ResponseWriter, err error, msg string) {
+	var pqErr *pq.Error
+	switch {
+	case err == sql.ErrNoRows:
+		utils.RespondError(w, http.StatusNotFound, err, "category not found")
+	case errors.Is(err, dbHelpers.ErrInvalidCategoryParent):
+		utils.RespondError(w, http.StatusBadRequest, err, "parent category not found or inside the category")
+	case errors.As(err, &pqErr) && pqErr.Code == "23503":
+		utils.RespondError(w, http.StatusBadRequest, err, "image not found")
+	default:
+		utils.RespondError(w, http.StatusInternalServerError, err, msg)
+	}
+}
+
+func respondCategory(w http.ResponseWriter, status, categoryID int) {
+	category, err := dbHelpers.GetCategoryById(categoryID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, "Failed to get category")
+		return
+	}
+	categories := []models.ItemCategory{*category}
+	if err := setCategoryImageLinks(categories); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, "Failed in getting image URL")
+		return
+	}
+	utils.RespondJSON(w, status, categories[0])
+}
+
+func setCategoryImageLinks(categories []models.ItemCategory) error {
+	for i := range categories {
+		if !categories[i].Bucket.Valid {
+			continue
+		}
+		imageURL, err := firebase.GetURL(&models.Image{Bucket: categories[i].Bucket.String, Path: categories[i].Path.String})
+		if err != nil {
+			return err
+		}
+		categories[i].ImageLink = null.StringFrom(imageURL)
+	}
+	return nil
+}
+
+//categoryTree nests the categories under the parent, categories are expected in display order
+func categoryTree(categories []models.ItemCategory, parentID null.Int) []models.ItemCategory {
+	tree := make([]models.ItemCategory, 0)
+	for _, category := range categories {
+		if category.ParentID != parentID {
+			continue
+		}
+		category.Children = categoryTree(categories, null.IntFrom(category.ID))
+		tree = append(tree, category)
+	}
+	return tree
+}
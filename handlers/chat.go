@@ -1,15 +1,39 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
+	"github.com/RemoteState/yourdaily-server/chatHub"
 	"github.com/RemoteState/yourdaily-server/dbHelpers"
+	"github.com/RemoteState/yourdaily-server/firebase"
 	"github.com/RemoteState/yourdaily-server/middlewares"
 	"github.com/RemoteState/yourdaily-server/models"
 	"github.com/RemoteState/yourdaily-server/utils"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+	"github.com/volatiletech/null"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
+var (
+	errNotChatParticipant = errors.New("not a participant of this order chat")
+	errChatClosed         = errors.New("chat is read-only for this order")
+	errChatMuted          = errors.New("you have been muted in this order chat")
+	errInvalidAttachment  = errors.New("invalid attachment")
+)
+
+var chatUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// apps connect from any origin, same as the cors options
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+//GetAllMessage GET /api/chat?orderID=&before=&limit=
+//returns the whole chat history, or a page of messages older than the `before` message id when paginating
 func GetAllMessage(w http.ResponseWriter, r *http.Request) {
 	var (
 		orderID int
@@ -26,7 +50,39 @@ func GetAllMessage(w http.ResponseWriter, r *http.Request) {
 		utils.RespondError(w, http.StatusUnauthorized, err, err.Error(), err.Error())
 		return
 	}
-	chats, err := dbHelpers.GetAllMessage(orderID)
+	userCtx := middlewares.UserContext(r)
+	if _, err := chatAccess(orderID, userCtx.ID, userCtx.Permission, false); err != nil {
+		respondChatAccessError(w, err)
+		return
+	}
+
+	before := r.URL.Query().Get("before")
+	limit := r.URL.Query().Get("limit")
+	if before == "" && limit == "" {
+		chats, err := dbHelpers.GetAllMessage(orderID)
+		if err != nil {
+			utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), err.Error())
+			return
+		}
+		utils.RespondJSON(w, 200, chats)
+		return
+	}
+
+	beforeID, pageSize := 0, models.DefaultChatPageSize
+	if before != "" {
+		if beforeID, err = strconv.Atoi(before); err != nil {
+			utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid value for before")
+			return
+		}
+	}
+	if limit != "" {
+		if pageSize, err = strconv.Atoi(limit); err != nil || pageSize <= 0 {
+			err = fmt.Errorf("invalid value for limit")
+			utils.RespondError(w, http.StatusBadRequest, err, err.Error(), err.Error())
+			return
+		}
+	}
+	chats, err := dbHelpers.GetMessagesBefore(orderID, beforeID, pageSize)
 	if err != nil {
 		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), err.Error())
 		return
@@ -35,7 +91,7 @@ func GetAllMessage(w http.ResponseWriter, r *http.Request) {
 }
 
 func PostMessage(w http.ResponseWriter, r *http.Request) {
-	Sender := middlewares.UserContext(r).ID
+	userCtx := middlewares.UserContext(r)
 
 	chat := models.Chat{}
 	err := utils.ParseBody(r.Body, &chat)
@@ -43,13 +99,30 @@ func PostMessage(w http.ResponseWriter, r *http.Request) {
 		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "unable to parse req body")
 		return
 	}
-	chat.Sender = Sender
-	err = dbHelpers.InsertMessage(chat)
+	order, err := chatAccess(chat.OrderID, userCtx.ID, userCtx.Permission, true)
+	if err != nil {
+		respondChatAccessError(w, err)
+		return
+	}
+	chat.Sender = userCtx.ID
+	if err := validateChatAttachment(&chat); err != nil {
+		if errors.Is(err, errInvalidAttachment) {
+			utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to validate attachment")
+		return
+	}
+	if err := moderateMessage(&chat, order); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to moderate message")
+		return
+	}
+	chat, err = dbHelpers.InsertMessage(chat)
 	if err != nil {
 		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), err.Error())
 		return
 	}
-	err = dbHelpers.SendNotification(chat)
+	err = deliverMessage(chat)
 	if err != nil {
 		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "failed to send message notification")
 		return
@@ -61,3 +134,382 @@ func PostMessage(w http.ResponseWriter, r *http.Request) {
 	}
 	utils.RespondJSON(w, 200, chats)
 }
+
+//UploadChatAttachment POST /api/chat/attachment?orderID=
+//uploads an image for the order chat, the returned image id is then sent with the message
+func UploadChatAttachment(w http.ResponseWriter, r *http.Request) {
+	userCtx := middlewares.UserContext(r)
+
+	orderID, err := strconv.Atoi(r.URL.Query().Get("orderID"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid orderID")
+		return
+	}
+	if _, err := chatAccess(orderID, userCtx.ID, userCtx.Permission, true); err != nil {
+		respondChatAccessError(w, err)
+		return
+	}
+
+	// leave room for the multipart headers on top of the image itself
+	r.Body = http.MaxBytesReader(w, r.Body, models.MaxChatImageSize+1<<20)
+	file, fileBytes, downloadedFileName, err := utils.ReadFromFile(r, string(models.ChatImage))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, "Failed in reading image file")
+		return
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			logrus.Errorf("UploadChatAttachment: failed to close file error: %v", err)
+		}
+	}()
+
+	if err := utils.ValidateImage(fileBytes, models.MaxChatImageSize); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
+
+	uploadedFileName, err := firebase.UploadToFirebase(fileBytes, downloadedFileName)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, "Failed in uploading to firebase")
+		return
+	}
+	imageID, err := dbHelpers.StoreChatImageInfo(models.BucketLink, uploadedFileName, orderID, userCtx.ID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, "Failed in storing uploaded file info")
+		return
+	}
+	url, err := firebase.GetURL(&models.Image{Bucket: models.BucketLink, Path: uploadedFileName})
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, "Failed in generating image link")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusCreated, struct {
+		ImageID  int    `json:"imageId"`
+		ImageURL string `json:"imageUrl"`
+	}{
+		ImageID:  imageID,
+		ImageURL: url,
+	})
+}
+
+//MarkMessagesRead PUT /api/chat/read marks all messages up to the given message id as read
+func MarkMessagesRead(w http.ResponseWriter, r *http.Request) {
+	userCtx := middlewares.UserContext(r)
+
+	reqBody := struct {
+		OrderID   int `json:"orderID"`
+		MessageID int `json:"messageID"`
+	}{}
+	if err := utils.ParseBody(r.Body, &reqBody); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "unable to parse req body")
+		return
+	}
+	if _, err := chatAccess(reqBody.OrderID, userCtx.ID, userCtx.Permission, false); err != nil {
+		respondChatAccessError(w, err)
+		return
+	}
+
+	// receipts are only tracked between the user and the staff
+	if userCtx.Permission != models.StoreManager {
+		if err := markRead(reqBody.OrderID, userCtx.ID, reqBody.MessageID); err != nil {
+			utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to mark messages as read")
+			return
+		}
+	}
+	utils.RespondJSON(w, http.StatusOK, models.Response{Success: true})
+}
+
+//ChatSocket GET /api/chat/ws?orderID=&lastMessageID=
+//upgrades to a websocket for the order chat, replaying every message after lastMessageID on (re)connect
+func ChatSocket(w http.ResponseWriter, r *http.Request) {
+	userCtx := middlewares.UserContext(r)
+
+	orderID, err := strconv.Atoi(r.URL.Query().Get("orderID"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid orderID")
+		return
+	}
+	lastMessageID := 0
+	if r.URL.Query().Get("lastMessageID") != "" {
+		lastMessageID, err = strconv.Atoi(r.URL.Query().Get("lastMessageID"))
+		if err != nil {
+			utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid lastMessageID")
+			return
+		}
+	}
+	if _, err := chatAccess(orderID, userCtx.ID, userCtx.Permission, false); err != nil {
+		respondChatAccessError(w, err)
+		return
+	}
+
+	conn, err := chatUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader has already replied with an http error
+		logrus.Errorf("ChatSocket: failed to upgrade connection for order %d error: %v", orderID, err)
+		return
+	}
+
+	// the client is registered before the missed messages are loaded so none posted in between is lost
+	client := chatHub.NewClient(conn, userCtx, orderID)
+	chatHub.HubInstance.RegisterReplaying(client)
+	go client.WritePump()
+
+	missed, err := dbHelpers.GetMessagesAfter(orderID, lastMessageID)
+	if err != nil {
+		logrus.Errorf("ChatSocket: failed to get messages after %d for order %d error: %v", lastMessageID, orderID, err)
+		chatHub.HubInstance.Unregister(client)
+		return
+	}
+	chatHub.HubInstance.Replay(client, missed, lastMessageID)
+	if len(missed) > 0 && userCtx.Permission != models.StoreManager {
+		markDelivered(orderID, userCtx.ID, missed[len(missed)-1].ID)
+	}
+
+	client.ReadPump(chatHub.HubInstance, handleChatEvent)
+}
+
+//handleChatEvent handles a single event sent by a websocket client
+func handleChatEvent(c *chatHub.Client, event models.ChatEvent) {
+	switch event.Type {
+	case models.ChatEventMessage:
+		// attachments are sent in the chat payload, plain text can be sent as message
+		chat := models.Chat{Message: event.Message}
+		if event.Chat != nil {
+			chat = *event.Chat
+		}
+		chat.OrderID = c.OrderID
+		chat.Sender = c.UserID
+		order, err := chatAccess(c.OrderID, c.UserID, c.Permission, true)
+		if err != nil {
+			sendChatError(c, err.Error())
+			return
+		}
+		if err := validateChatAttachment(&chat); err != nil {
+			if !errors.Is(err, errInvalidAttachment) {
+				logrus.Errorf("handleChatEvent: failed to validate attachment for order %d error: %v", c.OrderID, err)
+			}
+			sendChatError(c, err.Error())
+			return
+		}
+		if err := moderateMessage(&chat, order); err != nil {
+			logrus.Errorf("handleChatEvent: failed to moderate message for order %d error: %v", c.OrderID, err)
+			sendChatError(c, "failed to send message")
+			return
+		}
+		chat, err = dbHelpers.InsertMessage(chat)
+		if err != nil {
+			logrus.Errorf("handleChatEvent: failed to insert message for order %d error: %v", c.OrderID, err)
+			sendChatError(c, "failed to send message")
+			return
+		}
+		if err := deliverMessage(chat); err != nil {
+			logrus.Errorf("handleChatEvent: failed to notify for order %d error: %v", c.OrderID, err)
+		}
+	case models.ChatEventTyping:
+		if _, err := chatAccess(c.OrderID, c.UserID, c.Permission, true); err != nil {
+			sendChatError(c, err.Error())
+			return
+		}
+		chatHub.HubInstance.Broadcast(c.OrderID, models.ChatEvent{
+			Type:    models.ChatEventTyping,
+			OrderID: c.OrderID,
+			Sender:  c.UserID,
+			Typing:  event.Typing,
+		}, c)
+	case models.ChatEventDelivered:
+		if c.Permission != models.StoreManager {
+			markDelivered(c.OrderID, c.UserID, event.MessageID)
+		}
+	case models.ChatEventRead:
+		if c.Permission == models.StoreManager {
+			return
+		}
+		if err := markRead(c.OrderID, c.UserID, event.MessageID); err != nil {
+			logrus.Errorf("handleChatEvent: failed to mark messages read for order %d error: %v", c.OrderID, err)
+			sendChatError(c, "failed to mark messages as read")
+		}
+	default:
+		sendChatError(c, fmt.Sprintf("unknown event type %q", event.Type))
+	}
+}
+
+//validateChatAttachment trims the message and checks that the attachment matches its type,
+//clearing the fields that don't belong to it. A message needs either text or an attachment,
+//an image must have been uploaded by the sender for the order's chat.
+func validateChatAttachment(chat *models.Chat) error {
+	chat.Message = strings.TrimSpace(chat.Message)
+	if !chat.AttachmentType.Valid || chat.AttachmentType.String == "" {
+		if chat.Message == "" {
+			return fmt.Errorf("%w: message can't be empty", errInvalidAttachment)
+		}
+		chat.AttachmentType = null.String{}
+		chat.ImageID, chat.ItemID = null.Int{}, null.Int{}
+		chat.Lat, chat.Long = null.Float64{}, null.Float64{}
+		return nil
+	}
+
+	switch models.ChatAttachmentType(chat.AttachmentType.String) {
+	case models.ChatImageAttachment:
+		if !chat.ImageID.Valid {
+			return fmt.Errorf("%w: imageId is required", errInvalidAttachment)
+		}
+		ok, err := dbHelpers.IsChatImageOf(chat.ImageID.Int, chat.OrderID, chat.Sender)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("%w: unknown chat image", errInvalidAttachment)
+		}
+		chat.ItemID = null.Int{}
+		chat.Lat, chat.Long = null.Float64{}, null.Float64{}
+	case models.ChatLocationAttachment:
+		if !chat.Lat.Valid || !chat.Long.Valid ||
+			chat.Lat.Float64 < -90 || chat.Lat.Float64 > 90 ||
+			chat.Long.Float64 < -180 || chat.Long.Float64 > 180 {
+			return fmt.Errorf("%w: valid lat and long are required", errInvalidAttachment)
+		}
+		chat.ImageID, chat.ItemID = null.Int{}, null.Int{}
+	case models.ChatItemAttachment:
+		if !chat.ItemID.Valid {
+			return fmt.Errorf("%w: itemId is required", errInvalidAttachment)
+		}
+		if _, err := dbHelpers.GetItemById(chat.ItemID.Int); err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("%w: item not found", errInvalidAttachment)
+			}
+			return err
+		}
+		chat.ImageID = null.Int{}
+		chat.Lat, chat.Long = null.Float64{}, null.Float64{}
+	default:
+		return fmt.Errorf("%w: unknown attachment type %q", errInvalidAttachment, chat.AttachmentType.String)
+	}
+	return nil
+}
+
+//chatAccess checks if the user can read the order chat and, when write is set, post in it.
+//The order's user and assigned staff can post until the order is delivered or cancelled, the owning
+//store manager can only read it; while the order is under dispute all of them can post.
+//Participants muted by the store manager can only read.
+func chatAccess(orderID, userID int, permission models.UserPermission, write bool) (models.ChatOrder, error) {
+	order, err := dbHelpers.GetChatOrder(orderID)
+	if err != nil {
+		return order, err
+	}
+
+	if permission == models.StoreManager {
+		if !order.SmID.Valid || order.SmID.Int != userID {
+			return order, errNotChatParticipant
+		}
+		if write && !order.Disputed {
+			return order, errChatClosed
+		}
+		return order, nil
+	}
+
+	if order.UserID != userID && (!order.StaffID.Valid || order.StaffID.Int != userID) {
+		return order, errNotChatParticipant
+	}
+	if !write {
+		return order, nil
+	}
+	if order.IsClosed() && !order.Disputed {
+		return order, errChatClosed
+	}
+	muted, err := dbHelpers.IsChatMuted(orderID, userID)
+	if err != nil {
+		return order, err
+	}
+	if muted {
+		return order, errChatMuted
+	}
+	return order, nil
+}
+
+//moderateMessage masks the message text as per the chat settings of the order's store manager
+func moderateMessage(chat *models.Chat, order models.ChatOrder) error {
+	if chat.Message == "" || !order.SmID.Valid {
+		return nil
+	}
+	settings, err := dbHelpers.GetChatSettings(order.SmID.Int)
+	if err != nil {
+		return err
+	}
+	chat.Message = utils.MaskChatMessage(chat.Message, settings)
+	return nil
+}
+
+func respondChatAccessError(w http.ResponseWriter, err error) {
+	switch err {
+	case sql.ErrNoRows:
+		utils.RespondError(w, http.StatusNotFound, err, "order not found")
+	case errNotChatParticipant, errChatClosed, errChatMuted:
+		utils.RespondError(w, http.StatusForbidden, err, err.Error())
+	default:
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), err.Error())
+	}
+}
+
+//deliverMessage pushes a new message to the connected clients of the order chat,
+//falling back to a push notification when the other participant is not connected
+func deliverMessage(chat models.Chat) error {
+	online := chatHub.HubInstance.IsOnline(chat.OrderID, chat.Sender)
+	chatHub.HubInstance.Broadcast(chat.OrderID, models.ChatEvent{
+		Type:    models.ChatEventMessage,
+		OrderID: chat.OrderID,
+		Chat:    &chat,
+	}, nil)
+
+	if !online {
+		return dbHelpers.SendNotification(chat)
+	}
+	if err := dbHelpers.MarkMessageDelivered(chat.ID); err != nil {
+		return err
+	}
+	chatHub.HubInstance.Broadcast(chat.OrderID, models.ChatEvent{
+		Type:      models.ChatEventDelivered,
+		OrderID:   chat.OrderID,
+		MessageID: chat.ID,
+	}, nil)
+	return nil
+}
+
+func markDelivered(orderID, receiverID, messageID int) {
+	ids, err := dbHelpers.MarkMessagesDelivered(orderID, receiverID, messageID)
+	if err != nil {
+		logrus.Errorf("markDelivered: order %d error: %v", orderID, err)
+		return
+	}
+	for _, id := range ids {
+		chatHub.HubInstance.Broadcast(orderID, models.ChatEvent{
+			Type:      models.ChatEventDelivered,
+			OrderID:   orderID,
+			MessageID: id,
+		}, nil)
+	}
+}
+
+func markRead(orderID, readerID, messageID int) error {
+	ids, err := dbHelpers.MarkMessagesRead(orderID, readerID, messageID)
+	if err != nil {
+		return err
+	}
+	if len(ids) > 0 {
+		chatHub.HubInstance.Broadcast(orderID, models.ChatEvent{
+			Type:      models.ChatEventRead,
+			OrderID:   orderID,
+			Sender:    readerID,
+			MessageID: messageID,
+		}, nil)
+	}
+	return nil
+}
+
+func sendChatError(c *chatHub.Client, message string) {
+	chatHub.HubInstance.SendTo(c, models.ChatEvent{
+		Type:    models.ChatEventError,
+		OrderID: c.OrderID,
+		Message: message,
+	})
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/RemoteState/yourdaily-server/chatHub"
+	"github.com/RemoteState/yourdaily-server/dbHelpers"
+	"github.com/RemoteState/yourdaily-server/middlewares"
+	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/RemoteState/yourdaily-server/utils"
+	"github.com/go-chi/chi"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+//ReportMessage POST /api/chat/report reports an abusive message of the other participant to the store manager
+func ReportMessage(w http.ResponseWriter, r *http.Request) {
+	userCtx := middlewares.UserContext(r)
+
+	reqBody := struct {
+		MessageID int    `json:"messageID"`
+		Reason    string `json:"reason"`
+	}{}
+	if err := utils.ParseBody(r.Body, &reqBody); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "unable to parse req body")
+		return
+	}
+	reqBody.Reason = strings.TrimSpace(reqBody.Reason)
+	if reqBody.Reason == "" {
+		err := fmt.Errorf("reason can't be empty")
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
+
+	chat, err := dbHelpers.GetMessageByID(reqBody.MessageID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, http.StatusNotFound, err, "message not found")
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch message")
+		return
+	}
+	if _, err := chatAccess(chat.OrderID, userCtx.ID, userCtx.Permission, false); err != nil {
+		respondChatAccessError(w, err)
+		return
+	}
+	if chat.Sender == userCtx.ID {
+		err := fmt.Errorf("can't report your own message")
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
+
+	reportID, err := dbHelpers.InsertChatReport(chat.ID, userCtx.ID, reqBody.Reason)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, http.StatusConflict, err, "message already reported")
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to report message")
+		return
+	}
+	utils.RespondJSON(w, http.StatusCreated, struct {
+		ReportID int `json:"reportId"`
+	}{
+		ReportID: reportID,
+	})
+}
+
+//GetChatSettings returns the chat moderation settings of the store manager
+func GetChatSettings(w http.ResponseWriter, r *http.Request) {
+	smID := middlewares.UserContext(r).ID
+
+	settings, err := dbHelpers.GetChatSettings(smID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch chat settings")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, settings)
+}
+
+//UpdateChatSettings updates the masking applied to new messages in the store manager's order chats
+func UpdateChatSettings(w http.ResponseWriter, r *http.Request) {
+	smID := middlewares.UserContext(r).ID
+
+	settings := models.ChatSettings{}
+	if err := utils.ParseBody(r.Body, &settings); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "unable to parse req body")
+		return
+	}
+	words := make([]string, 0, len(settings.BlockedWords))
+	for _, word := range settings.BlockedWords {
+		if word = strings.TrimSpace(word); word != "" {
+			words = append(words, word)
+		}
+	}
+	settings.BlockedWords = words
+
+	if err := dbHelpers.UpsertChatSettings(smID, settings); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to update chat settings")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, settings)
+}
+
+//GetChatReports GET /chat/reports?status=open|resolved&offset=&limit= lists the reported messages
+func GetChatReports(w http.ResponseWriter, r *http.Request) {
+	smID := middlewares.UserContext(r).ID
+	offset, limit, err := utils.GetOffsetLimit(r)
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid value for offset or limit")
+		return
+	}
+
+	resolved := false
+	switch r.URL.Query().Get("status") {
+	case "", "open":
+	case "resolved":
+		resolved = true
+	default:
+		err := fmt.Errorf("invalid value for status")
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
+
+	reports, err := dbHelpers.GetChatReports(smID, resolved, offset, limit)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch chat reports")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, reports)
+}
+
+//ResolveChatReport marks a chat report as resolved with an optional note
+func ResolveChatReport(w http.ResponseWriter, r *http.Request) {
+	smID := middlewares.UserContext(r).ID
+	reportID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid report id")
+		return
+	}
+
+	reqBody := struct {
+		Note string `json:"note"`
+	}{}
+	if err := utils.ParseBody(r.Body, &reqBody); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "unable to parse req body")
+		return
+	}
+
+	err = dbHelpers.ResolveChatReport(reportID, smID, strings.TrimSpace(reqBody.Note))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, http.StatusNotFound, err, "open report not found")
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to resolve chat report")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, models.Response{Success: true})
+}
+
+//MuteChatParticipant mutes or unmutes the order's user or staff in the order chat
+func MuteChatParticipant(w http.ResponseWriter, r *http.Request) {
+	userCtx := middlewares.UserContext(r)
+
+	reqBody := struct {
+		OrderID int  `json:"orderID"`
+		UserID  int  `json:"userID"`
+		Muted   bool `json:"muted"`
+	}{}
+	if err := utils.ParseBody(r.Body, &reqBody); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "unable to parse req body")
+		return
+	}
+	order, err := chatAccess(reqBody.OrderID, userCtx.ID, userCtx.Permission, false)
+	if err != nil {
+		respondChatAccessError(w, err)
+		return
+	}
+	if order.UserID != reqBody.UserID && (!order.StaffID.Valid || order.StaffID.Int != reqBody.UserID) {
+		err := fmt.Errorf("user is not a participant of this order chat")
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
+
+	if reqBody.Muted {
+		err = dbHelpers.MuteChatUser(reqBody.OrderID, reqBody.UserID, userCtx.ID)
+	} else {
+		err = dbHelpers.UnmuteChatUser(reqBody.OrderID, reqBody.UserID)
+	}
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to update mute")
+		return
+	}
+
+	chatHub.HubInstance.Broadcast(reqBody.OrderID, models.ChatEvent{
+		Type:    models.ChatEventMuted,
+		OrderID: reqBody.OrderID,
+		Sender:  reqBody.UserID,
+		Muted:   reqBody.Muted,
+	}, nil)
+	utils.RespondJSON(w, http.StatusOK, models.Response{Success: true})
+}
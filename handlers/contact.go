@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/RemoteState/yourdaily-server/dbHelpers"
+	"github.com/RemoteState/yourdaily-server/firebase"
+	"github.com/RemoteState/yourdaily-server/middlewares"
+	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/RemoteState/yourdaily-server/telephony"
+	"github.com/RemoteState/yourdaily-server/utils"
+	"github.com/go-chi/chi"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+//GetOrderContact returns the masked contact the user or the staff of an active order can use to reach the other one
+func GetOrderContact(w http.ResponseWriter, r *http.Request) {
+	callerID := middlewares.UserContext(r).ID
+	orderID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid order id")
+		return
+	}
+
+	parties, err := dbHelpers.GetContactParties(orderID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, http.StatusNotFound, err, "order not found")
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch order")
+		return
+	}
+
+	req := telephony.ContactRequest{
+		OrderID:   orderID,
+		CallerID:  callerID,
+		ExpiresAt: time.Now().Add(models.ContactProxyTTL),
+	}
+	switch {
+	case parties.UserID == callerID && parties.StaffID.Valid:
+		req.CallerPhone, req.CalleeID, req.CalleePhone = parties.UserPhone, parties.StaffID.Int, parties.StaffPhone.String
+	case parties.StaffID.Valid && parties.StaffID.Int == callerID:
+		req.CallerPhone, req.CalleeID, req.CalleePhone = parties.StaffPhone.String, parties.UserID, parties.UserPhone
+	case parties.UserID == callerID:
+		err := fmt.Errorf("no staff assigned to this order yet")
+		utils.RespondError(w, http.StatusConflict, err, err.Error())
+		return
+	default:
+		err := fmt.Errorf("not a participant of this order")
+		utils.RespondError(w, http.StatusForbidden, err, err.Error())
+		return
+	}
+	if parties.Status != models.Accepted && parties.Status != models.OutForDelivery {
+		err := fmt.Errorf("contact is only available while the order is on its way")
+		utils.RespondError(w, http.StatusForbidden, err, err.Error())
+		return
+	}
+
+	proxy, err := dbHelpers.GetActiveContactProxy(orderID, callerID)
+	if err != nil && err != sql.ErrNoRows {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch contact")
+		return
+	}
+	if err == nil {
+		if proxy.ExpiresAt.After(time.Now()) {
+			utils.RespondJSON(w, http.StatusOK, proxy)
+			return
+		}
+		ReleaseContactProxies([]models.ContactProxy{proxy})
+	}
+
+	contact, err := telephony.ProviderInstance.Allocate(req)
+	if err != nil {
+		utils.RespondError(w, http.StatusBadGateway, err, "unable to issue contact, try again after some time")
+		return
+	}
+	proxy = models.ContactProxy{
+		OrderID:     orderID,
+		CallerID:    callerID,
+		CalleeID:    req.CalleeID,
+		Provider:    telephony.ProviderInstance.Name(),
+		Kind:        string(contact.Kind),
+		Value:       contact.Value,
+		ProviderRef: contact.ProviderRef,
+		ExpiresAt:   contact.ExpiresAt,
+	}
+	proxy.ID, err = dbHelpers.InsertContactProxy(proxy)
+	if err != nil {
+		if releaseErr := telephony.ProviderInstance.Release(contact.ProviderRef); releaseErr != nil {
+			logrus.Errorf("GetOrderContact: failed to release contact for order %d error: %v", orderID, releaseErr)
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to store contact")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, proxy)
+}
+
+//RedeemCallToken POST /order/contact/call connects an in-app call, the caller redeems the call token
+//issued to them by GetOrderContact and the callee is rung with a push notification
+func RedeemCallToken(w http.ResponseWriter, r *http.Request) {
+	callerID := middlewares.UserContext(r).ID
+
+	reqBody := struct {
+		Token string `json:"token"`
+	}{}
+	if err := utils.ParseBody(r.Body, &reqBody); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "unable to parse req body")
+		return
+	}
+
+	verifier, ok := telephony.ProviderInstance.(telephony.CallTokenVerifier)
+	if !ok {
+		err := fmt.Errorf("calls are made through proxy numbers")
+		utils.RespondError(w, http.StatusNotFound, err, err.Error())
+		return
+	}
+	claims, err := verifier.Verify(reqBody.Token)
+	if err != nil {
+		utils.RespondError(w, http.StatusUnauthorized, err, err.Error())
+		return
+	}
+	if claims.CallerID != callerID {
+		err := fmt.Errorf("call token was issued to someone else")
+		utils.RespondError(w, http.StatusForbidden, err, err.Error())
+		return
+	}
+
+	// a released token is never served again, even while its signature is still valid
+	proxy, err := dbHelpers.GetActiveContactProxy(claims.OrderID, callerID)
+	if err != nil && err != sql.ErrNoRows {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch contact")
+		return
+	}
+	if err == sql.ErrNoRows || proxy.Value != reqBody.Token {
+		err := fmt.Errorf("call token is no longer valid")
+		utils.RespondError(w, http.StatusForbidden, err, err.Error())
+		return
+	}
+
+	parties, err := dbHelpers.GetContactParties(claims.OrderID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch order")
+		return
+	}
+	if parties.Status != models.Accepted && parties.Status != models.OutForDelivery {
+		err := fmt.Errorf("contact is only available while the order is on its way")
+		utils.RespondError(w, http.StatusForbidden, err, err.Error())
+		return
+	}
+
+	if err := firebase.IncomingCallNotification(claims.CalleeID, callerID, claims.OrderID); err != nil {
+		utils.RespondError(w, http.StatusBadGateway, err, "unable to reach the other party, try again after some time")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, struct {
+		OrderID  int `json:"orderId"`
+		CalleeID int `json:"calleeId"`
+	}{
+		OrderID:  claims.OrderID,
+		CalleeID: claims.CalleeID,
+	})
+}
+
+//ReleaseOrderContacts releases the proxies of an order once it is completed
+func ReleaseOrderContacts(orderID int) {
+	proxies, err := dbHelpers.GetReleasableContactProxies(&orderID)
+	if err != nil {
+		logrus.Errorf("ReleaseOrderContacts: failed to fetch contacts for order %d error: %v", orderID, err)
+		return
+	}
+	ReleaseContactProxies(proxies)
+}
+
+//ReleaseContactProxies returns the proxies to their provider and marks them released
+func ReleaseContactProxies(proxies []models.ContactProxy) {
+	for _, proxy := range proxies {
+		// proxies issued by a provider we have since switched from can only be dropped on our side
+		if proxy.Provider == telephony.ProviderInstance.Name() {
+			err := telephony.ProviderInstance.Release(proxy.ProviderRef)
+			if err != nil && err != telephony.ErrNotFound {
+				logrus.Errorf("ReleaseContactProxies: failed to release contact %d error: %v", proxy.ID, err)
+				continue
+			}
+		}
+		if err := dbHelpers.MarkContactProxyReleased(proxy.ID); err != nil {
+			logrus.Errorf("ReleaseContactProxies: failed to mark contact %d released error: %v", proxy.ID, err)
+		}
+	}
+}
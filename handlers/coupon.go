@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/RemoteState/yourdaily-server/dbHelpers"
+	"github.com/RemoteState/yourdaily-server/middlewares"
+	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/RemoteState/yourdaily-server/promotions"
+	"github.com/RemoteState/yourdaily-server/utils"
+	"github.com/go-chi/chi"
+	"github.com/lib/pq"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//ValidateCoupon POST /api/user/coupon/validate checks the coupon against the cart before checkout
+//and returns the discount it gives
+func ValidateCoupon(w http.ResponseWriter, r *http.Request) {
+	userID := middlewares.UserContext(r).ID
+	reqBody := struct {
+		Code  string            `json:"code"`
+		Items []models.ItemInfo `json:"items"`
+	}{}
+	if err := utils.ParseBody(r.Body, &reqBody); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "unable to parse req body")
+		return
+	}
+	items := utils.FilterOrderItems(reqBody.Items)
+	if len(items) == 0 {
+		err := fmt.Errorf("coupons can only be applied to orders with items")
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
+	for _, item := range items {
+		if item.Quantity <= 0 {
+			err := fmt.Errorf("invalid quantity for item %d", item.Id)
+			utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+			return
+		}
+	}
+
+	coupon, err := dbHelpers.GetCouponByCode(strings.TrimSpace(reqBody.Code))
+	if err != nil {
+		if err == promotions.ErrInvalidCoupon {
+			utils.RespondError(w, http.StatusNotFound, err, err.Error())
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch coupon")
+		return
+	}
+	offer, err := dbHelpers.GetActiveOffer()
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch offer")
+		return
+	}
+	discount := offer.Discount
+	if !coupon.Stackable {
+		discount = 0
+	}
+	lines, err := dbHelpers.GetCartCouponLines(items, discount)
+	if err != nil {
+		if err == dbHelpers.ErrItemNotFound {
+			utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to price cart")
+		return
+	}
+	usage, err := dbHelpers.GetCouponUsage(coupon.ID, userID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch coupon usage")
+		return
+	}
+
+	couponDiscount, err := promotions.Discount(coupon, lines, usage, time.Now())
+	if err != nil {
+		utils.RespondError(w, http.StatusUnprocessableEntity, err, err.Error())
+		return
+	}
+	var orderValue float32
+	for _, line := range lines {
+		orderValue += line.Amount
+	}
+	utils.RespondJSON(w, http.StatusOK, struct {
+		Code          string  `json:"code"`
+		Description   string  `json:"description"`
+		OfferReplaced bool    `json:"offerReplaced"`
+		OrderValue    float32 `json:"orderValue"`
+		Discount      float32 `json:"discount"`
+		Total         float32 `json:"total"`
+	}{
+		Code:          coupon.Code,
+		Description:   coupon.Description,
+		OfferReplaced: !coupon.Stackable && offer.Discount > 0,
+		OrderValue:    orderValue,
+		Discount:      couponDiscount,
+		Total:         orderValue - couponDiscount,
+	})
+}
+
+//GetCoupons returns all the coupons to the store manager
+func GetCoupons(w http.ResponseWriter, r *http.Request) {
+	coupons, err := dbHelpers.GetCoupons()
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch coupons")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, coupons)
+}
+
+//CreateCoupon creates a coupon code
+func CreateCoupon(w http.ResponseWriter, r *http.Request) {
+	smID := middlewares.UserContext(r).ID
+	coupon, err := parseCoupon(r)
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
+	coupon.ID, err = dbHelpers.InsertCoupon(coupon, smID)
+	if err != nil {
+		respondCouponSaveError(w, err)
+		return
+	}
+	respondCoupon(w, http.StatusCreated, coupon.ID)
+}
+
+//UpdateCoupon updates a coupon
+func UpdateCoupon(w http.ResponseWriter, r *http.Request) {
+	couponID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid coupon id")
+		return
+	}
+	coupon, err := parseCoupon(r)
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
+	coupon.ID = couponID
+	if err := dbHelpers.UpdateCoupon(coupon); err != nil {
+		respondCouponSaveError(w, err)
+		return
+	}
+	respondCoupon(w, http.StatusOK, couponID)
+}
+
+//ArchiveCoupon archives a coupon so it can't be used anymore
+func ArchiveCoupon(w http.ResponseWriter, r *http.Request) {
+	couponID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid coupon id")
+		return
+	}
+	if err := dbHelpers.ArchiveCoupon(couponID); err != nil {
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, http.StatusNotFound, err, "coupon not found")
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to archive coupon")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, models.Response{Success: true})
+}
+
+func parseCoupon(r *http.Request) (models.Coupon, error) {
+	coupon := models.Coupon{}
+	if err := utils.ParseBody(r.Body, &coupon); err != nil {
+		return coupon, err
+	}
+	coupon.Code = strings.ToUpper(strings.TrimSpace(coupon.Code))
+	coupon.Description = strings.TrimSpace(coupon.Description)
+	if coupon.Code == "" || strings.ContainsAny(coupon.Code, " \t\n") {
+		return coupon, fmt.Errorf("code can't be empty or contain spaces")
+	}
+
+	switch coupon.DiscountType {
+	case models.FlatCoupon:
+		coupon.MaxDiscount.Valid = false
+	case models.PercentageCoupon:
+		if coupon.Value > 100 {
+			return coupon, fmt.Errorf("percentage can't be more than 100")
+		}
+	default:
+		return coupon, fmt.Errorf("invalid discount type")
+	}
+	if coupon.Value <= 0 || (coupon.MaxDiscount.Valid && coupon.MaxDiscount.Float32 <= 0) || coupon.MinOrderValue < 0 {
+		return coupon, fmt.Errorf("value, max discount and min order value must be positive")
+	}
+	if (coupon.UsageLimit.Valid && coupon.UsageLimit.Int <= 0) || (coupon.PerUserLimit.Valid && coupon.PerUserLimit.Int <= 0) {
+		return coupon, fmt.Errorf("usage limits must be positive")
+	}
+
+	if coupon.CategoryIDs == nil {
+		coupon.CategoryIDs = pq.Int64Array{}
+	}
+	if coupon.ItemIDs == nil {
+		coupon.ItemIDs = pq.Int64Array{}
+	}
+	switch coupon.Scope {
+	case "", models.OrderCouponScope:
+		coupon.Scope = models.OrderCouponScope
+	case models.CategoryCouponScope:
+		if len(coupon.CategoryIDs) == 0 {
+			return coupon, fmt.Errorf("categoryIds are required for category coupons")
+		}
+	case models.ItemCouponScope:
+		if len(coupon.ItemIDs) == 0 {
+			return coupon, fmt.Errorf("itemIds are required for item coupons")
+		}
+	default:
+		return coupon, fmt.Errorf("invalid scope")
+	}
+
+	if coupon.StartsAt.IsZero() {
+		coupon.StartsAt = time.Now()
+	}
+	if coupon.EndsAt.Valid && !coupon.EndsAt.Time.After(coupon.StartsAt) {
+		return coupon, fmt.Errorf("endsAt must be after startsAt")
+	}
+	return coupon, nil
+}
+
+func respondCouponSaveError(w http.ResponseWriter, err error) {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		utils.RespondError(w, http.StatusConflict, err, "coupon code already exists")
+		return
+	}
+	if err == sql.ErrNoRows {
+		utils.RespondError(w, http.StatusNotFound, err, "coupon not found")
+		return
+	}
+	utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to save coupon")
+}
+
+func respondCoupon(w http.ResponseWriter, status, couponID int) {
+	coupon, err := dbHelpers.GetCouponByID(couponID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch coupon")
+		return
+	}
+	utils.RespondJSON(w, status, coupon)
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/RemoteState/yourdaily-server/dbHelpers"
+	"github.com/RemoteState/yourdaily-server/middlewares"
+	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/RemoteState/yourdaily-server/utils"
+	"net/http"
+	"sort"
+)
+
+//GetDeliverySettings returns the minimum order value and the delivery fee rules of the store manager
+func GetDeliverySettings(w http.ResponseWriter, r *http.Request) {
+	smID := middlewares.UserContext(r).ID
+	settings, err := dbHelpers.GetDeliverySettings(smID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch delivery settings")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, settings)
+}
+
+//UpdateDeliverySettings replaces the minimum order value and the delivery fee rules of the store manager
+func UpdateDeliverySettings(w http.ResponseWriter, r *http.Request) {
+	smID := middlewares.UserContext(r).ID
+	settings := models.DeliverySettings{}
+	if err := utils.ParseBody(r.Body, &settings); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "unable to parse req body")
+		return
+	}
+	if settings.Rules == nil {
+		settings.Rules = make([]models.DeliveryFeeRule, 0)
+	}
+	if err := validateDeliverySettings(settings); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
+
+	if err := dbHelpers.UpdateDeliverySettings(smID, settings); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to update delivery settings")
+		return
+	}
+	settings, err := dbHelpers.GetDeliverySettings(smID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch delivery settings")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, settings)
+}
+
+//validateDeliverySettings checks the values of the rules and that the distance bands of a mode don't overlap
+func validateDeliverySettings(settings models.DeliverySettings) error {
+	if settings.MinOrderValue < 0 {
+		return fmt.Errorf("minOrderValue can't be negative")
+	}
+	rules := append([]models.DeliveryFeeRule{}, settings.Rules...)
+	for _, rule := range rules {
+		if rule.Mode != models.CartMode && rule.Mode != models.DeliveryMode {
+			return fmt.Errorf("invalid mode %q", rule.Mode)
+		}
+		if rule.MinDistance < 0 || rule.Fee < 0 {
+			return fmt.Errorf("minDistance and fee can't be negative")
+		}
+		if rule.MaxDistance.Valid && rule.MaxDistance.Float64 <= rule.MinDistance {
+			return fmt.Errorf("maxDistance must be more than minDistance")
+		}
+		if rule.FreeAbove.Valid && rule.FreeAbove.Float32 <= 0 {
+			return fmt.Errorf("freeAbove must be positive")
+		}
+	}
+
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Mode != rules[j].Mode {
+			return rules[i].Mode < rules[j].Mode
+		}
+		return rules[i].MinDistance < rules[j].MinDistance
+	})
+	for i := 1; i < len(rules); i++ {
+		previous := rules[i-1]
+		if previous.Mode == rules[i].Mode && (!previous.MaxDistance.Valid || previous.MaxDistance.Float64 > rules[i].MinDistance) {
+			return fmt.Errorf("%s distance bands overlap at %.2f km", rules[i].Mode, rules[i].MinDistance)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/RemoteState/yourdaily-server/dbHelpers"
+	"github.com/RemoteState/yourdaily-server/firebase"
+	"github.com/RemoteState/yourdaily-server/invoice"
+	"github.com/RemoteState/yourdaily-server/middlewares"
+	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/RemoteState/yourdaily-server/utils"
+	"github.com/go-chi/chi"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var errNotInvoiceable = fmt.Errorf("invoice is only available for delivered orders")
+
+//GenerateInvoice returns the invoice of the delivered order, numbering and storing it on first use
+func GenerateInvoice(orderID int) (models.Invoice, error) {
+	inv, err := dbHelpers.GetInvoiceByOrderID(orderID)
+	if err != sql.ErrNoRows {
+		return inv, err
+	}
+
+	order, err := dbHelpers.GetInvoiceOrder(orderID)
+	if err != nil {
+		return inv, err
+	}
+	if order.Status != models.Delivered || !order.SmID.Valid {
+		return inv, errNotInvoiceable
+	}
+	items, err := dbHelpers.GetOrderItems(orderID)
+	if err != nil {
+		return inv, err
+	}
+	store, err := dbHelpers.GetStoreDetails(order.SmID.Int)
+	if err != nil {
+		return inv, err
+	}
+
+	inv, err = dbHelpers.InsertInvoice(order.SmID.Int, invoice.New(order, items, store))
+	if err == sql.ErrNoRows {
+		// invoiced by a concurrent request
+		return dbHelpers.GetInvoiceByOrderID(orderID)
+	}
+	if err != nil {
+		return inv, err
+	}
+	go uploadInvoicePDF(inv)
+	return inv, nil
+}
+
+//uploadInvoicePDF stores a copy of the invoice pdf in firebase storage
+func uploadInvoicePDF(inv models.Invoice) {
+	file, err := invoice.PDF(inv)
+	if err != nil {
+		logrus.Errorf("uploadInvoicePDF: failed to render invoice %s error: %v", inv.Number, err)
+		return
+	}
+	uploadedFileName, err := firebase.UploadToFirebase(file, invoiceFileName(inv))
+	if err != nil {
+		logrus.Errorf("uploadInvoicePDF: failed to upload invoice %s error: %v", inv.Number, err)
+		return
+	}
+	if err := dbHelpers.SetInvoicePDF(inv.ID, models.BucketLink, uploadedFileName); err != nil {
+		logrus.Errorf("uploadInvoicePDF: failed to store invoice %s path error: %v", inv.Number, err)
+	}
+}
+
+func invoiceFileName(inv models.Invoice) string {
+	return strings.ReplaceAll(inv.Number, "/", "-") + ".pdf"
+}
+
+//GetOrderInvoice GET /order/invoice/{id}?format=pdf|json returns the invoice of the delivered order
+//to its user or its store manager
+func GetOrderInvoice(w http.ResponseWriter, r *http.Request) {
+	userCtx := middlewares.UserContext(r)
+	orderID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid order id")
+		return
+	}
+
+	order, err := dbHelpers.GetInvoiceOrder(orderID)
+	if err != nil && err != sql.ErrNoRows {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "something went wrong")
+		return
+	}
+	allowed := order.UserID == userCtx.ID
+	if userCtx.Permission == models.StoreManager {
+		allowed = order.SmID.Valid && order.SmID.Int == userCtx.ID
+	}
+	if err == sql.ErrNoRows || !allowed {
+		err := fmt.Errorf("order not found")
+		utils.RespondError(w, http.StatusNotFound, err, err.Error())
+		return
+	}
+
+	inv, err := GenerateInvoice(orderID)
+	if err != nil {
+		if err == errNotInvoiceable {
+			utils.RespondError(w, http.StatusConflict, err, err.Error())
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to generate invoice")
+		return
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		if inv.Path.Valid {
+			inv.PDFURL, err = firebase.GetURL(&models.Image{Bucket: inv.Bucket.String, Path: inv.Path.String})
+			if err != nil {
+				logrus.Errorf("GetOrderInvoice: failed to sign url of invoice %s error: %v", inv.Number, err)
+			}
+		}
+		utils.RespondJSON(w, http.StatusOK, inv)
+	case "pdf":
+		file, err := invoice.PDF(inv)
+		if err != nil {
+			utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to render invoice")
+			return
+		}
+		w.Header().Set("Content-Disposition", "attachment; filename="+invoiceFileName(inv))
+		w.Header().Set("Content-Type", "application/pdf")
+		if _, err := w.Write(file); err != nil {
+			logrus.Errorf("GetOrderInvoice: failed to write invoice %s error: %v", inv.Number, err)
+		}
+	default:
+		err := fmt.Errorf("invalid value for format")
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+	}
+}
+
+//GetStoreDetails returns the store details printed on the store manager's invoices
+func GetStoreDetails(w http.ResponseWriter, r *http.Request) {
+	smID := middlewares.UserContext(r).ID
+	details, err := dbHelpers.GetStoreDetails(smID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch store details")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, details)
+}
+
+//UpdateStoreDetails updates the store details, already issued invoices keep the details they were issued with
+func UpdateStoreDetails(w http.ResponseWriter, r *http.Request) {
+	smID := middlewares.UserContext(r).ID
+	details := models.StoreDetails{}
+	if err := utils.ParseBody(r.Body, &details); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "unable to parse req body")
+		return
+	}
+	details.Name = strings.TrimSpace(details.Name)
+	details.InvoicePrefix = strings.ToUpper(strings.TrimSpace(details.InvoicePrefix))
+	if details.Name == "" {
+		err := fmt.Errorf("name can't be empty")
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
+	if details.InvoicePrefix == "" {
+		details.InvoicePrefix = "YD"
+	}
+	if strings.Contains(details.InvoicePrefix, "/") || len(details.InvoicePrefix) > 10 {
+		err := fmt.Errorf("invoice prefix can't contain '/' or be longer than 10 characters")
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
+
+	if err := dbHelpers.UpsertStoreDetails(smID, details); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to update store details")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, details)
+}
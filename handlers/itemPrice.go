@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/RemoteState/yourdaily-server/dbHelpers"
+	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/RemoteState/yourdaily-server/utils"
+	"github.com/go-chi/chi"
+	"github.com/volatiletech/null"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+//GetItemPrices GET /api/store-manager/item/{id}/price returns the price history of the item and its variants,
+//scheduled changes included
+func GetItemPrices(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid item id")
+		return
+	}
+	prices, err := dbHelpers.GetItemPrices(itemID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch price history")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, prices)
+}
+
+//GetItemPrice GET /api/store-manager/item/price/{id} returns the price version an order item references as priceId
+func GetItemPrice(w http.ResponseWriter, r *http.Request) {
+	priceID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid price id")
+		return
+	}
+	price, err := dbHelpers.GetItemPrice(priceID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, http.StatusNotFound, err, "price not found")
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch price")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, price)
+}
+
+//ScheduleItemPrice POST /api/store-manager/item/{id}/price changes the price of the item, or of the variant given,
+//from effectiveFrom, right away when it is not given
+func ScheduleItemPrice(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid item id")
+		return
+	}
+	reqBody := struct {
+		VariantID          null.Int     `json:"variantId"`
+		Price              float32      `json:"price"`
+		StrikeThroughPrice null.Float32 `json:"strikeThroughPrice"`
+		EffectiveFrom      null.Time    `json:"effectiveFrom"`
+	}{}
+	if err := utils.ParseBody(r.Body, &reqBody); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "unable to parse req body")
+		return
+	}
+	if reqBody.Price < 0 || (reqBody.StrikeThroughPrice.Valid && reqBody.StrikeThroughPrice.Float32 < 0) {
+		err := fmt.Errorf("price can't be negative")
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
+	price := models.ItemPrice{
+		ItemID:             itemID,
+		VariantID:          reqBody.VariantID,
+		Price:              reqBody.Price,
+		StrikeThroughPrice: reqBody.StrikeThroughPrice,
+		EffectiveFrom:      time.Now(),
+	}
+	if reqBody.EffectiveFrom.Valid {
+		if reqBody.EffectiveFrom.Time.Before(time.Now().Add(-time.Minute)) {
+			err := fmt.Errorf("effectiveFrom can't be in the past")
+			utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+			return
+		}
+		price.EffectiveFrom = reqBody.EffectiveFrom.Time
+	}
+
+	priceID, err := dbHelpers.ScheduleItemPrice(price)
+	if err != nil {
+		if errors.Is(err, dbHelpers.ErrItemNotFound) {
+			utils.RespondError(w, http.StatusNotFound, err, err.Error())
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to schedule price")
+		return
+	}
+	price, err = dbHelpers.GetItemPrice(priceID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch price")
+		return
+	}
+	utils.RespondJSON(w, http.StatusCreated, price)
+}
+
+//CancelItemPrice DELETE /api/store-manager/item/price/{id} cancels a scheduled price change not applied yet
+func CancelItemPrice(w http.ResponseWriter, r *http.Request) {
+	priceID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid price id")
+		return
+	}
+	if err := dbHelpers.CancelItemPrice(priceID); err != nil {
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, http.StatusNotFound, err, "no scheduled price change found")
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to cancel price change")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
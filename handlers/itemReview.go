@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/RemoteState/yourdaily-server/dbHelpers"
+	"github.com/RemoteState/yourdaily-server/middlewares"
+	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/RemoteState/yourdaily-server/utils"
+	"github.com/go-chi/chi"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+//SaveItemReview PUT /api/user/item/{id}/review rates and reviews an item the user received,
+//a review with text is shown once a store manager approves it
+func SaveItemReview(w http.ResponseWriter, r *http.Request) {
+	userID := middlewares.UserContext(r).ID
+	itemID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid item id")
+		return
+	}
+
+	reqBody := struct {
+		Rating int    `json:"rating"`
+		Review string `json:"review"`
+	}{}
+	if err := utils.ParseBody(r.Body, &reqBody); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "unable to parse req body")
+		return
+	}
+	reqBody.Review = strings.TrimSpace(reqBody.Review)
+	switch {
+	case reqBody.Rating < 1 || reqBody.Rating > 5:
+		err = fmt.Errorf("rating must be from 1 to 5")
+	case utf8.RuneCountInString(reqBody.Review) > models.MaxItemReviewLength:
+		err = fmt.Errorf("review can't be longer than %d characters", models.MaxItemReviewLength)
+	}
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
+
+	reviewID, err := dbHelpers.SaveItemReview(userID, itemID, reqBody.Rating, reqBody.Review)
+	if err != nil {
+		if err == dbHelpers.ErrReviewNotVerified {
+			utils.RespondError(w, http.StatusForbidden, err, "only items received in a delivered order can be reviewed")
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to save review")
+		return
+	}
+
+	review, err := dbHelpers.GetItemReview(reviewID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch review")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, review)
+}
+
+//GetItemReviews GET /api/user/item/{id}/review?offset=&limit= lists the approved reviews of an item
+func GetItemReviews(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid item id")
+		return
+	}
+	offset, limit, err := utils.GetOffsetLimit(r)
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid value for offset or limit")
+		return
+	}
+
+	reviews, err := dbHelpers.GetItemReviews(itemID, offset, limit)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch reviews")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, reviews)
+}
+
+//GetMyItemReview GET /api/user/item/{id}/review/mine returns the user's review of an item with its moderation status
+func GetMyItemReview(w http.ResponseWriter, r *http.Request) {
+	userID := middlewares.UserContext(r).ID
+	itemID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid item id")
+		return
+	}
+
+	review, err := dbHelpers.GetUserItemReview(userID, itemID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, http.StatusNotFound, err, "review not found")
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch review")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, review)
+}
+
+//ArchiveItemReview DELETE /api/user/item/{id}/review removes the user's review of an item
+func ArchiveItemReview(w http.ResponseWriter, r *http.Request) {
+	userID := middlewares.UserContext(r).ID
+	itemID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid item id")
+		return
+	}
+
+	if err := dbHelpers.ArchiveItemReview(userID, itemID); err != nil {
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, http.StatusNotFound, err, "review not found")
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to remove review")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, models.Response{Success: true})
+}
+
+//GetItemReviewsForModeration GET /api/store-manager/review?status=pending|approved|rejected&offset=&limit=
+//lists the reviews of all items, pending ones by default
+func GetItemReviewsForModeration(w http.ResponseWriter, r *http.Request) {
+	offset, limit, err := utils.GetOffsetLimit(r)
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid value for offset or limit")
+		return
+	}
+
+	status := models.ItemReviewStatus(r.URL.Query().Get("status"))
+	if status == "" {
+		status = models.PendingItemReview
+	}
+	if !models.IsValidItemReviewStatus(status) {
+		err := fmt.Errorf("invalid value for status")
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
+
+	reviews, err := dbHelpers.GetItemReviewsByStatus(status, offset, limit)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch reviews")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, reviews)
+}
+
+//ModerateItemReview PUT /api/store-manager/review/{id} approves or rejects a review with an optional note
+func ModerateItemReview(w http.ResponseWriter, r *http.Request) {
+	smID := middlewares.UserContext(r).ID
+	reviewID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid review id")
+		return
+	}
+
+	reqBody := struct {
+		Status models.ItemReviewStatus `json:"status"`
+		Note   string                  `json:"note"`
+	}{}
+	if err := utils.ParseBody(r.Body, &reqBody); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "unable to parse req body")
+		return
+	}
+	if reqBody.Status != models.ApprovedItemReview && reqBody.Status != models.RejectedItemReview {
+		err := fmt.Errorf("status must be approved or rejected")
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
+
+	err = dbHelpers.ModerateItemReview(reviewID, smID, reqBody.Status, strings.TrimSpace(reqBody.Note))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, http.StatusNotFound, err, "review not found")
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to moderate review")
+		return
+	}
+
+	review, err := dbHelpers.GetItemReview(reviewID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch review")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, review)
+}
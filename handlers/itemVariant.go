@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/RemoteState/yourdaily-server/dbHelpers"
+	"github.com/RemoteState/yourdaily-server/firebase"
+	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/RemoteState/yourdaily-server/pricing"
+	"github.com/RemoteState/yourdaily-server/utils"
+	"github.com/go-chi/chi"
+	"github.com/lib/pq"
+	"github.com/volatiletech/null"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+//variantRequest is a variant sent by the store manager, ImageID is an image uploaded for the variant
+type variantRequest struct {
+	Name               string               `json:"name"`
+	Quantity           float32              `json:"quantity"`
+	Unit               models.UnitOfMeasure `json:"unit"`
+	Price              float32              `json:"price"`
+	StrikeThroughPrice null.Float32         `json:"strikeThroughPrice"`
+	InStock            bool                 `json:"inStock"`
+	ImageID            null.Int             `json:"imageId"`
+}
+
+//CreateItemVariant POST /api/store-manager/item/{id}/variant adds a pack size to the item
+func CreateItemVariant(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid item id")
+		return
+	}
+	variant, imageID, err := parseVariant(r)
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
+	variant.ItemID = itemID
+
+	variantID, err := dbHelpers.InsertItemVariant(variant, imageID)
+	if err != nil {
+		respondVariantSaveError(w, err)
+		return
+	}
+	respondVariant(w, http.StatusCreated, variantID)
+}
+
+//ModifyItemVariant PUT /api/store-manager/item/variant/{id} updates the variant, its image is replaced when one is given
+func ModifyItemVariant(w http.ResponseWriter, r *http.Request) {
+	variantID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid variant id")
+		return
+	}
+	variant, imageID, err := parseVariant(r)
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
+	variant.ID = variantID
+
+	if err := dbHelpers.ModifyItemVariant(variant, imageID); err != nil {
+		respondVariantSaveError(w, err)
+		return
+	}
+	if variant.InStock {
+		go notifyBackInStock()
+	}
+	respondVariant(w, http.StatusOK, variantID)
+}
+
+//ArchiveItemVariant DELETE /api/store-manager/item/variant/{id} stops selling the variant, orders keep their snapshot of it
+func ArchiveItemVariant(w http.ResponseWriter, r *http.Request) {
+	variantID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid variant id")
+		return
+	}
+	if err := dbHelpers.ArchiveItemVariant(variantID); err != nil {
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, http.StatusNotFound, err, "variant not found")
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to archive variant")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func parseVariant(r *http.Request) (models.ItemVariant, null.Int, error) {
+	reqBody := variantRequest{}
+	if err := utils.ParseBody(r.Body, &reqBody); err != nil {
+		return models.ItemVariant{}, null.Int{}, err
+	}
+	variant := models.ItemVariant{
+		Name:               strings.TrimSpace(reqBody.Name),
+		Quantity:           reqBody.Quantity,
+		Unit:               reqBody.Unit,
+		Price:              reqBody.Price,
+		StrikeThroughPrice: reqBody.StrikeThroughPrice,
+		InStock:            reqBody.InStock,
+	}
+	switch {
+	case !models.IsValidUnit(variant.Unit):
+		return variant, reqBody.ImageID, fmt.Errorf("invalid unit %q", variant.Unit)
+	case variant.Quantity <= 0:
+		return variant, reqBody.ImageID, fmt.Errorf("quantity must be more than 0")
+	case variant.Price < 0:
+		return variant, reqBody.ImageID, fmt.Errorf("price can't be negative")
+	}
+	if variant.Name == "" {
+		variant.Name = fmt.Sprintf("%s %s", strconv.FormatFloat(float64(variant.Quantity), 'f', -1, 32), variant.Unit)
+	}
+	return variant, reqBody.ImageID, nil
+}
+
+func respondVariantSaveError(w http.ResponseWriter, err error) {
+	var pqErr *pq.Error
+	switch {
+	case errors.Is(err, dbHelpers.ErrItemNotFound), err == sql.ErrNoRows:
+		utils.RespondError(w, http.StatusNotFound, err, err.Error())
+	case errors.As(err, &pqErr) && pqErr.Code == "23505":
+		utils.RespondError(w, http.StatusConflict, err, "the item already has a variant of this size")
+	case errors.As(err, &pqErr) && pqErr.Code == "23503":
+		utils.RespondError(w, http.StatusBadRequest, err, "image not found")
+	default:
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to save variant")
+	}
+}
+
+func respondVariant(w http.ResponseWriter, status, variantID int) {
+	variant, err := dbHelpers.GetItemVariant(variantID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch variant")
+		return
+	}
+	if err := setVariantDetails(&variant); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "Failed in getting image URL")
+		return
+	}
+	utils.RespondJSON(w, status, variant)
+}
+
+//setVariantDetails fills the price per unit of the variant and signs its image urls
+func setVariantDetails(variant *models.ItemVariant) error {
+	pricing.Variant(variant)
+	variant.ItemImageLinks = make([]string, 0, len(variant.Images))
+	for i := range variant.Images {
+		imageURL, err := firebase.GetURL(&variant.Images[i])
+		if err != nil {
+			return err
+		}
+		variant.ItemImageLinks = append(variant.ItemImageLinks, imageURL)
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"database/sql"
+	"fmt"
 	"github.com/RemoteState/yourdaily-server/dbHelpers"
 	"github.com/RemoteState/yourdaily-server/firebase"
 	"github.com/RemoteState/yourdaily-server/models"
@@ -9,6 +10,9 @@ import (
 	"github.com/go-chi/chi"
 	"github.com/volatiletech/null"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 )
 
 func CreateItem(w http.ResponseWriter, r *http.Request) {
@@ -64,28 +68,18 @@ func CreateItem(w http.ResponseWriter, r *http.Request) {
 
 func GetAllItems(w http.ResponseWriter, r *http.Request) {
 
-	items, err := dbHelpers.GetItems(false)
+	items, err := dbHelpers.GetItems(false, true)
 	if err != nil {
 		utils.RespondError(w, http.StatusInternalServerError, err, "Failed to get item entries")
 		return
 	}
-	for i := range items {
-		imagesInfo, err := dbHelpers.GetImageInfoByItemID(items[i].ID)
-		if err != nil {
-			utils.RespondError(w, http.StatusInternalServerError, err, "Failed in generating image link")
-			return
-		}
-
-		imageUrls := make([]string, 0)
-		for j := range imagesInfo {
-			url, err := firebase.GetURL(&imagesInfo[j])
-			if err != nil {
-				utils.RespondError(w, http.StatusInternalServerError, err, "Failed in getting image URL")
-				return
-			}
-			imageUrls = append(imageUrls, url)
-		}
-		items[i].ItemImageLinks = imageUrls
+	if err := setItemImageLinks(items); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, "Failed in getting image URL")
+		return
+	}
+	if err := setItemVariants(items); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, "Failed to get item variants")
+		return
 	}
 	utils.RespondJSON(w, http.StatusOK, items)
 }
@@ -114,6 +108,9 @@ func ModifyItem(w http.ResponseWriter, r *http.Request) {
 		utils.RespondError(w, http.StatusInternalServerError, err, "Failed to update item entry")
 		return
 	}
+	if reqBody.InStock {
+		go notifyBackInStock()
+	}
 
 	item, err := dbHelpers.GetItemById(itemID)
 	if err != nil {
@@ -204,6 +201,28 @@ func AddImageForExistingItem(w http.ResponseWriter, r *http.Request) {
 	utils.RespondJSON(w, http.StatusOK, response)
 }
 
+//GetVisibleItemById GET /api/user/item/{id}
+//returns the item like GetItemById unless its category is hidden from users
+func GetVisibleItemById(w http.ResponseWriter, r *http.Request) {
+
+	itemID, err := utils.StringToInt(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, "Failed to convert given itemID to int")
+		return
+	}
+
+	visible, err := dbHelpers.IsItemVisible(itemID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, "Failed to get item")
+		return
+	}
+	if !visible {
+		utils.RespondError(w, http.StatusNotFound, sql.ErrNoRows, "item not found")
+		return
+	}
+	GetItemById(w, r)
+}
+
 func GetItemById(w http.ResponseWriter, r *http.Request) {
 
 	itemID, err := utils.StringToInt(chi.URLParam(r, "id"))
@@ -233,5 +252,153 @@ func GetItemById(w http.ResponseWriter, r *http.Request) {
 		item.ItemImageLinks = append(item.ItemImageLinks, url)
 	}
 
-	utils.RespondJSON(w, http.StatusOK, item)
+	items := []models.Item{*item}
+	if err := setItemVariants(items); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, "Failed to get item variants")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, items[0])
+}
+
+//SearchItems GET /api/user/item/search?q=&category=1,2&minPrice=&maxPrice=&inStock=true&sort=relevance&cursor=&limit=
+//searches the catalogue, pass nextCursor of a page as cursor to get the next one
+func SearchItems(w http.ResponseWriter, r *http.Request) {
+	search := models.ItemSearch{
+		Query:       strings.TrimSpace(r.URL.Query().Get("q")),
+		VisibleOnly: true,
+	}
+	respondItemPage(w, r, search)
+}
+
+//ListItems GET /api/user/item/list?category=1,2&minPrice=&maxPrice=&sort=newest&cursor=&limit=
+//returns a page of the catalogue in stock with the image links, pass nextCursor of a page as cursor to get the next one
+func ListItems(w http.ResponseWriter, r *http.Request) {
+	respondItemPage(w, r, models.ItemSearch{InStockOnly: true, VisibleOnly: true})
+}
+
+//ListItemsForStoreManager GET /api/store-manager/item/list?category=1,2&minPrice=&maxPrice=&inStock=&sort=newest&cursor=&limit=
+//returns a page of the catalogue including items out of stock
+func ListItemsForStoreManager(w http.ResponseWriter, r *http.Request) {
+	respondItemPage(w, r, models.ItemSearch{})
+}
+
+//respondItemPage completes the search with the sort, filters and page of the request and responds with the page of items
+func respondItemPage(w http.ResponseWriter, r *http.Request, search models.ItemSearch) {
+	params := r.URL.Query()
+	search.Sort = models.ItemSort(params.Get("sort"))
+	search.Cursor = params.Get("cursor")
+	search.Limit = models.DefaultItemPageSize
+	if search.Sort == "" {
+		search.Sort = models.NewestItemSort
+		if search.Query != "" {
+			search.Sort = models.RelevanceItemSort
+		}
+	}
+	if !dbHelpers.IsValidItemSort(search.Sort) {
+		err := fmt.Errorf("invalid value for sort")
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
+	if err := parseItemFilters(params, &search); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
+
+	page, err := dbHelpers.SearchItems(search)
+	if err != nil {
+		if err == dbHelpers.ErrInvalidCursor {
+			utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to get items")
+		return
+	}
+	if err := setItemImageLinks(page.Items); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "Failed in getting image URL")
+		return
+	}
+	if err := setItemVariants(page.Items); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "Failed to get item variants")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, page)
+}
+
+//parseItemFilters reads the category, price, stock and page size filters of the catalogue,
+//inStock can only narrow the search to the items in stock
+func parseItemFilters(params url.Values, search *models.ItemSearch) error {
+	if categories := params.Get("category"); categories != "" {
+		for _, category := range strings.Split(categories, ",") {
+			categoryID, err := strconv.ParseInt(strings.TrimSpace(category), 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid value for category")
+			}
+			search.CategoryIDs = append(search.CategoryIDs, categoryID)
+		}
+	}
+	for name, price := range map[string]*null.Float32{"minPrice": &search.MinPrice, "maxPrice": &search.MaxPrice} {
+		if value := params.Get(name); value != "" {
+			parsed, err := strconv.ParseFloat(value, 32)
+			if err != nil || parsed < 0 {
+				return fmt.Errorf("invalid value for %s", name)
+			}
+			*price = null.Float32From(float32(parsed))
+		}
+	}
+	if search.MinPrice.Valid && search.MaxPrice.Valid && search.MinPrice.Float32 > search.MaxPrice.Float32 {
+		return fmt.Errorf("minPrice can't be more than maxPrice")
+	}
+	if inStock := params.Get("inStock"); inStock != "" {
+		parsed, err := strconv.ParseBool(inStock)
+		if err != nil {
+			return fmt.Errorf("invalid value for inStock")
+		}
+		// an endpoint listing only the items in stock can't be widened by the request
+		search.InStockOnly = search.InStockOnly || parsed
+	}
+	if limit := params.Get("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed <= 0 || parsed > models.MaxItemPageSize {
+			return fmt.Errorf("limit must be between 1 and %d", models.MaxItemPageSize)
+		}
+		search.Limit = parsed
+	}
+	return nil
+}
+
+//setItemImageLinks signs the urls of the images loaded along with the items
+func setItemImageLinks(items []models.Item) error {
+	for i := range items {
+		items[i].ItemImageLinks = make([]string, 0, len(items[i].Images))
+		for j := range items[i].Images {
+			imageURL, err := firebase.GetURL(&items[i].Images[j])
+			if err != nil {
+				return err
+			}
+			items[i].ItemImageLinks = append(items[i].ItemImageLinks, imageURL)
+		}
+	}
+	return nil
+}
+
+//setItemVariants attaches the variants of the items, fetched in one query, with their price per unit and image links
+func setItemVariants(items []models.Item) error {
+	itemIDs := make([]int, 0, len(items))
+	for i := range items {
+		itemIDs = append(itemIDs, items[i].ID)
+	}
+	variants, err := dbHelpers.GetItemVariants(itemIDs)
+	if err != nil {
+		return err
+	}
+	for i := range items {
+		items[i].Variants = make([]models.ItemVariant, 0, len(variants[items[i].ID]))
+		for _, variant := range variants[items[i].ID] {
+			if err := setVariantDetails(&variant); err != nil {
+				return err
+			}
+			items[i].Variants = append(items[i].Variants, variant)
+		}
+	}
+	return nil
 }
@@ -1,16 +1,21 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/RemoteState/yourdaily-server/dbHelpers"
 	"github.com/RemoteState/yourdaily-server/firebase"
 	"github.com/RemoteState/yourdaily-server/middlewares"
 	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/RemoteState/yourdaily-server/pricing"
+	"github.com/RemoteState/yourdaily-server/promotions"
 	"github.com/RemoteState/yourdaily-server/utils"
 	"github.com/go-chi/chi"
 	"github.com/sirupsen/logrus"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -91,6 +96,12 @@ func OrderNow(w http.ResponseWriter, r *http.Request) {
 	newOrder.StoreMangerID = smId
 
 	newOrder.Items = utils.FilterOrderItems(newOrder.Items)
+	newOrder.CouponCode = strings.TrimSpace(newOrder.CouponCode)
+	if newOrder.CouponCode != "" && newOrder.Mode == models.CartMode {
+		err := fmt.Errorf("coupons can only be applied to orders with items")
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
 
 	orderID, err := dbHelpers.InsertIntoOrders(newOrder)
 	if err != nil {
@@ -104,6 +115,63 @@ func OrderNow(w http.ResponseWriter, r *http.Request) {
 	}{orderID})
 }
 
+//QuoteOrder POST /api/user/order/quote prices the items for the address the same way OrderNow would, without placing the order
+func QuoteOrder(w http.ResponseWriter, r *http.Request) {
+	userID := middlewares.UserContext(r).ID
+	newOrder := models.Order{
+		UserID:       userID,
+		Mode:         models.DeliveryMode,
+		DeliveryTime: time.Now().Format(time.RFC3339Nano),
+	}
+	if err := utils.ParseBody(r.Body, &newOrder); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, "Failed to decode request body")
+		return
+	}
+	newOrder.Items = utils.FilterOrderItems(newOrder.Items)
+	if len(newOrder.Items) == 0 {
+		err := fmt.Errorf("items are required for a quote")
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
+	for _, item := range newOrder.Items {
+		if item.Quantity <= 0 {
+			err := fmt.Errorf("invalid quantity for item %d", item.Id)
+			utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+			return
+		}
+	}
+	newOrder.CouponCode = strings.TrimSpace(newOrder.CouponCode)
+
+	addressLocation, err := dbHelpers.SelectAddressWithID(newOrder.UserID, newOrder.AddressID, false)
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid address id")
+		return
+	}
+	newOrder.StoreMangerID, err = dbHelpers.StoreManagerNearMe(addressLocation.Lat, addressLocation.Long)
+	if err != nil {
+		utils.RespondError(w, http.StatusNotAcceptable, err, err.Error(), err.Error())
+		return
+	}
+
+	quote, err := dbHelpers.QuoteOrder(newOrder)
+	if err != nil {
+		switch {
+		case errors.Is(err, dbHelpers.ErrItemNotFound):
+			utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		case errors.Is(err, dbHelpers.ErrItemUnavailable):
+			utils.RespondError(w, http.StatusConflict, err, err.Error())
+		case err == promotions.ErrInvalidCoupon:
+			utils.RespondError(w, http.StatusNotFound, err, err.Error())
+		case promotions.IsCouponError(err), errors.Is(err, pricing.ErrMinOrderValue):
+			utils.RespondError(w, http.StatusUnprocessableEntity, err, err.Error())
+		default:
+			utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to quote order")
+		}
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, quote)
+}
+
 //OrderStatus Get /api/user/order/status/{id}
 func OrderStatus(w http.ResponseWriter, r *http.Request) {
 	orderID, err := strconv.Atoi(chi.URLParam(r, "id"))
@@ -143,6 +211,59 @@ func OrderInfo(w http.ResponseWriter, r *http.Request) {
 	utils.RespondJSON(w, 200, orderDetails)
 }
 
+//ResendOTP POST /api/user/order/otp/{id} regenerates the delivery otp of an order that is out for delivery
+func ResendOTP(w http.ResponseWriter, r *http.Request) {
+	userID := middlewares.UserContext(r).ID
+	orderID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), err.Error())
+		return
+	}
+
+	order, err := dbHelpers.SelectOrder(orderID, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, http.StatusNotFound, err, "order not found")
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), err.Error())
+		return
+	}
+	if order.Status != models.OutForDelivery {
+		err := fmt.Errorf("otp can only be regenerated once the order is out for delivery")
+		utils.RespondError(w, http.StatusConflict, err, err.Error())
+		return
+	}
+
+	currentOTP, err := dbHelpers.GetOrderOTP(orderID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), err.Error())
+		return
+	}
+	if currentOTP.LockedAt.Valid {
+		err := fmt.Errorf("otp locked after too many wrong attempts, contact store manager")
+		utils.RespondError(w, http.StatusLocked, err, err.Error())
+		return
+	}
+	if currentOTP.ResendCount >= models.MaxOTPResends {
+		err := fmt.Errorf("otp can't be regenerated more than %d times", models.MaxOTPResends)
+		utils.RespondError(w, http.StatusTooManyRequests, err, err.Error())
+		return
+	}
+
+	newOTP, err := dbHelpers.ResendOTP(orderID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err := fmt.Errorf("otp can't be regenerated anymore, contact store manager")
+			utils.RespondError(w, http.StatusConflict, err, err.Error())
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to regenerate otp")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, newOTP)
+}
+
 //AllPastOrder Get /api/user/order
 func AllPastOrder(w http.ResponseWriter, r *http.Request) {
 	userID := middlewares.UserContext(r).ID
@@ -210,6 +331,7 @@ func CancelOrder(w http.ResponseWriter, r *http.Request) {
 			firebase.OrderStatusUpdateNotification(int64(staffID.Int), orderID, models.Cancelled, address.AddressData)
 		}
 	}()
+	go ReleaseOrderContacts(orderID)
 	utils.RespondJSON(w, http.StatusOK, models.Response{
 		Success: true,
 	})
@@ -250,6 +372,14 @@ func PostScheduledOrder(w http.ResponseWriter, r *http.Request) {
 
 	scheduledOrderID, err := dbHelpers.InsertScheduledOrder(newOrder)
 	if err != nil {
+		if errors.Is(err, pricing.ErrMinOrderValue) {
+			utils.RespondError(w, http.StatusUnprocessableEntity, err, err.Error())
+			return
+		}
+		if errors.Is(err, dbHelpers.ErrItemNotFound) || errors.Is(err, dbHelpers.ErrItemUnavailable) {
+			utils.RespondError(w, http.StatusConflict, err, err.Error())
+			return
+		}
 		utils.RespondError(w, http.StatusInternalServerError, err, "Failed to insert scheduled order")
 		return
 	}
@@ -347,6 +477,44 @@ func ConfirmOrderDelivery(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+//ConfirmOrderBill PUT /api/user/order/bill/{id} confirms or rejects the bill submitted by the staff for a cart order
+func ConfirmOrderBill(w http.ResponseWriter, r *http.Request) {
+	userID := middlewares.UserContext(r).ID
+	orderID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid order id")
+		return
+	}
+	reqBody := struct {
+		Confirm bool `json:"confirm"`
+	}{}
+	if err := utils.ParseBody(r.Body, &reqBody); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "unable to parse req body")
+		return
+	}
+
+	err = dbHelpers.ConfirmOrderBill(orderID, userID, reqBody.Confirm)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, http.StatusNotFound, err, "no bill pending confirmation for this order")
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to update bill")
+		return
+	}
+
+	bill, err := getOrderBillWithItems(orderID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch bill")
+		return
+	}
+	if !reqBody.Confirm && bill.StaffID.Valid {
+		go firebase.OrderBillNotification(bill.StaffID.Int, orderID, "Bill rejected",
+			fmt.Sprintf("User rejected the bill of order %d, please submit it again", orderID))
+	}
+	utils.RespondJSON(w, http.StatusOK, bill)
+}
+
 //OrderInfoForStaff Returns an object of order detail for given order id
 func OrderInfoForStaff(w http.ResponseWriter, r *http.Request) {
 	staffID := middlewares.UserContext(r).ID
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/RemoteState/yourdaily-server/dbHelpers"
+	"github.com/RemoteState/yourdaily-server/firebase"
+	"github.com/RemoteState/yourdaily-server/middlewares"
+	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/RemoteState/yourdaily-server/utils"
+	"github.com/go-chi/chi"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+//ProposeOrderItemChange POST /api/staff/order/item-change/{id} proposes a substitute or a lower quantity
+//for an unavailable item of a delivery order, the user has to approve it
+func ProposeOrderItemChange(w http.ResponseWriter, r *http.Request) {
+	staffID := middlewares.UserContext(r).ID
+	orderID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid order id")
+		return
+	}
+
+	change := models.OrderItemChange{}
+	if err := utils.ParseBody(r.Body, &change); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "unable to parse req body")
+		return
+	}
+	change.OrderID, change.ProposedBy = orderID, staffID
+
+	order, err := dbHelpers.GetOrderBill(orderID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, http.StatusNotFound, err, "order not found")
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "something went wrong")
+		return
+	}
+	if !order.StaffID.Valid || order.StaffID.Int != staffID {
+		err := fmt.Errorf("order not found")
+		utils.RespondError(w, http.StatusNotFound, err, err.Error())
+		return
+	}
+	if order.Mode != models.DeliveryMode {
+		err := fmt.Errorf("items can only be changed for delivery orders")
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
+	if order.Status != models.Accepted && order.Status != models.OutForDelivery {
+		err := fmt.Errorf("items can't be changed for %s order", order.Status)
+		utils.RespondError(w, http.StatusConflict, err, err.Error())
+		return
+	}
+
+	items, err := dbHelpers.GetOrderItems(orderID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch order items")
+		return
+	}
+	var orderItem *models.ItemInfo
+	for i := range items {
+		if items[i].OrderItemID == change.OrderItemID {
+			orderItem = &items[i]
+		}
+	}
+	if orderItem == nil || orderItem.Quantity == 0 {
+		err := fmt.Errorf("order item not found")
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
+
+	switch change.Type {
+	case models.ReduceItemChange:
+		change.SubstituteItemID.Valid = false
+		if change.Quantity < 0 || change.Quantity >= orderItem.Quantity {
+			err := fmt.Errorf("quantity must be less than the ordered %d", orderItem.Quantity)
+			utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+			return
+		}
+	case models.SubstituteItemChange:
+		if !change.SubstituteItemID.Valid || change.SubstituteItemID.Int == orderItem.Id || change.Quantity <= 0 {
+			err := fmt.Errorf("a different substitute item and quantity are required")
+			utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+			return
+		}
+		item, err := dbHelpers.GetItemById(change.SubstituteItemID.Int)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				utils.RespondError(w, http.StatusBadRequest, err, "substitute item not found")
+				return
+			}
+			utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch substitute item")
+			return
+		}
+		if !item.InStock {
+			err := fmt.Errorf("substitute item is out of stock")
+			utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+			return
+		}
+	default:
+		err := fmt.Errorf("invalid change type")
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
+
+	changeID, err := dbHelpers.InsertOrderItemChange(change)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, http.StatusConflict, err, "item already has a pending change")
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to propose change")
+		return
+	}
+	change, err = dbHelpers.GetOrderItemChange(changeID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch change")
+		return
+	}
+
+	message := fmt.Sprintf("%s is unavailable, %s instead?", change.ItemName, change.SubstituteName.String)
+	if change.Type == models.ReduceItemChange {
+		message = fmt.Sprintf("Only %d of %s is available, is that ok?", change.Quantity, change.ItemName)
+	}
+	go firebase.OrderItemChangeNotification(order.UserID, orderID, changeID, "Change in your order", message)
+	utils.RespondJSON(w, http.StatusCreated, change)
+}
+
+//GetOrderItemChanges returns the item changes of the order to its user or staff
+func GetOrderItemChanges(w http.ResponseWriter, r *http.Request) {
+	userID := middlewares.UserContext(r).ID
+	orderID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid order id")
+		return
+	}
+
+	order, err := dbHelpers.GetOrderBill(orderID)
+	if err != nil && err != sql.ErrNoRows {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "something went wrong")
+		return
+	}
+	if err == sql.ErrNoRows || (order.UserID != userID && (!order.StaffID.Valid || order.StaffID.Int != userID)) {
+		err := fmt.Errorf("order not found")
+		utils.RespondError(w, http.StatusNotFound, err, err.Error())
+		return
+	}
+
+	changes, err := dbHelpers.GetOrderItemChanges(orderID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch changes")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, changes)
+}
+
+//AnswerOrderItemChange PUT /api/user/order/item-change/{id} approves or rejects the change with the given id
+func AnswerOrderItemChange(w http.ResponseWriter, r *http.Request) {
+	userID := middlewares.UserContext(r).ID
+	changeID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid change id")
+		return
+	}
+	reqBody := struct {
+		Approve bool `json:"approve"`
+	}{}
+	if err := utils.ParseBody(r.Body, &reqBody); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "unable to parse req body")
+		return
+	}
+
+	change, err := dbHelpers.GetOrderItemChange(changeID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, http.StatusNotFound, err, "change not found")
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch change")
+		return
+	}
+	order, err := dbHelpers.GetOrderBill(change.OrderID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "something went wrong")
+		return
+	}
+	if order.UserID != userID {
+		err := fmt.Errorf("change not found")
+		utils.RespondError(w, http.StatusNotFound, err, err.Error())
+		return
+	}
+	if change.Status != models.PendingItemChange || time.Now().After(change.ExpiresAt) {
+		err := fmt.Errorf("change is already %s", change.Status)
+		if change.Status == models.PendingItemChange {
+			err = fmt.Errorf("change has expired")
+		}
+		utils.RespondError(w, http.StatusConflict, err, err.Error())
+		return
+	}
+
+	status := models.RejectedItemChange
+	if reqBody.Approve {
+		status = models.ApprovedItemChange
+	}
+	if err := dbHelpers.ResolveOrderItemChange(changeID, status); err != nil {
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, http.StatusConflict, err, "change is not pending anymore")
+			return
+		}
+		if errors.Is(err, dbHelpers.ErrItemNotFound) {
+			utils.RespondError(w, http.StatusConflict, err, "substitute item is not available anymore")
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to update change")
+		return
+	}
+	change.Status = status
+
+	if order.StaffID.Valid {
+		go firebase.OrderItemChangeNotification(order.StaffID.Int, change.OrderID, changeID, "Item change "+string(status),
+			fmt.Sprintf("User %s the change of %s in order %d", status, change.ItemName, change.OrderID))
+	}
+	utils.RespondJSON(w, http.StatusOK, change)
+}
+
+//ExpireOrderItemChanges applies the default to the given changes and lets the user and staff know
+func ExpireOrderItemChanges(changes []models.OrderItemChange) {
+	for _, change := range changes {
+		if err := dbHelpers.ResolveOrderItemChange(change.ID, models.ExpiredItemChange); err != nil {
+			if err != sql.ErrNoRows {
+				logrus.Errorf("ExpireOrderItemChanges: failed to expire change %d error: %v", change.ID, err)
+			}
+			continue
+		}
+		order, err := dbHelpers.GetOrderBill(change.OrderID)
+		if err != nil {
+			logrus.Errorf("ExpireOrderItemChanges: failed to fetch order %d error: %v", change.OrderID, err)
+			continue
+		}
+		message := fmt.Sprintf("No response on %s, it was removed from order %d", change.ItemName, change.OrderID)
+		if change.Type == models.ReduceItemChange {
+			message = fmt.Sprintf("No response on %s, quantity changed to %d in order %d", change.ItemName, change.Quantity, change.OrderID)
+		}
+		go firebase.OrderItemChangeNotification(order.UserID, change.OrderID, change.ID, "Order updated", message)
+		if order.StaffID.Valid {
+			go firebase.OrderItemChangeNotification(order.StaffID.Int, change.OrderID, change.ID, "Item change expired", message)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/RemoteState/yourdaily-server/dbHelpers"
+	"github.com/RemoteState/yourdaily-server/middlewares"
+	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/RemoteState/yourdaily-server/utils"
+	"github.com/go-chi/chi"
+	"github.com/lib/pq"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Reorder POST /api/user/order/reorder/{id} places a new order with the items of a previous order at today's prices,
+//items which can't be ordered anymore are left out and reported
+func Reorder(w http.ResponseWriter, r *http.Request) {
+	userID := middlewares.UserContext(r).ID
+	orderID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid order id")
+		return
+	}
+	reqBody := struct {
+		AddressID  int    `json:"addressId"`
+		CouponCode string `json:"couponCode"`
+	}{}
+	if err := utils.ParseBody(r.Body, &reqBody); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "unable to parse req body")
+		return
+	}
+	previousOrder, err := dbHelpers.SelectOrder(orderID, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, http.StatusNotFound, err, "order not found")
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch order")
+		return
+	}
+	if reqBody.AddressID == 0 {
+		reqBody.AddressID = previousOrder.AddressID
+	}
+
+	lines, err := dbHelpers.GetReorderItems(orderID, userID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch order items")
+		return
+	}
+	orderAvailableItems(w, userID, reqBody.AddressID, strings.TrimSpace(reqBody.CouponCode), lines)
+}
+
+//GetBaskets GET /api/user/basket returns the saved baskets of the user
+func GetBaskets(w http.ResponseWriter, r *http.Request) {
+	userID := middlewares.UserContext(r).ID
+	baskets, err := dbHelpers.GetBaskets(userID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch baskets")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, baskets)
+}
+
+//CreateBasket POST /api/user/basket saves the items as a named basket
+func CreateBasket(w http.ResponseWriter, r *http.Request) {
+	userID := middlewares.UserContext(r).ID
+	name, items, err := parseBasket(r)
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
+	basketID, err := dbHelpers.InsertBasket(userID, name, items)
+	if err != nil {
+		respondBasketSaveError(w, err)
+		return
+	}
+	respondBasket(w, http.StatusCreated, basketID, userID)
+}
+
+//UpdateBasket PUT /api/user/basket/{id} renames the basket and replaces its items
+func UpdateBasket(w http.ResponseWriter, r *http.Request) {
+	userID := middlewares.UserContext(r).ID
+	basketID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid basket id")
+		return
+	}
+	name, items, err := parseBasket(r)
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
+	if err := dbHelpers.UpdateBasket(basketID, userID, name, items); err != nil {
+		respondBasketSaveError(w, err)
+		return
+	}
+	respondBasket(w, http.StatusOK, basketID, userID)
+}
+
+//ArchiveBasket DELETE /api/user/basket/{id} removes the basket
+func ArchiveBasket(w http.ResponseWriter, r *http.Request) {
+	userID := middlewares.UserContext(r).ID
+	basketID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid basket id")
+		return
+	}
+	if err := dbHelpers.ArchiveBasket(basketID, userID); err != nil {
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, http.StatusNotFound, err, "basket not found")
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to remove basket")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, models.Response{Success: true})
+}
+
+//OrderBasket POST /api/user/basket/{id}/order places an order now with the items of the basket
+func OrderBasket(w http.ResponseWriter, r *http.Request) {
+	userID := middlewares.UserContext(r).ID
+	basket, ok := basketFromRequest(w, r, userID)
+	if !ok {
+		return
+	}
+	reqBody := struct {
+		AddressID  int    `json:"addressId"`
+		CouponCode string `json:"couponCode"`
+	}{}
+	if err := utils.ParseBody(r.Body, &reqBody); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "unable to parse req body")
+		return
+	}
+	orderAvailableItems(w, userID, reqBody.AddressID, strings.TrimSpace(reqBody.CouponCode), basket.Items)
+}
+
+//ScheduleBasket POST /api/user/basket/{id}/schedule creates a scheduled order with the items of the basket
+func ScheduleBasket(w http.ResponseWriter, r *http.Request) {
+	userID := middlewares.UserContext(r).ID
+	basket, ok := basketFromRequest(w, r, userID)
+	if !ok {
+		return
+	}
+	newOrder := models.ScheduledOrder{}
+	if err := utils.ParseBody(r.Body, &newOrder); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, "Failed to decode request body")
+		return
+	}
+	if len(newOrder.Weekdays) == 0 {
+		err := fmt.Errorf("weekdays are required")
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
+	items, dropped := availableItems(basket.Items)
+	if len(items) == 0 {
+		respondNothingAvailable(w)
+		return
+	}
+	smID, ok := storeManagerForOrder(w, userID, newOrder.AddressID)
+	if !ok {
+		return
+	}
+
+	newOrder.UserID, newOrder.Mode, newOrder.StoreMangerID, newOrder.Items = userID, models.DeliveryMode, smID, items
+	scheduledOrderID, err := dbHelpers.InsertScheduledOrder(newOrder)
+	if err != nil {
+		respondOrderError(w, err)
+		return
+	}
+	scheduledOrder, err := dbHelpers.GetScheduledOrderById(scheduledOrderID, userID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, "Failed to get scheduled order details")
+		return
+	}
+	utils.RespondJSON(w, http.StatusCreated, struct {
+		*models.ScheduledOrder
+		Dropped []models.DroppedItem `json:"dropped"`
+	}{scheduledOrder, dropped})
+}
+
+//orderAvailableItems places an order now with the items which can still be ordered and reports the ones left out
+func orderAvailableItems(w http.ResponseWriter, userID, addressID int, couponCode string, lines []models.BasketItem) {
+	items, dropped := availableItems(lines)
+	if len(items) == 0 {
+		respondNothingAvailable(w)
+		return
+	}
+	smID, ok := storeManagerForOrder(w, userID, addressID)
+	if !ok {
+		return
+	}
+
+	newOrder := models.Order{
+		UserID:        userID,
+		Mode:          models.DeliveryMode,
+		AddressID:     addressID,
+		DeliveryTime:  time.Now().Format(time.RFC3339Nano),
+		StoreMangerID: smID,
+		Items:         items,
+		CouponCode:    couponCode,
+	}
+	orderID, err := dbHelpers.InsertIntoOrders(newOrder)
+	if err != nil {
+		respondOrderError(w, err)
+		return
+	}
+	go FindAndPing(newOrder.Mode, newOrder.AddressID, newOrder.UserID, orderID)
+
+	utils.RespondJSON(w, http.StatusOK, struct {
+		OrderID int                  `json:"order_id"`
+		Dropped []models.DroppedItem `json:"dropped"`
+	}{orderID, dropped})
+}
+
+//availableItems splits the lines into the items which can be ordered and the ones which can't
+func availableItems(lines []models.BasketItem) ([]models.ItemInfo, []models.DroppedItem) {
+	items, dropped := make([]models.ItemInfo, 0, len(lines)), make([]models.DroppedItem, 0)
+	for _, line := range lines {
+		if line.Available {
+			items = append(items, models.ItemInfo{Id: line.ItemID, VariantID: line.VariantID, Quantity: line.Quantity})
+			continue
+		}
+		reason := models.OutOfStockItemReason
+		if line.ItemID == 0 {
+			reason = models.NotInCatalogueItemReason
+		} else if line.Archived {
+			reason = models.ArchivedItemReason
+		}
+		dropped = append(dropped, models.DroppedItem{ItemID: line.ItemID, VariantID: line.VariantID, Name: line.Name, Quantity: line.Quantity, Reason: reason})
+	}
+	return items, dropped
+}
+
+func respondNothingAvailable(w http.ResponseWriter) {
+	err := fmt.Errorf("none of the items are available anymore")
+	utils.RespondError(w, http.StatusConflict, err, err.Error())
+}
+
+func basketFromRequest(w http.ResponseWriter, r *http.Request, userID int) (models.Basket, bool) {
+	basketID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid basket id")
+		return models.Basket{}, false
+	}
+	basket, err := dbHelpers.GetBasket(basketID, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, http.StatusNotFound, err, "basket not found")
+			return basket, false
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch basket")
+		return basket, false
+	}
+	return basket, true
+}
+
+func parseBasket(r *http.Request) (string, []models.ItemInfo, error) {
+	reqBody := struct {
+		Name  string            `json:"name"`
+		Items []models.ItemInfo `json:"items"`
+	}{}
+	if err := utils.ParseBody(r.Body, &reqBody); err != nil {
+		return "", nil, err
+	}
+	reqBody.Name = strings.TrimSpace(reqBody.Name)
+	if reqBody.Name == "" {
+		return "", nil, fmt.Errorf("name can't be empty")
+	}
+	items := utils.FilterOrderItems(reqBody.Items)
+	if len(items) == 0 {
+		return "", nil, fmt.Errorf("a basket needs at least one item")
+	}
+	for _, item := range items {
+		if item.Quantity <= 0 {
+			return "", nil, fmt.Errorf("invalid quantity for item %d", item.Id)
+		}
+	}
+	return reqBody.Name, items, nil
+}
+
+func respondBasketSaveError(w http.ResponseWriter, err error) {
+	var pqErr *pq.Error
+	switch {
+	case errors.As(err, &pqErr) && pqErr.Code == "23505":
+		utils.RespondError(w, http.StatusConflict, err, "a basket with this name already exists")
+	case errors.Is(err, dbHelpers.ErrItemNotFound):
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+	case err == sql.ErrNoRows:
+		utils.RespondError(w, http.StatusNotFound, err, "basket not found")
+	default:
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to save basket")
+	}
+}
+
+func respondBasket(w http.ResponseWriter, status, basketID, userID int) {
+	basket, err := dbHelpers.GetBasket(basketID, userID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch basket")
+		return
+	}
+	utils.RespondJSON(w, status, basket)
+}
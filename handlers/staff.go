@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
 	"github.com/RemoteState/yourdaily-server/dbHelpers"
@@ -10,6 +11,8 @@ import (
 	"github.com/RemoteState/yourdaily-server/utils"
 	"github.com/go-chi/chi"
 	"github.com/sirupsen/logrus"
+	"github.com/volatiletech/null"
+	"io/ioutil"
 	"net/http"
 	"strconv"
 	"time"
@@ -152,6 +155,11 @@ func PutOutForDelivery(w http.ResponseWriter, r *http.Request) {
 		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "something went wrong")
 		return
 	}
+	// the otp only starts working, and expiring, once the order is on its way
+	if _, err := dbHelpers.ArmOTP(orderID); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to generate otp")
+		return
+	}
 	go func() {
 		order, err := dbHelpers.GetOrderByID(orderID, staffID)
 		if err != nil {
@@ -181,42 +189,346 @@ func PostVerifyOTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	storedOTP, err := dbHelpers.GetOTP(orderID)
+	if _, err := dbHelpers.GetOrderByID(orderID, staffID); err != nil {
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, http.StatusNotFound, err, "order not found")
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "something went wrong")
+		return
+	}
+
+	storedOTP, err := dbHelpers.GetOrderOTP(orderID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "something went wrong")
+		return
+	}
+	if storedOTP.LockedAt.Valid {
+		err := fmt.Errorf("otp locked after too many wrong attempts, contact store manager")
+		utils.RespondError(w, http.StatusLocked, err, err.Error())
+		return
+	}
+	if storedOTP.IsExpired() {
+		err := fmt.Errorf("otp expired, ask the user to regenerate it")
+		utils.RespondError(w, http.StatusGone, err, err.Error())
+		return
+	}
+
+	// cart orders are priced from the bill the user confirmed, never from the staff
+	bill, err := dbHelpers.GetOrderBill(orderID)
 	if err != nil {
 		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "something went wrong")
 		return
 	}
+	if bill.Mode == models.CartMode && !bill.ConfirmedAt.Valid {
+		err := fmt.Errorf("bill is not confirmed by the user yet")
+		utils.RespondError(w, http.StatusPreconditionFailed, err, err.Error())
+		return
+	}
+
+	pendingChanges, err := dbHelpers.GetPendingItemChangeCount(orderID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "something went wrong")
+		return
+	}
+	if pendingChanges > 0 {
+		err := fmt.Errorf("item changes are waiting for the user's approval")
+		utils.RespondError(w, http.StatusPreconditionFailed, err, err.Error())
+		return
+	}
+
+	proofMissing, err := dbHelpers.IsDeliveryProofMissing(orderID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "something went wrong")
+		return
+	}
+	if proofMissing {
+		err := fmt.Errorf("proof of delivery is required for this order, upload it first")
+		utils.RespondError(w, http.StatusPreconditionFailed, err, err.Error())
+		return
+	}
+
+	matched := utils.CompareOTP(verifyOrder.OTP, storedOTP.OTP)
+	attempt, err := dbHelpers.RecordOTPAttempt(orderID, staffID, matched)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err := fmt.Errorf("otp locked after too many wrong attempts, contact store manager")
+			utils.RespondError(w, http.StatusLocked, err, err.Error())
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "something went wrong")
+		return
+	}
+	if !matched {
+		if attempt.LockedAt.Valid {
+			go alertOTPLocked(orderID, attempt.Attempts)
+			err := fmt.Errorf("otp locked after too many wrong attempts, contact store manager")
+			utils.RespondError(w, http.StatusLocked, err, err.Error())
+			return
+		}
+		utils.RespondError(w, http.StatusUnauthorized, fmt.Errorf("invalid otp"),
+			fmt.Sprintf("invalid otp, %d attempts left", models.MaxOTPAttempts-attempt.Attempts))
+		return
+	}
+
 	status := models.Delivered
-	if verifyOrder.OTP == storedOTP {
-		err := dbHelpers.UpdateOrders(orderID, nil, &verifyOrder.Amount, &verifyOrder.UserRating, nil, &status, nil)
+	err = dbHelpers.UpdateOrders(orderID, nil, nil, &verifyOrder.UserRating, nil, &status, nil)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to update order")
+		return
+	}
+	err = dbHelpers.DeleteOTP(orderID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "Unable to delete otp")
+		return
+	}
+
+	go func() {
+		order, err := dbHelpers.GetOrderByID(orderID, staffID)
+		if err != nil {
+			logrus.Error(err)
+		}
+		userID := order.UserID
+		firebase.OrderStatusUpdateNotification(int64(userID), orderID, models.Delivered, "")
+	}()
+	go ReleaseOrderContacts(orderID)
+	go func() {
+		if _, err := GenerateInvoice(orderID); err != nil {
+			logrus.Errorf("PostVerifyOTP: failed to generate invoice for order %d error: %v", orderID, err)
+		}
+	}()
+
+	utils.RespondJSON(w,200,models.Response{
+		Success: true,
+	})
+}
+
+//alertOTPLocked lets the store manager of the order know its otp got locked
+func alertOTPLocked(orderID, attempts int) {
+	smID, err := dbHelpers.GetOrderStoreManager(orderID)
+	if err != nil {
+		logrus.Errorf("alertOTPLocked: failed to fetch store manager for order %d error: %v", orderID, err)
+		return
+	}
+	if smID.Valid {
+		firebase.OTPLockedNotification(smID.Int, orderID, attempts)
+	}
+}
+
+//SubmitOrderBill PUT /api/staff/order/bill/{id} records the items the user bought from the cart,
+//the server prices them and the user has to confirm the bill before the otp can be verified
+func SubmitOrderBill(w http.ResponseWriter, r *http.Request) {
+	staffID := middlewares.UserContext(r).ID
+	orderID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid order id")
+		return
+	}
+
+	reqBody := struct {
+		Items []models.ItemInfo `json:"items"`
+	}{}
+	if err := utils.ParseBody(r.Body, &reqBody); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "unable to parse req body")
+		return
+	}
+	if len(reqBody.Items) == 0 {
+		err := fmt.Errorf("items can't be empty")
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
+	// merge the same item scanned more than once
+	quantities := make(map[int]int)
+	items := make([]models.ItemInfo, 0, len(reqBody.Items))
+	for _, item := range reqBody.Items {
+		if item.Quantity <= 0 {
+			err := fmt.Errorf("invalid quantity for item %d", item.Id)
+			utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+			return
+		}
+		if _, ok := quantities[item.Id]; !ok {
+			items = append(items, models.ItemInfo{Id: item.Id})
+		}
+		quantities[item.Id] += item.Quantity
+	}
+	for i := range items {
+		items[i].Quantity = quantities[items[i].Id]
+	}
+
+	bill, err := dbHelpers.GetOrderBill(orderID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, http.StatusNotFound, err, "order not found")
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "something went wrong")
+		return
+	}
+	if !bill.StaffID.Valid || bill.StaffID.Int != staffID {
+		err := fmt.Errorf("order not found")
+		utils.RespondError(w, http.StatusNotFound, err, err.Error())
+		return
+	}
+	if bill.Mode != models.CartMode {
+		err := fmt.Errorf("bill can only be submitted for cart orders")
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
+	if bill.Status != models.Accepted && bill.Status != models.OutForDelivery {
+		err := fmt.Errorf("bill can't be submitted for %s order", bill.Status)
+		utils.RespondError(w, http.StatusConflict, err, err.Error())
+		return
+	}
+	if bill.ConfirmedAt.Valid {
+		err := fmt.Errorf("bill is already confirmed by the user")
+		utils.RespondError(w, http.StatusConflict, err, err.Error())
+		return
+	}
+
+	if err := dbHelpers.SubmitOrderBill(orderID, items); err != nil {
+		if errors.Is(err, dbHelpers.ErrItemNotFound) {
+			utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to submit bill")
+		return
+	}
+
+	bill, err = getOrderBillWithItems(orderID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch bill")
+		return
+	}
+	go firebase.OrderBillNotification(bill.UserID, orderID, "Review your bill",
+		fmt.Sprintf("Bill of %.2f for order %d is waiting for your confirmation", bill.Amount, orderID))
+	utils.RespondJSON(w, http.StatusOK, bill)
+}
+
+//getOrderBillWithItems returns the bill of the order along with its items
+func getOrderBillWithItems(orderID int) (models.OrderBill, error) {
+	bill, err := dbHelpers.GetOrderBill(orderID)
+	if err != nil {
+		return bill, err
+	}
+	bill.Items, err = dbHelpers.GetOrderItems(orderID)
+	return bill, err
+}
+
+//UploadDeliveryProof POST /api/staff/order/proof/{id}
+//multipart form with a photo and/or signature image along with lat, long and optional capturedAt(RFC3339)
+func UploadDeliveryProof(w http.ResponseWriter, r *http.Request) {
+	staffID := middlewares.UserContext(r).ID
+	orderID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid order id")
+		return
+	}
+
+	order, err := dbHelpers.GetOrderByID(orderID, staffID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, http.StatusNotFound, err, "order not found")
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "something went wrong")
+		return
+	}
+	if order.Status != models.OutForDelivery {
+		err := dbHelpers.ErrDeliveryProofClosed
+		utils.RespondError(w, http.StatusConflict, err, err.Error())
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 2*models.MaxProofImageSize+1<<20)
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "unable to parse multipart form")
+		return
+	}
+
+	proof := models.DeliveryProof{
+		OrderID:    orderID,
+		StaffID:    staffID,
+		CapturedAt: time.Now(),
+	}
+	lat, latErr := strconv.ParseFloat(r.FormValue("lat"), 64)
+	long, longErr := strconv.ParseFloat(r.FormValue("long"), 64)
+	if latErr != nil || longErr != nil || lat < -90 || lat > 90 || long < -180 || long > 180 {
+		err := fmt.Errorf("valid lat and long are required")
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
+	proof.Lat, proof.Long = null.Float64From(lat), null.Float64From(long)
+	if capturedAt := r.FormValue("capturedAt"); capturedAt != "" {
+		proof.CapturedAt, err = time.Parse(time.RFC3339, capturedAt)
+		if err != nil || proof.CapturedAt.After(time.Now().Add(5*time.Minute)) {
+			err := fmt.Errorf("invalid value for capturedAt")
+			utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+			return
+		}
+	}
+
+	// validate every image before uploading any of them
+	images := make(map[string][]byte)
+	fileNames := make(map[string]string)
+	for _, field := range []string{"photo", "signature"} {
+		file, handler, err := r.FormFile(field)
+		if err == http.ErrMissingFile {
+			continue
+		}
 		if err != nil {
-			utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to update order")
+			utils.RespondError(w, http.StatusBadRequest, err, "Failed in reading "+field+" file")
 			return
 		}
-		err = dbHelpers.DeleteOTP(orderID)
+		fileBytes, err := ioutil.ReadAll(file)
+		_ = file.Close()
 		if err != nil {
-			utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "Unable to delete otp")
+			utils.RespondError(w, http.StatusBadRequest, err, "Failed in reading "+field+" file")
+			return
+		}
+		if err := utils.ValidateImage(fileBytes, models.MaxProofImageSize); err != nil {
+			utils.RespondError(w, http.StatusBadRequest, err, field+": "+err.Error())
 			return
 		}
+		images[field], fileNames[field] = fileBytes, handler.Filename
+	}
+	if len(images) == 0 {
+		err := fmt.Errorf("photo or signature is required")
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
 
-		go func() {
-			order, err := dbHelpers.GetOrderByID(orderID, staffID)
-			if err != nil {
-				logrus.Error(err)
-			}
-			userID := order.UserID
-			firebase.OrderStatusUpdateNotification(int64(userID), orderID, models.Delivered, "")
-		}()
+	for field, fileBytes := range images {
+		uploadedFileName, err := firebase.UploadToFirebase(fileBytes, fileNames[field])
+		if err != nil {
+			utils.RespondError(w, http.StatusInternalServerError, err, "Failed in uploading to firebase")
+			return
+		}
+		imageID, err := dbHelpers.StoreImageInfo(models.BucketLink, uploadedFileName, string(models.ProofImage))
+		if err != nil {
+			utils.RespondError(w, http.StatusInternalServerError, err, "Failed in storing uploaded file info")
+			return
+		}
+		if field == "photo" {
+			proof.PhotoImageID = null.IntFrom(imageID)
+		} else {
+			proof.SignatureImageID = null.IntFrom(imageID)
+		}
+	}
 
-		utils.RespondJSON(w,200,models.Response{
-			Success: true,
-		})
+	if err := dbHelpers.UpsertDeliveryProof(proof); err != nil {
+		if err == dbHelpers.ErrDeliveryProofClosed {
+			utils.RespondError(w, http.StatusConflict, err, err.Error())
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to store proof of delivery")
 		return
-	} else {
-		utils.RespondError(w, http.StatusUnauthorized, fmt.Errorf("invalid otp"), "invalid  otp")
+	}
+	storedProof, err := dbHelpers.GetDeliveryProof(orderID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch proof of delivery")
 		return
 	}
-
+	utils.RespondJSON(w, http.StatusCreated, storedProof)
 }
 
 //GetOrderHistory returns order history of all delivered orders for given staff
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"database/sql"
+	"github.com/RemoteState/yourdaily-server/dbHelpers"
+	"github.com/RemoteState/yourdaily-server/middlewares"
+	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/RemoteState/yourdaily-server/utils"
+	"github.com/go-chi/chi"
+	"github.com/sirupsen/logrus"
+	"github.com/volatiletech/null"
+	"net/http"
+	"strconv"
+)
+
+//SetStockAlert PUT /api/user/item/{id}/alert?variantId= notifies the user once the out of stock item,
+//or its variant, is back in stock
+func SetStockAlert(w http.ResponseWriter, r *http.Request) {
+	userID := middlewares.UserContext(r).ID
+	itemID, variantID, err := stockAlertItem(r)
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid item or variant id")
+		return
+	}
+
+	if err := dbHelpers.SetStockAlert(userID, itemID, variantID); err != nil {
+		switch err {
+		case dbHelpers.ErrItemNotFound:
+			utils.RespondError(w, http.StatusNotFound, err, err.Error())
+		case dbHelpers.ErrItemInStock:
+			utils.RespondError(w, http.StatusConflict, err, "item is in stock, it can be ordered now")
+		default:
+			utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to set stock alert")
+		}
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, models.Response{Success: true})
+}
+
+//RemoveStockAlert DELETE /api/user/item/{id}/alert?variantId= stops waiting for the item, or its variant
+func RemoveStockAlert(w http.ResponseWriter, r *http.Request) {
+	userID := middlewares.UserContext(r).ID
+	itemID, variantID, err := stockAlertItem(r)
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid item or variant id")
+		return
+	}
+
+	if err := dbHelpers.RemoveStockAlert(userID, itemID, variantID); err != nil {
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, http.StatusNotFound, err, "stock alert not found")
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to remove stock alert")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, models.Response{Success: true})
+}
+
+//GetStockAlerts GET /api/user/alert lists the items the user waits for
+func GetStockAlerts(w http.ResponseWriter, r *http.Request) {
+	userID := middlewares.UserContext(r).ID
+	alerts, err := dbHelpers.GetStockAlerts(userID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch stock alerts")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, alerts)
+}
+
+//GetStockDemand GET /api/store-manager/item/demand?offset=&limit= lists the out of stock items users wait for,
+//the most awaited first, to restock them in that order
+func GetStockDemand(w http.ResponseWriter, r *http.Request) {
+	offset, limit, err := utils.GetOffsetLimit(r)
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid value for offset or limit")
+		return
+	}
+
+	demand, err := dbHelpers.GetStockDemand(offset, limit)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch stock demand")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, demand)
+}
+
+//stockAlertItem returns the item id of the path and the optional variantId of the query
+func stockAlertItem(r *http.Request) (int, null.Int, error) {
+	itemID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		return 0, null.Int{}, err
+	}
+	var variantID null.Int
+	if variant := r.URL.Query().Get("variantId"); variant != "" {
+		id, err := strconv.Atoi(variant)
+		if err != nil {
+			return 0, null.Int{}, err
+		}
+		variantID = null.IntFrom(id)
+	}
+	return itemID, variantID, nil
+}
+
+//notifyBackInStock sends the alerts of the items a change of the catalogue put back in stock
+func notifyBackInStock() {
+	if err := dbHelpers.SendBackInStockAlerts(); err != nil {
+		logrus.Errorf("notifyBackInStock: unable to send back in stock alerts error: %v", err)
+	}
+}
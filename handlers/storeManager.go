@@ -161,6 +161,18 @@ func DashBoardStats(w http.ResponseWriter, r *http.Request) {
 
 	})
 
+	egp.Go(func() error {
+		var err error
+		stats.OpenChatReports, err = dbHelpers.GetOpenChatReportCount(smID)
+		return err
+	})
+
+	egp.Go(func() error {
+		var err error
+		stats.LockedOTPOrders, err = dbHelpers.GetLockedOTPOrderCount(smID)
+		return err
+	})
+
 	err := egp.Wait()
 	if err != nil {
 		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "something went wrong")
@@ -354,6 +366,46 @@ func GetAllDisputedOrders(w http.ResponseWriter, r *http.Request) {
 	}
 	utils.RespondJSON(w, 200, disOrder)
 }
+//GetLockedOTPOrders returns the orders whose delivery otp got locked after too many wrong attempts
+func GetLockedOTPOrders(w http.ResponseWriter, r *http.Request) {
+	smID := middlewares.UserContext(r).ID
+	orders, err := dbHelpers.GetLockedOTPOrders(smID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), err.Error())
+		return
+	}
+	utils.RespondJSON(w, 200, orders)
+}
+
+//UnlockOTP unlocks the delivery otp of an order and issues a fresh one to the user
+func UnlockOTP(w http.ResponseWriter, r *http.Request) {
+	smID := middlewares.UserContext(r).ID
+	orderID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid order id")
+		return
+	}
+
+	if _, err := dbHelpers.UnlockOTP(orderID, smID); err != nil {
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, http.StatusNotFound, err, "no locked otp found for this order")
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to unlock otp")
+		return
+	}
+
+	go func() {
+		parties, err := dbHelpers.GetContactParties(orderID)
+		if err != nil {
+			logrus.Errorf("UnlockOTP: failed to fetch user of order %d error: %v", orderID, err)
+			return
+		}
+		firebase.OrderStatusUpdateNotification(int64(parties.UserID), orderID, models.OutForDelivery, "")
+	}()
+	utils.RespondJSON(w, http.StatusOK, models.Response{Success: true})
+}
+
 func GetAllDisputedOrderInfo(w http.ResponseWriter, r *http.Request) {
 	orderId, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
@@ -874,28 +926,18 @@ func DownloadOrderHistory(w http.ResponseWriter, r *http.Request) {
 }
 
 func GetItemsForStoreManager(w http.ResponseWriter, r *http.Request) {
-	items, err := dbHelpers.GetItems(true)
+	items, err := dbHelpers.GetItems(true, false)
 	if err != nil {
 		utils.RespondError(w, http.StatusInternalServerError, err, "Failed to get item entries")
 		return
 	}
-	for i := range items {
-		imagesInfo, err := dbHelpers.GetImageInfoByItemID(items[i].ID)
-		if err != nil {
-			utils.RespondError(w, http.StatusInternalServerError, err, "Failed in generating image link")
-			return
-		}
-
-		imageUrls := make([]string, 0)
-		for j := range imagesInfo {
-			url, err := firebase.GetURL(&imagesInfo[j])
-			if err != nil {
-				utils.RespondError(w, http.StatusInternalServerError, err, "Failed in getting image URL")
-				return
-			}
-			imageUrls = append(imageUrls, url)
-		}
-		items[i].ItemImageLinks = imageUrls
+	if err := setItemImageLinks(items); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, "Failed in getting image URL")
+		return
+	}
+	if err := setItemVariants(items); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, "Failed to get item variants")
+		return
 	}
 	utils.RespondJSON(w, http.StatusOK, items)
 }
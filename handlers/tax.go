@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/RemoteState/yourdaily-server/dbHelpers"
+	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/RemoteState/yourdaily-server/utils"
+	"github.com/go-chi/chi"
+	"github.com/volatiletech/null"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+//GetTaxSlabs returns all the tax slabs
+func GetTaxSlabs(w http.ResponseWriter, r *http.Request) {
+	slabs, err := dbHelpers.GetTaxSlabs()
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch tax slabs")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, slabs)
+}
+
+//CreateTaxSlab creates a tax slab which can then be assigned to categories and items
+func CreateTaxSlab(w http.ResponseWriter, r *http.Request) {
+	slab, err := parseTaxSlab(r)
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
+	slab.ID, err = dbHelpers.InsertTaxSlab(slab)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to create tax slab")
+		return
+	}
+	respondTaxSlab(w, http.StatusCreated, slab.ID)
+}
+
+//UpdateTaxSlab updates the rate of a tax slab for new orders
+func UpdateTaxSlab(w http.ResponseWriter, r *http.Request) {
+	slabID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid tax slab id")
+		return
+	}
+	slab, err := parseTaxSlab(r)
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
+	slab.ID = slabID
+	if err := dbHelpers.UpdateTaxSlab(slab); err != nil {
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, http.StatusNotFound, err, "tax slab not found")
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to update tax slab")
+		return
+	}
+	respondTaxSlab(w, http.StatusOK, slabID)
+}
+
+//ArchiveTaxSlab archives a tax slab, its categories and items become untaxed
+func ArchiveTaxSlab(w http.ResponseWriter, r *http.Request) {
+	slabID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid tax slab id")
+		return
+	}
+	if err := dbHelpers.ArchiveTaxSlab(slabID); err != nil {
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, http.StatusNotFound, err, "tax slab not found")
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to archive tax slab")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, models.Response{Success: true})
+}
+
+//SetCategoryTaxSlab PUT /category/tax/{id} assigns a tax slab to the category, {"taxSlabId": null} removes it
+func SetCategoryTaxSlab(w http.ResponseWriter, r *http.Request) {
+	setTaxSlab(w, r, dbHelpers.SetCategoryTaxSlab, "category")
+}
+
+//SetItemTaxSlab PUT /item/tax/{id} overrides the tax slab of the item's category, {"taxSlabId": null} removes the override
+func SetItemTaxSlab(w http.ResponseWriter, r *http.Request) {
+	setTaxSlab(w, r, dbHelpers.SetItemTaxSlab, "item")
+}
+
+func setTaxSlab(w http.ResponseWriter, r *http.Request, set func(id int, slabID null.Int) error, target string) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "invalid "+target+" id")
+		return
+	}
+	reqBody := struct {
+		TaxSlabID null.Int `json:"taxSlabId"`
+	}{}
+	if err := utils.ParseBody(r.Body, &reqBody); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err, err.Error(), "unable to parse req body")
+		return
+	}
+	if reqBody.TaxSlabID.Valid {
+		if _, err := dbHelpers.GetTaxSlabByID(reqBody.TaxSlabID.Int); err != nil {
+			if err == sql.ErrNoRows {
+				utils.RespondError(w, http.StatusBadRequest, err, "tax slab not found")
+				return
+			}
+			utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch tax slab")
+			return
+		}
+	}
+	if err := set(id, reqBody.TaxSlabID); err != nil {
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, http.StatusNotFound, err, target+" not found")
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to update tax slab of "+target)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, models.Response{Success: true})
+}
+
+func parseTaxSlab(r *http.Request) (models.TaxSlab, error) {
+	slab := models.TaxSlab{}
+	if err := utils.ParseBody(r.Body, &slab); err != nil {
+		return slab, err
+	}
+	slab.Name = strings.TrimSpace(slab.Name)
+	if slab.Name == "" {
+		return slab, fmt.Errorf("name can't be empty")
+	}
+	if slab.Rate < 0 || slab.Rate > 100 {
+		return slab, fmt.Errorf("rate must be between 0 and 100")
+	}
+	return slab, nil
+}
+
+func respondTaxSlab(w http.ResponseWriter, status, slabID int) {
+	slab, err := dbHelpers.GetTaxSlabByID(slabID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err, err.Error(), "unable to fetch tax slab")
+		return
+	}
+	utils.RespondJSON(w, status, slab)
+}
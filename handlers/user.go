@@ -228,17 +228,18 @@ func GetStaffByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := dbHelpers.GetUserById(staffID)
+	// the phone is not shared, the user reaches the staff through the order contact
+	staff, err := dbHelpers.GetStaffProfile(staffID)
 	if err != nil {
 		utils.RespondError(w, http.StatusInternalServerError, err, "Failed to get user details")
 		return
 	}
-	user.Rating, err = dbHelpers.GetStaffRating(staffID)
+	staff.Rating, err = dbHelpers.GetStaffRating(staffID)
 	if err != nil {
 		utils.RespondError(w, http.StatusInternalServerError, err, "Failed to get user rating")
 		return
 	}
-	utils.RespondJSON(w, 200, user)
+	utils.RespondJSON(w, 200, staff)
 }
 
 func GetActiveDiscount(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,2 @@
+// Package invoice builds the tax invoice of a delivered order and renders it as PDF
+package invoice
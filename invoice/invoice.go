@@ -0,0 +1,72 @@
+package invoice
+
+import (
+	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/RemoteState/yourdaily-server/pricing"
+)
+
+//New builds the invoice of the order from its items, the number and issue time are assigned when it is stored
+func New(order models.InvoiceOrder, items []models.ItemInfo, store models.StoreDetails) models.Invoice {
+	inv := models.Invoice{
+		OrderID: order.OrderID,
+		Mode:    order.Mode,
+		Store:   store,
+		Customer: models.InvoiceParty{
+			Name:    order.UserName.String,
+			Address: order.AddressData,
+		},
+		Lines: make([]models.InvoiceLine, 0, len(items)),
+		Taxes: make([]models.InvoiceTax, 0),
+	}
+	for _, item := range items {
+		// removed and substituted lines are not billed
+		if item.Quantity == 0 {
+			continue
+		}
+		line := models.InvoiceLine{
+			Name:               item.Name,
+			BaseQuantity:       item.BaseQuantity,
+			Quantity:           item.Quantity,
+			StrikeThroughPrice: item.StrikeThroughPrice,
+			Price:              item.Price,
+			DiscountPercent:    item.Discount.Int,
+		}
+		line.Discount = pricing.Discount(item)
+		// the tax breakup is the one calculated on the order, so the invoice always matches the amount paid
+		line.TaxName, line.TaxRate, line.TaxInclusive = item.TaxName.String, item.TaxRate, item.TaxInclusive
+		line.TaxableAmount, line.TaxAmount = item.TaxableAmount, item.TaxAmount
+		line.Amount = pricing.Total(item)
+
+		inv.SubTotal += pricing.Gross(item)
+		inv.Discount += line.Discount
+		inv.TaxTotal += line.TaxAmount
+		inv.Total += line.Amount
+		inv.Lines = append(inv.Lines, line)
+		if line.TaxName != "" {
+			addTax(&inv, line)
+		}
+	}
+	inv.CouponDiscount, inv.DeliveryFee = order.CouponDiscount, order.DeliveryFee
+	inv.Total = pricing.AmountDue(inv.Total, inv.CouponDiscount, inv.DeliveryFee)
+	inv.SubTotal, inv.Discount = pricing.Round(inv.SubTotal), pricing.Round(inv.Discount)
+	inv.TaxTotal, inv.Total = pricing.Round(inv.TaxTotal), pricing.Round(inv.Total)
+	return inv
+}
+
+//addTax adds the tax of the line to the breakup of its slab
+func addTax(inv *models.Invoice, line models.InvoiceLine) {
+	for i, tax := range inv.Taxes {
+		if tax.Name == line.TaxName && tax.Rate == line.TaxRate && tax.Inclusive == line.TaxInclusive {
+			inv.Taxes[i].Taxable = pricing.Round(tax.Taxable + line.TaxableAmount)
+			inv.Taxes[i].Amount = pricing.Round(tax.Amount + line.TaxAmount)
+			return
+		}
+	}
+	inv.Taxes = append(inv.Taxes, models.InvoiceTax{
+		Name:      line.TaxName,
+		Rate:      line.TaxRate,
+		Inclusive: line.TaxInclusive,
+		Taxable:   line.TaxableAmount,
+		Amount:    line.TaxAmount,
+	})
+}
@@ -0,0 +1,150 @@
+package invoice
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/jung-kurt/gofpdf"
+)
+
+const currency = "Rs."
+
+var (
+	lineHeaders = []string{"#", "Item", "Qty", "MRP", "Price", "Disc %", "Amount"}
+	lineWidths  = []float64{10, 70, 15, 22, 22, 16, 25}
+)
+
+//PDF renders the invoice as an A4 pdf
+func PDF(inv models.Invoice) ([]byte, error) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(15, 15, 15)
+	pdf.AddPage()
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	// store
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(0, 8, tr(inv.Store.Name), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	if inv.Store.Address != "" {
+		pdf.MultiCell(0, 4.5, tr(inv.Store.Address), "", "L", false)
+	}
+	if inv.Store.GSTIN != "" {
+		pdf.CellFormat(0, 4.5, "GSTIN: "+inv.Store.GSTIN, "", 1, "L", false, 0, "")
+	}
+	if contact := joinNonEmpty(inv.Store.Phone, inv.Store.Email); contact != "" {
+		pdf.CellFormat(0, 4.5, tr(contact), "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(4)
+
+	pdf.SetFont("Helvetica", "B", 13)
+	pdf.CellFormat(0, 8, "TAX INVOICE", "TB", 1, "C", false, 0, "")
+	pdf.Ln(2)
+
+	// invoice and customer
+	pdf.SetFont("Helvetica", "", 9)
+	pdf.CellFormat(90, 5, "Invoice No: "+inv.Number, "", 0, "L", false, 0, "")
+	pdf.CellFormat(0, 5, "Bill To: "+tr(inv.Customer.Name), "", 1, "L", false, 0, "")
+	pdf.CellFormat(90, 5, "Date: "+inv.IssuedAt.Format("02 Jan 2006 15:04"), "", 0, "L", false, 0, "")
+	y := pdf.GetY()
+	pdf.MultiCell(0, 5, tr(inv.Customer.Address), "", "L", false)
+	addressEnd := pdf.GetY()
+	pdf.SetXY(15, y+5)
+	pdf.CellFormat(90, 5, fmt.Sprintf("Order: #%d (%s)", inv.OrderID, inv.Mode), "", 1, "L", false, 0, "")
+	if addressEnd > pdf.GetY() {
+		pdf.SetY(addressEnd)
+	}
+	pdf.Ln(4)
+
+	// lines
+	pdf.SetFont("Helvetica", "B", 9)
+	pdf.SetFillColor(235, 235, 235)
+	for i, header := range lineHeaders {
+		align := "R"
+		if i == 1 {
+			align = "L"
+		}
+		pdf.CellFormat(lineWidths[i], 7, header, "1", 0, align, true, 0, "")
+	}
+	pdf.Ln(-1)
+	pdf.SetFont("Helvetica", "", 9)
+	for i, line := range inv.Lines {
+		name := line.Name
+		if line.BaseQuantity != "" {
+			name = fmt.Sprintf("%s (%s)", line.Name, line.BaseQuantity)
+		}
+		mrp := ""
+		if line.StrikeThroughPrice.Valid {
+			mrp = money(line.StrikeThroughPrice.Float32)
+		}
+		cells := []string{fmt.Sprintf("%d", i+1), tr(name), fmt.Sprintf("%d", line.Quantity), mrp,
+			money(line.Price), fmt.Sprintf("%d", line.DiscountPercent), money(line.Amount)}
+		for j, cell := range cells {
+			align := "R"
+			if j == 1 {
+				align = "L"
+			}
+			pdf.CellFormat(lineWidths[j], 6, cell, "1", 0, align, false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+	pdf.Ln(3)
+
+	// totals
+	total := func(label, value string, bold bool) {
+		style := ""
+		if bold {
+			style = "B"
+		}
+		pdf.SetFont("Helvetica", style, 9)
+		pdf.CellFormat(105, 6, "", "", 0, "L", false, 0, "")
+		pdf.CellFormat(50, 6, label, "", 0, "L", false, 0, "")
+		pdf.CellFormat(25, 6, value, "", 1, "R", false, 0, "")
+	}
+	total("Sub Total", money(inv.SubTotal), false)
+	total("Discount", "-"+money(inv.Discount), false)
+	for _, tax := range inv.Taxes {
+		label := fmt.Sprintf("%s %.2f%%", tax.Name, tax.Rate)
+		if tax.Inclusive {
+			label += " (incl.)"
+		}
+		total(tr(label), money(tax.Amount), false)
+	}
+	if inv.CouponDiscount > 0 {
+		total("Coupon", "-"+money(inv.CouponDiscount), false)
+	}
+	if inv.DeliveryFee > 0 {
+		total("Delivery Fee", money(inv.DeliveryFee), false)
+	}
+	total("Total", fmt.Sprintf("%s %s", currency, money(inv.Total)), true)
+	pdf.Ln(6)
+
+	pdf.SetFont("Helvetica", "I", 8)
+	if len(inv.Taxes) == 0 {
+		pdf.CellFormat(0, 4, "Prices are inclusive of all taxes.", "", 1, "L", false, 0, "")
+	}
+	pdf.CellFormat(0, 4, "This is a computer generated invoice and does not need a signature.", "", 1, "L", false, 0, "")
+
+	buf := bytes.Buffer{}
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func money(amount float32) string {
+	return fmt.Sprintf("%.2f", amount)
+}
+
+func joinNonEmpty(values ...string) string {
+	joined := ""
+	for _, value := range values {
+		if value == "" {
+			continue
+		}
+		if joined != "" {
+			joined += " | "
+		}
+		joined += value
+	}
+	return joined
+}
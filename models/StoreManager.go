@@ -20,6 +20,8 @@ type DashBoardStats struct {
 	ActiveUsers        int `json:"activeUsers"`
 	UnapprovedStaff    int `json:"unapprovedStaff"`
 	BookingForLastWeek int `json:"bookingForLastWeek"`
+	OpenChatReports    int `json:"openChatReports"`
+	LockedOTPOrders    int `json:"lockedOtpOrders"`
 }
 
 type OrderNSStats struct {
@@ -112,5 +114,6 @@ type ScheduledOrderCsv struct {
 	CreatedAt    time.Time   `json:"createdAt" db:"created_at"`
 	StartDate    time.Time   `json:"startDate" db:"start_date"`
 	EndDate      time.Time   `json:"endDate" db:"end_date"`
+	Amount       float32     `json:"amount" db:"amount"`
 	Items        []ItemInfo  `json:"items" db:"items"`
 }
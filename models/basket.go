@@ -0,0 +1,43 @@
+package models
+
+import (
+	"github.com/volatiletech/null"
+	"time"
+)
+
+const (
+	ArchivedItemReason       = "archived"
+	OutOfStockItemReason     = "outOfStock"
+	NotInCatalogueItemReason = "notInCatalogue"
+)
+
+//Basket is a named list of items the user orders again and again
+type Basket struct {
+	ID        int          `json:"id" db:"id"`
+	Name      string       `json:"name" db:"name"`
+	Items     []BasketItem `json:"items" db:"-"`
+	CreatedAt time.Time    `json:"createdAt" db:"created_at"`
+	UpdatedAt time.Time    `json:"updatedAt" db:"updated_at"`
+}
+
+//BasketItem is an item saved in a basket or ordered before, with its current catalogue details
+type BasketItem struct {
+	BasketID  int     `json:"-" db:"basket_id"`
+	ItemID       int         `json:"itemId" db:"item_id"`
+	VariantID    null.Int    `json:"variantId" db:"variant_id"`
+	Quantity     int         `json:"quantity" db:"quantity"`
+	Name         string      `json:"name" db:"name"`
+	BaseQuantity null.String `json:"baseQuantity" db:"base_quantity"`
+	Price        float32     `json:"price" db:"price"`
+	Available    bool        `json:"available" db:"available"`
+	Archived     bool        `json:"-" db:"archived"`
+}
+
+//DroppedItem is an item left out of a reorder because it can't be ordered anymore
+type DroppedItem struct {
+	ItemID    int      `json:"itemId"`
+	VariantID null.Int `json:"variantId"`
+	Name      string   `json:"name"`
+	Quantity  int      `json:"quantity"`
+	Reason    string   `json:"reason"`
+}
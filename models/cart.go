@@ -0,0 +1,23 @@
+package models
+
+import "github.com/volatiletech/null"
+
+//CartLine is an item in the cart with its current catalogue details
+type CartLine struct {
+	ItemInfo
+	Available bool `json:"available" db:"available"`
+}
+
+//Cart is the cart of a user, it is kept on the server so it is the same on all the user's devices
+type Cart struct {
+	AddressID  null.Int    `json:"addressId" db:"address_id"`
+	CouponCode null.String `json:"couponCode" db:"coupon_code"`
+	UpdatedAt  null.Time   `json:"updatedAt" db:"updated_at"`
+	Items      []CartLine  `json:"items" db:"-"`
+}
+
+//CartDetails are the address and coupon attached to the cart
+type CartDetails struct {
+	AddressID  null.Int    `json:"addressId"`
+	CouponCode null.String `json:"couponCode"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import "github.com/volatiletech/null"
+
+const (
+	//MaxCatalogueImportSize is the largest catalogue file accepted for an import
+	MaxCatalogueImportSize = 10 << 20
+	//MaxCatalogueImportRows is the most items an import can create or update
+	MaxCatalogueImportRows = 5000
+	//CategoryPathSeparator separates the levels of the category path of a catalogue row
+	CategoryPathSeparator = " > "
+)
+
+//CatalogueRow is an item of a catalogue import or export, an import creates the rows without ID and updates the others,
+//ImageURL is downloaded and linked as the image of the item unless it is an image of the catalogue already,
+//Category is the path of the category from the top of the tree, like Fruits > Apples
+type CatalogueRow struct {
+	Row                int          `json:"-" db:"-"`
+	ID                 null.Int     `json:"id" db:"id"`
+	Name               string       `json:"name" db:"name"`
+	Category           string       `json:"category" db:"category"`
+	Price              float32      `json:"price" db:"price"`
+	StrikeThroughPrice null.Float32 `json:"strikeThroughPrice" db:"strikethrough_price"`
+	InStock            bool         `json:"inStock" db:"in_stock"`
+	BaseQuantity       string       `json:"baseQuantity" db:"base_quantity"`
+	ImageURL           string       `json:"imageUrl" db:"-"`
+	ImageID            null.Int     `json:"-" db:"-"`
+	Bucket             null.String  `json:"-" db:"bucket"`
+	Path               null.String  `json:"-" db:"path"`
+}
+
+//CatalogueRowError is why a row of a catalogue import is rejected, Row counts the records of the file from the header as 1
+type CatalogueRowError struct {
+	Row     int    `json:"row"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+//CatalogueImport is the outcome of a catalogue import, nothing is applied when there are errors or on a dry run
+type CatalogueImport struct {
+	DryRun            bool                `json:"dryRun"`
+	Created           int                 `json:"created"`
+	Updated           int                 `json:"updated"`
+	CreatedCategories []string            `json:"createdCategories"`
+	Errors            []CatalogueRowError `json:"errors"`
+}
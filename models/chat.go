@@ -1,8 +1,112 @@
 package models
 
+import (
+	"github.com/lib/pq"
+	"github.com/volatiletech/null"
+)
+
+type ChatEventType string
+
+const (
+	ChatEventMessage   ChatEventType = "message"
+	ChatEventTyping    ChatEventType = "typing"
+	ChatEventDelivered ChatEventType = "delivered"
+	ChatEventRead      ChatEventType = "read"
+	ChatEventError     ChatEventType = "error"
+	ChatEventMuted     ChatEventType = "muted"
+)
+
+type ChatAttachmentType string
+
+const (
+	ChatImageAttachment    ChatAttachmentType = "image"
+	ChatLocationAttachment ChatAttachmentType = "location"
+	ChatItemAttachment     ChatAttachmentType = "item"
+)
+
+const DefaultChatPageSize = 30
+
 type Chat struct {
-	OrderID   int    `json:"orderID" db:"order_id"`
-	Sender    int    `json:"sender" db:"sender"`
-	Message   string `json:"message" db:"message"`
-	CreatedAt string `json:"createdAt" db:"created_at"`
+	ID             int          `json:"id" db:"id"`
+	OrderID        int          `json:"orderID" db:"order_id"`
+	Sender         int          `json:"sender" db:"sender"`
+	Message        string       `json:"message" db:"message"`
+	AttachmentType null.String  `json:"attachmentType" db:"attachment_type"`
+	ImageID        null.Int     `json:"imageId" db:"image_id"`
+	ImageURL       string       `json:"imageUrl,omitempty" db:"-"`
+	Lat            null.Float64 `json:"lat" db:"lat"`
+	Long           null.Float64 `json:"long" db:"long"`
+	ItemID         null.Int     `json:"itemId" db:"item_id"`
+	ItemName       null.String  `json:"itemName" db:"item_name"`
+	Bucket         null.String  `json:"-" db:"bucket"`
+	Path           null.String  `json:"-" db:"path"`
+	CreatedAt      string       `json:"createdAt" db:"created_at"`
+	DeliveredAt    null.String  `json:"deliveredAt" db:"delivered_at"`
+	ReadAt         null.String  `json:"readAt" db:"read_at"`
+}
+
+// Preview returns the text shown in the push notification of the message
+func (c Chat) Preview() string {
+	if c.Message != "" {
+		return c.Message
+	}
+	switch ChatAttachmentType(c.AttachmentType.String) {
+	case ChatImageAttachment:
+		return "sent a photo"
+	case ChatLocationAttachment:
+		return "shared a location"
+	case ChatItemAttachment:
+		return "shared an item"
+	}
+	return ""
+}
+
+// ChatEvent is a single frame sent or received over the order chat websocket
+type ChatEvent struct {
+	Type      ChatEventType `json:"type"`
+	OrderID   int           `json:"orderID"`
+	Sender    int           `json:"sender,omitempty"`
+	MessageID int           `json:"messageID,omitempty"`
+	Message   string        `json:"message,omitempty"`
+	Typing    bool          `json:"typing,omitempty"`
+	Muted     bool          `json:"muted,omitempty"`
+	Chat      *Chat         `json:"chat,omitempty"`
+}
+
+// ChatOrder holds the order details needed to decide who can read or post in its chat
+type ChatOrder struct {
+	OrderID  int         `db:"id"`
+	UserID   int         `db:"user_id"`
+	StaffID  null.Int    `db:"staff_id"`
+	SmID     null.Int    `db:"sm_id"`
+	Status   OrderStatus `db:"status"`
+	Disputed bool        `db:"disputed"`
+}
+
+// IsClosed tells if the order has reached a final status, after which its chat is read-only
+func (o ChatOrder) IsClosed() bool {
+	return o.Status == Delivered || o.Status == Cancelled || o.Status == Declined
+}
+
+// ChatSettings is the store manager's moderation config applied to the messages of their orders
+type ChatSettings struct {
+	MaskPhone    bool           `json:"maskPhone" db:"mask_phone"`
+	MaskEmail    bool           `json:"maskEmail" db:"mask_email"`
+	BlockedWords pq.StringArray `json:"blockedWords" db:"blocked_words"`
+}
+
+type ChatReport struct {
+	ID             int         `json:"id" db:"id"`
+	ChatID         int         `json:"chatId" db:"chat_id"`
+	OrderID        int         `json:"orderId" db:"order_id"`
+	ReportedBy     int         `json:"reportedBy" db:"reported_by"`
+	ReporterName   string      `json:"reporterName" db:"reporter_name"`
+	Sender         int         `json:"sender" db:"sender"`
+	SenderName     string      `json:"senderName" db:"sender_name"`
+	Message        string      `json:"message" db:"message"`
+	Reason         string      `json:"reason" db:"reason"`
+	SenderMuted    bool        `json:"senderMuted" db:"sender_muted"`
+	CreatedAt      string      `json:"createdAt" db:"created_at"`
+	ResolvedAt     null.String `json:"resolvedAt" db:"resolved_at"`
+	ResolutionNote null.String `json:"resolutionNote" db:"resolution_note"`
 }
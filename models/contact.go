@@ -0,0 +1,40 @@
+package models
+
+import (
+	"github.com/volatiletech/null"
+	"time"
+)
+
+//ContactProxyTTL is how long an issued proxy contact stays valid if the order doesn't complete before
+const ContactProxyTTL = 6 * time.Hour
+
+//ContactProxy is the masked contact a participant of an order uses to reach the other one
+type ContactProxy struct {
+	ID          int       `json:"-" db:"id"`
+	OrderID     int       `json:"orderId" db:"order_id"`
+	CallerID    int       `json:"-" db:"caller_id"`
+	CalleeID    int       `json:"calleeId" db:"callee_id"`
+	Provider    string    `json:"-" db:"provider"`
+	Kind        string    `json:"kind" db:"kind"`
+	Value       string    `json:"value" db:"value"`
+	ProviderRef string    `json:"-" db:"provider_ref"`
+	ExpiresAt   time.Time `json:"expiresAt" db:"expires_at"`
+}
+
+//ContactParties are the user and the assigned staff of an order with their real phones, never sent to clients
+type ContactParties struct {
+	OrderID    int         `db:"id"`
+	Status     OrderStatus `db:"status"`
+	UserID     int         `db:"user_id"`
+	UserPhone  string      `db:"user_phone"`
+	StaffID    null.Int    `db:"staff_id"`
+	StaffPhone null.String `db:"staff_phone"`
+}
+
+//StaffProfile is the staff info shown to the user of an order
+type StaffProfile struct {
+	ID               int         `json:"id" db:"id"`
+	Name             null.String `json:"name" db:"name"`
+	Rating           float32     `json:"rating" db:"rating"`
+	ProfileImageLink string      `json:"profileImageLink" db:"-"`
+}
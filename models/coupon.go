@@ -0,0 +1,57 @@
+package models
+
+import (
+	"github.com/lib/pq"
+	"github.com/volatiletech/null"
+	"time"
+)
+
+type CouponDiscountType string
+
+const (
+	FlatCoupon       CouponDiscountType = "flat"
+	PercentageCoupon CouponDiscountType = "percentage"
+)
+
+type CouponScope string
+
+const (
+	OrderCouponScope    CouponScope = "order"
+	CategoryCouponScope CouponScope = "category"
+	ItemCouponScope     CouponScope = "item"
+)
+
+//Coupon is a promotion code applied on top of the order, a coupon that is not stackable replaces the active offer
+type Coupon struct {
+	ID            int                `json:"id" db:"id"`
+	Code          string             `json:"code" db:"code"`
+	Description   string             `json:"description" db:"description"`
+	DiscountType  CouponDiscountType `json:"discountType" db:"discount_type"`
+	Value         float32            `json:"value" db:"value"`
+	MaxDiscount   null.Float32       `json:"maxDiscount" db:"max_discount"`
+	Scope         CouponScope        `json:"scope" db:"scope"`
+	CategoryIDs   pq.Int64Array      `json:"categoryIds" db:"category_ids"`
+	ItemIDs       pq.Int64Array      `json:"itemIds" db:"item_ids"`
+	MinOrderValue float32            `json:"minOrderValue" db:"min_order_value"`
+	UsageLimit    null.Int           `json:"usageLimit" db:"usage_limit"`
+	PerUserLimit  null.Int           `json:"perUserLimit" db:"per_user_limit"`
+	Stackable     bool               `json:"stackable" db:"stackable"`
+	StartsAt      time.Time          `json:"startsAt" db:"starts_at"`
+	EndsAt        null.Time          `json:"endsAt" db:"ends_at"`
+	CreatedAt     time.Time          `json:"createdAt" db:"created_at"`
+	UsedCount     int                `json:"usedCount" db:"used_count"`
+}
+
+//CouponUsage is how many live orders used the coupon, cancelled and declined orders give the usage back
+type CouponUsage struct {
+	Total  int `db:"total"`
+	ByUser int `db:"by_user"`
+}
+
+//CouponLine is an order line the coupon is evaluated against, Amount is what the line costs after the offer and taxes
+type CouponLine struct {
+	ItemID     int     `db:"item_id"`
+	CategoryID int     `db:"category_id"`
+	Quantity   int     `db:"quantity"`
+	Amount     float32 `db:"amount"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "github.com/volatiletech/null"
+
+//DeliveryFeeRule charges the fee on orders of the mode delivered between min and max distance (km) from the store,
+//orders worth free above or more are delivered free
+type DeliveryFeeRule struct {
+	ID          int          `json:"id" db:"id"`
+	Mode        OrderMode    `json:"mode" db:"mode"`
+	MinDistance float64      `json:"minDistance" db:"min_distance"`
+	MaxDistance null.Float64 `json:"maxDistance" db:"max_distance"`
+	Fee         float32      `json:"fee" db:"fee"`
+	FreeAbove   null.Float32 `json:"freeAbove" db:"free_above"`
+}
+
+//DeliverySettings are the delivery fee rules and the minimum order value of a store
+type DeliverySettings struct {
+	MinOrderValue float32           `json:"minOrderValue" db:"min_order_value"`
+	Rules         []DeliveryFeeRule `json:"rules" db:"-"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"github.com/volatiletech/null"
+	"time"
+)
+
+//ProofRequiredAmount is the order amount from which staff are asked to capture a proof of delivery
+const ProofRequiredAmount float32 = 1000
+
+//DeliveryProof is the photo and/or signature captured by the staff when handing over an order
+type DeliveryProof struct {
+	OrderID          int          `json:"orderId" db:"order_id"`
+	StaffID          int          `json:"staffId" db:"staff_id"`
+	PhotoImageID     null.Int     `json:"-" db:"photo_image_id"`
+	PhotoURL         string       `json:"photoUrl,omitempty" db:"-"`
+	SignatureImageID null.Int     `json:"-" db:"signature_image_id"`
+	SignatureURL     string       `json:"signatureUrl,omitempty" db:"-"`
+	Lat              null.Float64 `json:"lat" db:"lat"`
+	Long             null.Float64 `json:"long" db:"long"`
+	CapturedAt       time.Time    `json:"capturedAt" db:"captured_at"`
+}
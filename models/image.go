@@ -3,11 +3,22 @@ package models
 type ImageType string
 
 const (
-	ProfileImage ImageType = "profile"
-	ItemImage    ImageType = "item"
-	OfferImage ImageType = "offer"
+	ProfileImage  ImageType = "profile"
+	ItemImage     ImageType = "item"
+	OfferImage    ImageType = "offer"
+	ChatImage     ImageType = "chat"
+	ProofImage    ImageType = "delivery-proof"
+	CategoryImage ImageType = "category"
 )
 
+const (
+	MaxChatImageSize  = 5 << 20
+	MaxProofImageSize = 5 << 20
+	MaxItemImageSize  = 5 << 20
+)
+
+var AllowedImageContentTypes = []string{"image/jpeg", "image/png", "image/webp"}
+
 var BucketLink = "yoursdaily-3e32c.appspot.com"
 
 type Image struct {
@@ -16,5 +27,5 @@ type Image struct {
 }
 
 func IsValidImageType(imageType string) bool {
-	return imageType == string(OfferImage) || imageType == string(ItemImage)
-}
\ No newline at end of file
+	return imageType == string(OfferImage) || imageType == string(ItemImage) || imageType == string(CategoryImage)
+}
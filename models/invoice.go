@@ -0,0 +1,84 @@
+package models
+
+import (
+	"github.com/volatiletech/null"
+	"time"
+)
+
+//InvoiceNumberFormat is prefix/store manager id/per store sequence
+const InvoiceNumberFormat = "%s/%d/%06d"
+
+//StoreDetails are printed on the invoices of the store manager's orders
+type StoreDetails struct {
+	Name          string `json:"name" db:"name"`
+	Address       string `json:"address" db:"address"`
+	GSTIN         string `json:"gstin" db:"gstin"`
+	Phone         string `json:"phone" db:"phone"`
+	Email         string `json:"email" db:"email"`
+	InvoicePrefix string `json:"invoicePrefix" db:"invoice_prefix"`
+}
+
+//InvoiceOrder is the order being invoiced
+type InvoiceOrder struct {
+	OrderID        int         `db:"id"`
+	Mode           OrderMode   `db:"mode"`
+	Status         OrderStatus `db:"status"`
+	UserID         int         `db:"user_id"`
+	SmID           null.Int    `db:"sm_id"`
+	UserName       null.String `db:"user_name"`
+	AddressData    string      `db:"address_data"`
+	CouponDiscount float32     `db:"coupon_discount"`
+	DeliveryFee    float32     `db:"delivery_fee"`
+	Amount         float32     `db:"amount"`
+}
+
+type InvoiceParty struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+}
+
+type InvoiceLine struct {
+	Name               string       `json:"name"`
+	BaseQuantity       string       `json:"baseQuantity"`
+	Quantity           int          `json:"quantity"`
+	StrikeThroughPrice null.Float32 `json:"strikeThroughPrice"`
+	Price              float32      `json:"price"`
+	DiscountPercent    int          `json:"discountPercent"`
+	Discount           float32      `json:"discount"`
+	TaxName            string       `json:"taxName"`
+	TaxRate            float32      `json:"taxRate"`
+	TaxInclusive       bool         `json:"taxInclusive"`
+	TaxableAmount      float32      `json:"taxableAmount"`
+	TaxAmount          float32      `json:"taxAmount"`
+	Amount             float32      `json:"amount"`
+}
+
+type InvoiceTax struct {
+	Name      string  `json:"name"`
+	Rate      float32 `json:"rate"`
+	Inclusive bool    `json:"inclusive"`
+	Taxable   float32 `json:"taxable"`
+	Amount    float32 `json:"amount"`
+}
+
+//Invoice is the tax invoice of a delivered order, it is stored as is so a reprint always matches the original
+type Invoice struct {
+	ID             int           `json:"-"`
+	Number         string        `json:"number"`
+	OrderID        int           `json:"orderId"`
+	Mode           OrderMode     `json:"mode"`
+	IssuedAt       time.Time     `json:"issuedAt"`
+	Store          StoreDetails  `json:"store"`
+	Customer       InvoiceParty  `json:"customer"`
+	Lines          []InvoiceLine `json:"lines"`
+	SubTotal       float32       `json:"subTotal"`
+	Discount       float32       `json:"discount"`
+	Taxes          []InvoiceTax  `json:"taxes"`
+	TaxTotal       float32       `json:"taxTotal"`
+	CouponDiscount float32       `json:"couponDiscount"`
+	DeliveryFee    float32       `json:"deliveryFee"`
+	Total          float32       `json:"total"`
+	PDFURL         string        `json:"pdfUrl,omitempty"`
+	Bucket         null.String   `json:"-"`
+	Path           null.String   `json:"-"`
+}
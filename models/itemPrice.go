@@ -0,0 +1,29 @@
+package models
+
+import (
+	"github.com/volatiletech/null"
+	"time"
+)
+
+type ItemPriceStatus string
+
+const (
+	AppliedItemPrice   ItemPriceStatus = "applied"
+	ScheduledItemPrice ItemPriceStatus = "scheduled"
+	CancelledItemPrice ItemPriceStatus = "cancelled"
+)
+
+//ItemPrice is a price an item, or one of its variants, had or will have from EffectiveFrom, order items
+//reference the one they were priced with
+type ItemPrice struct {
+	ID                 int             `json:"id" db:"id"`
+	ItemID             int             `json:"itemId" db:"item_id"`
+	VariantID          null.Int        `json:"variantId" db:"variant_id"`
+	Price              float32         `json:"price" db:"price"`
+	StrikeThroughPrice null.Float32    `json:"strikeThroughPrice" db:"strikethrough_price"`
+	EffectiveFrom      time.Time       `json:"effectiveFrom" db:"effective_from"`
+	AppliedAt          null.Time       `json:"appliedAt" db:"applied_at"`
+	CancelledAt        null.Time       `json:"cancelledAt" db:"cancelled_at"`
+	CreatedAt          time.Time       `json:"createdAt" db:"created_at"`
+	Status             ItemPriceStatus `json:"status" db:"status"`
+}
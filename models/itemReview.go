@@ -0,0 +1,44 @@
+package models
+
+import (
+	"github.com/volatiletech/null"
+	"time"
+)
+
+type ItemReviewStatus string
+
+const (
+	PendingItemReview  ItemReviewStatus = "pending"
+	ApprovedItemReview ItemReviewStatus = "approved"
+	RejectedItemReview ItemReviewStatus = "rejected"
+)
+
+//MaxItemReviewLength is the longest review text a user can write
+const MaxItemReviewLength = 2000
+
+//IsValidItemReviewStatus reports whether status is a status of a review
+func IsValidItemReviewStatus(status ItemReviewStatus) bool {
+	switch status {
+	case PendingItemReview, ApprovedItemReview, RejectedItemReview:
+		return true
+	}
+	return false
+}
+
+//ItemReview is the rating of an item by a user who received it in OrderID,
+//a review with text is shown once a store manager approves it, a rating alone counts right away
+type ItemReview struct {
+	ID             int              `json:"id" db:"id"`
+	ItemID         int              `json:"itemId" db:"item_id"`
+	ItemName       string           `json:"itemName,omitempty" db:"item_name"`
+	UserID         int              `json:"userId" db:"user_id"`
+	UserName       string           `json:"userName" db:"user_name"`
+	OrderID        int              `json:"orderId" db:"order_id"`
+	Rating         int              `json:"rating" db:"rating"`
+	Review         string           `json:"review" db:"review"`
+	Status         ItemReviewStatus `json:"status" db:"status"`
+	ModerationNote null.String      `json:"moderationNote" db:"moderation_note"`
+	ModeratedAt    null.Time        `json:"moderatedAt" db:"moderated_at"`
+	CreatedAt      time.Time        `json:"createdAt" db:"created_at"`
+	UpdatedAt      null.Time        `json:"updatedAt" db:"updated_at"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import "github.com/volatiletech/null"
+
+type UnitOfMeasure string
+
+const (
+	GramUnit       UnitOfMeasure = "g"
+	KilogramUnit   UnitOfMeasure = "kg"
+	MillilitreUnit UnitOfMeasure = "ml"
+	LitreUnit      UnitOfMeasure = "l"
+	PieceUnit      UnitOfMeasure = "pc"
+)
+
+//unitBases is the unit prices per unit are shown in and how many of the unit make one of it
+var unitBases = map[UnitOfMeasure]struct {
+	unit   UnitOfMeasure
+	factor float32
+}{
+	GramUnit:       {KilogramUnit, 1000},
+	KilogramUnit:   {KilogramUnit, 1},
+	MillilitreUnit: {LitreUnit, 1000},
+	LitreUnit:      {LitreUnit, 1},
+	PieceUnit:      {PieceUnit, 1},
+}
+
+func IsValidUnit(unit UnitOfMeasure) bool {
+	_, ok := unitBases[unit]
+	return ok
+}
+
+//BaseUnit returns the unit the price per unit of a pack sold in the unit is shown in, and the quantity of
+//the pack in that unit
+func BaseUnit(quantity float32, unit UnitOfMeasure) (float32, UnitOfMeasure) {
+	base, ok := unitBases[unit]
+	if !ok {
+		return quantity, unit
+	}
+	return quantity / base.factor, base.unit
+}
+
+//ItemVariant is a pack size of an item with its own price, stock and images, Name is shown on the product page
+//like "500 g" and PricePerUnit is the price of one PerUnit
+type ItemVariant struct {
+	ID                 int           `json:"id" db:"id"`
+	ItemID             int           `json:"itemId" db:"item_id"`
+	Name               string        `json:"name" db:"name"`
+	Quantity           float32       `json:"quantity" db:"quantity"`
+	Unit               UnitOfMeasure `json:"unit" db:"unit"`
+	Price              float32       `json:"price" db:"price"`
+	StrikeThroughPrice null.Float32  `json:"strikeThroughPrice" db:"strikethrough_price"`
+	InStock            bool          `json:"inStock" db:"in_stock"`
+	PricePerUnit       float32       `json:"pricePerUnit" db:"-"`
+	PerUnit            UnitOfMeasure `json:"perUnit" db:"-"`
+	ItemImageLinks     []string      `json:"itemImageLinks" db:"-"`
+	Images             []Image       `json:"-" db:"-"`
+}
@@ -6,19 +6,75 @@ import (
 )
 
 type Item struct {
-	ID                 int          `json:"id" db:"id"`
-	CategoryID         int          `json:"categoryID" db:"category"`
-	Name               string       `json:"name" db:"name"`
-	Price              float32      `json:"price" db:"price"`
-	StrikeThroughPrice null.Float32 `json:"strikeThroughPrice" db:"strikethrough_price"`
-	InStock            bool         `json:"inStock" db:"in_stock"`
-	CreatedAt          time.Time    `json:"-" db:"created_at"`
-	ItemImageLinks     []string     `json:"itemImageLinks" db:"-"`
-	BaseQuantity       string       `json:"baseQuantity" db:"base_quantity"`
+	ID                 int           `json:"id" db:"id"`
+	CategoryID         int           `json:"categoryID" db:"category"`
+	Name               string        `json:"name" db:"name"`
+	Price              float32       `json:"price" db:"price"`
+	StrikeThroughPrice null.Float32  `json:"strikeThroughPrice" db:"strikethrough_price"`
+	InStock            bool          `json:"inStock" db:"in_stock"`
+	CreatedAt          time.Time     `json:"-" db:"created_at"`
+	ItemImageLinks     []string      `json:"itemImageLinks" db:"-"`
+	BaseQuantity       string        `json:"baseQuantity" db:"base_quantity"`
+	TaxSlabID          null.Int      `json:"taxSlabId" db:"tax_slab_id"`
+	Category           string        `json:"category,omitempty" db:"category_name"`
+	Images             []Image       `json:"-" db:"-"`
+	Rating             float32       `json:"rating" db:"rating"`
+	RatingCount        int           `json:"ratingCount" db:"rating_count"`
+	Variants           []ItemVariant `json:"variants" db:"-"`
 }
 
+//ItemCategory is a category of the catalogue, categories nest under their parent and are shown by position,
+//a category with a visibility window is shown to users only within it
 type ItemCategory struct {
-	ID        int       `json:"id" db:"id"`
-	Category  string    `json:"category" db:"category"`
-	CreatedAt time.Time `json:"-" db:"created_at"`
+	ID           int            `json:"id" db:"id"`
+	Category     string         `json:"category" db:"category"`
+	TaxSlabID    null.Int       `json:"taxSlabId" db:"tax_slab_id"`
+	CreatedAt    time.Time      `json:"-" db:"created_at"`
+	ParentID     null.Int       `json:"parentId" db:"parent_id"`
+	Position     int            `json:"position" db:"position"`
+	ImageID      null.Int       `json:"imageId" db:"image_id"`
+	ImageLink    null.String    `json:"imageLink" db:"-"`
+	Bucket       null.String    `json:"-" db:"bucket"`
+	Path         null.String    `json:"-" db:"path"`
+	VisibleFrom  null.Time      `json:"visibleFrom" db:"visible_from"`
+	VisibleUntil null.Time      `json:"visibleUntil" db:"visible_until"`
+	Children     []ItemCategory `json:"children,omitempty" db:"-"`
+}
+
+type ItemSort string
+
+const (
+	RelevanceItemSort ItemSort = "relevance"
+	PriceAscItemSort  ItemSort = "priceAsc"
+	PriceDescItemSort ItemSort = "priceDesc"
+	NameItemSort      ItemSort = "name"
+	NewestItemSort    ItemSort = "newest"
+	RatingItemSort    ItemSort = "rating"
+)
+
+const (
+	DefaultItemPageSize = 20
+	MaxItemPageSize     = 100
+	// ItemTypoSimilarity is the trigram word similarity from which a misspelt search still matches an item
+	ItemTypoSimilarity = 0.3
+)
+
+//ItemSearch filters and sorts the catalogue, Cursor is the NextCursor of the previous page,
+//CategoryIDs also match the sub categories, VisibleOnly leaves out the items of categories hidden from users
+type ItemSearch struct {
+	Query       string
+	CategoryIDs []int64
+	MinPrice    null.Float32
+	MaxPrice    null.Float32
+	InStockOnly bool
+	VisibleOnly bool
+	Sort        ItemSort
+	Cursor      string
+	Limit       int
+}
+
+//ItemPage is a page of the catalogue, NextCursor is empty on the last page
+type ItemPage struct {
+	Items      []Item `json:"items"`
+	NextCursor string `json:"nextCursor"`
 }
@@ -49,7 +49,13 @@ type Order struct {
 	AddressID     int          `json:"addressID" db:"address_id"`
 	Status        OrderStatus  `json:"status" db:"status"`
 	OTP           null.Int     `json:"otp" db:"otp"`
+	OTPExpiresAt  null.Time    `json:"otpExpiresAt" db:"otp_expires_at"`
+	BillSubmitted null.Time    `json:"billSubmittedAt" db:"bill_submitted_at"`
+	BillConfirmed null.Time    `json:"billConfirmedAt" db:"bill_confirmed_at"`
 	Amount        float32      `json:"amount" db:"amount"`
+	CouponCode    string       `json:"couponCode,omitempty" db:"-"`
+	CouponAmount  float32      `json:"couponDiscount" db:"coupon_discount"`
+	DeliveryFee   float32      `json:"deliveryFee" db:"delivery_fee"`
 	Items         []ItemInfo   `json:"items" db:"-"`
 	UserRating    null.Float32 `json:"-" db:"user_rating"`
 	StaffRating   null.Float32 `json:"staffRating" db:"staff_rating"`
@@ -86,8 +92,23 @@ type ItemInfo struct {
 	ImageID            int          `json:"-" db:"image_id"`
 	ItemImageLinks     []string     `json:"itemImageLinks" db:"-"`
 	Discount           null.Int     `json:"discount" db:"discount"`
+	UnitPrice          float32      `json:"unitPrice" db:"-"`
 	Bucket             null.String  `json:"-" db:"bucket"`
 	Path               null.String  `json:"-" db:"path"`
+	OrderItemID        int          `json:"orderItemId,omitempty" db:"order_item_id"`
+	OriginalQuantity   null.Int     `json:"originalQuantity" db:"original_quantity"`
+	SubstituteFor      null.Int     `json:"substituteFor" db:"substitute_for"`
+	TaxName            null.String  `json:"taxName" db:"tax_name"`
+	TaxRate            float32      `json:"taxRate" db:"tax_rate"`
+	TaxInclusive       bool         `json:"taxInclusive" db:"tax_inclusive"`
+	TaxableAmount      float32      `json:"taxableAmount" db:"taxable_amount"`
+	TaxAmount          float32      `json:"taxAmount" db:"tax_amount"`
+	VariantID          null.Int     `json:"variantId" db:"variant_id"`
+	UnitQuantity       null.Float32 `json:"unitQuantity" db:"unit_quantity"`
+	Unit               null.String  `json:"unit" db:"unit"`
+	PricePerUnit       null.Float32 `json:"pricePerUnit" db:"-"`
+	PerUnit            null.String  `json:"perUnit" db:"-"`
+	PriceID            null.Int     `json:"priceId" db:"price_id"`
 }
 
 type ScheduledOrder struct {
@@ -114,8 +135,36 @@ type ScheduledOrder struct {
 type VerifyOrder struct {
 	OTP        int          `json:"otp" db:"otp"`
 	UserRating null.Float32 `json:"userRating" db:"user_rating"`
-	Amount     float32      `json:"amount" db:"amount"`
+	// Amount is ignored, the amount is always calculated by the server from the order items
+	Amount float32 `json:"amount" db:"amount"`
 }
+
+//OrderBill is the state of the bill of an order, cart orders are billed at the door by the staff
+type OrderBill struct {
+	OrderID     int         `json:"orderId" db:"id"`
+	Mode        OrderMode   `json:"mode" db:"mode"`
+	Status      OrderStatus `json:"status" db:"status"`
+	UserID      int         `json:"-" db:"user_id"`
+	StaffID     null.Int    `json:"-" db:"staff_id"`
+	Amount      float32     `json:"amount" db:"amount"`
+	SubmittedAt null.Time   `json:"submittedAt" db:"bill_submitted_at"`
+	ConfirmedAt null.Time   `json:"confirmedAt" db:"bill_confirmed_at"`
+	Items       []ItemInfo  `json:"items" db:"-"`
+}
+
+//OrderQuote is the price of an order before it is placed
+type OrderQuote struct {
+	Items          []ItemInfo `json:"items" db:"-"`
+	ItemTotal      float32    `json:"itemTotal" db:"item_total"`
+	OfferDiscount  float32    `json:"offerDiscount" db:"offer_discount"`
+	TaxableAmount  float32    `json:"taxableAmount" db:"taxable_amount"`
+	TaxAmount      float32    `json:"taxAmount" db:"tax_amount"`
+	CouponCode     string     `json:"couponCode,omitempty" db:"-"`
+	CouponDiscount float32    `json:"couponDiscount" db:"coupon_discount"`
+	DeliveryFee    float32    `json:"deliveryFee" db:"delivery_fee"`
+	Amount         float32    `json:"amount" db:"amount"`
+}
+
 type ConfirmOrder struct {
 	StaffRating null.Float32 `json:"staffRating" db:"staff_rating"`
 }
@@ -135,14 +184,15 @@ type DisputedOrder struct {
 }
 
 type DisputedOrderInfo struct {
-	OrderID    int        `json:"orderId" db:"order_id"`
-	OrderMode  OrderMode  `json:"orderMode" db:"mode"`
-	UserName   string     `json:"userName" db:"user_name"`
-	UserPhone  string     `json:"userPhone" db:"user_phone"`
-	StaffName  string     `json:"staffName" db:"staff_name"`
-	StaffPhone string     `json:"staffPhone" db:"staff_phone"`
-	Amount     float32    `json:"amount"`
-	Items      []ItemInfo `json:"items"`
+	OrderID    int            `json:"orderId" db:"order_id"`
+	OrderMode  OrderMode      `json:"orderMode" db:"mode"`
+	UserName   string         `json:"userName" db:"user_name"`
+	UserPhone  string         `json:"userPhone" db:"user_phone"`
+	StaffName  string         `json:"staffName" db:"staff_name"`
+	StaffPhone string         `json:"staffPhone" db:"staff_phone"`
+	Amount     float32        `json:"amount"`
+	Items      []ItemInfo     `json:"items"`
+	Proof      *DeliveryProof `json:"proof"`
 }
 
 type DeniedUnassignedOrders struct {
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"github.com/volatiletech/null"
+	"time"
+)
+
+type OrderItemChangeType string
+
+const (
+	SubstituteItemChange OrderItemChangeType = "substitute"
+	ReduceItemChange     OrderItemChangeType = "reduce"
+)
+
+type OrderItemChangeStatus string
+
+const (
+	PendingItemChange  OrderItemChangeStatus = "pending"
+	ApprovedItemChange OrderItemChangeStatus = "approved"
+	RejectedItemChange OrderItemChangeStatus = "rejected"
+	ExpiredItemChange  OrderItemChangeStatus = "expired"
+)
+
+//ItemChangeTimeout is how long the user has to answer a proposed change, after which the default applies:
+//reductions go through and substitutions drop the unavailable item, so the user never pays for what they didn't approve
+const ItemChangeTimeout = 10 * time.Minute
+
+//OrderItemChange is a substitution or quantity reduction of an order item proposed by the staff
+type OrderItemChange struct {
+	ID               int                   `json:"id" db:"id"`
+	OrderID          int                   `json:"orderId" db:"order_id"`
+	OrderItemID      int                   `json:"orderItemId" db:"order_item_id"`
+	ItemName         string                `json:"itemName" db:"item_name"`
+	OriginalQuantity int                   `json:"originalQuantity" db:"original_quantity"`
+	Type             OrderItemChangeType   `json:"type" db:"change_type"`
+	SubstituteItemID null.Int              `json:"substituteItemId" db:"substitute_item_id"`
+	SubstituteName   null.String           `json:"substituteName" db:"substitute_name"`
+	SubstitutePrice  null.Float32          `json:"substitutePrice" db:"substitute_price"`
+	Quantity         int                   `json:"quantity" db:"quantity"`
+	ProposedBy       int                   `json:"proposedBy" db:"proposed_by"`
+	Status           OrderItemChangeStatus `json:"status" db:"status"`
+	ExpiresAt        time.Time             `json:"expiresAt" db:"expires_at"`
+	ResolvedAt       null.Time             `json:"resolvedAt" db:"resolved_at"`
+	CreatedAt        time.Time             `json:"createdAt" db:"created_at"`
+}
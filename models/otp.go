@@ -0,0 +1,41 @@
+package models
+
+import (
+	"github.com/volatiletech/null"
+	"time"
+)
+
+const (
+	//OTPValidity is how long the delivery otp works once the order is out for delivery
+	OTPValidity = 2 * time.Hour
+	//MaxOTPAttempts is the number of wrong otp a staff can enter before the order gets locked
+	MaxOTPAttempts = 5
+	//MaxOTPResends is the number of times the user can regenerate the otp of an order
+	MaxOTPResends = 3
+)
+
+type OrderOTP struct {
+	OrderID     int       `json:"orderId" db:"order_id"`
+	OTP         int       `json:"otp" db:"otp"`
+	ExpiresAt   null.Time `json:"expiresAt" db:"expires_at"`
+	Attempts    int       `json:"attempts" db:"attempts"`
+	LockedAt    null.Time `json:"lockedAt" db:"locked_at"`
+	ResendCount int       `json:"resendCount" db:"resend_count"`
+}
+
+//IsExpired tells if the otp is not armed yet or past its validity
+func (o OrderOTP) IsExpired() bool {
+	return !o.ExpiresAt.Valid || time.Now().After(o.ExpiresAt.Time)
+}
+
+//LockedOTPOrder is an order whose delivery otp got locked after too many wrong attempts
+type LockedOTPOrder struct {
+	OrderID     int         `json:"orderId" db:"order_id"`
+	OrderMode   OrderMode   `json:"orderMode" db:"mode"`
+	UserName    null.String `json:"userName" db:"user_name"`
+	StaffID     null.Int    `json:"staffId" db:"staff_id"`
+	StaffName   null.String `json:"staffName" db:"staff_name"`
+	AddressData string      `json:"addressData" db:"address_data"`
+	Attempts    int         `json:"attempts" db:"attempts"`
+	LockedAt    time.Time   `json:"lockedAt" db:"locked_at"`
+}
@@ -11,8 +11,8 @@ type StaffOrder struct {
 	OrderId         int          `json:"orderID" db:"order_id"`
 	UserID          int          `json:"-" db:"user_id"`
 	UserName        string       `json:"userName" db:"name"`
-	UserPhone       string       `json:"userPhone" db:"phone"`
-	UserImage       string       `json:"user_image" db:"phone"`
+	UserPhone       string       `json:"userPhone,omitempty" db:"phone"`
+	UserImage       string       `json:"user_image" db:"-"`
 	OrderType       string       `json:"orderType" db:"order_type"`
 	DeliveryTime    null.Time    `json:"deliveryTime" db:"delivery_time"`
 	StaffRating     null.Float32 `json:"staffRating" db:"staff_rating"`
@@ -24,6 +24,7 @@ type StaffOrder struct {
 	UserLat         null.Float64 `json:"userLat" db:"lat"`
 	UserLong        null.Float64 `json:"userLong" db:"long"`
 	Status          OrderStatus  `json:"status" db:"status"`
+	ProofRequired   bool         `json:"proofRequired" db:"proof_required"`
 }
 
 type UnapprovedStaff struct {
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"github.com/volatiletech/null"
+	"time"
+)
+
+//StockAlert is a user waiting for an out of stock item, or a variant of it, to be back in stock
+type StockAlert struct {
+	ID          int         `json:"id" db:"id"`
+	ItemID      int         `json:"itemId" db:"item_id"`
+	VariantID   null.Int    `json:"variantId" db:"variant_id"`
+	ItemName    string      `json:"itemName" db:"item_name"`
+	VariantName null.String `json:"variantName" db:"variant_name"`
+	InStock     bool        `json:"inStock" db:"in_stock"`
+	CreatedAt   time.Time   `json:"createdAt" db:"created_at"`
+}
+
+//StockDemand is the number of users waiting for an out of stock item, or a variant of it
+type StockDemand struct {
+	ItemID      int         `json:"itemId" db:"item_id"`
+	VariantID   null.Int    `json:"variantId" db:"variant_id"`
+	ItemName    string      `json:"itemName" db:"item_name"`
+	VariantName null.String `json:"variantName" db:"variant_name"`
+	Subscribers int         `json:"subscribers" db:"subscribers"`
+	WaitingFrom time.Time   `json:"waitingFrom" db:"waiting_from"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"github.com/volatiletech/null"
+	"time"
+)
+
+//TaxSlab is a tax rate assigned to categories and items, inclusive slabs are already part of the item price
+type TaxSlab struct {
+	ID        int       `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	Rate      float32   `json:"rate" db:"rate"`
+	Inclusive bool      `json:"inclusive" db:"inclusive"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt null.Time `json:"updatedAt" db:"updated_at"`
+}
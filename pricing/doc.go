@@ -0,0 +1,2 @@
+//Package pricing holds the rules to price the items of an order, every order amount is calculated with it
+package pricing
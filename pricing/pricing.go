@@ -0,0 +1,135 @@
+package pricing
+
+import (
+	"errors"
+	"fmt"
+	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/volatiletech/null"
+	"math"
+)
+
+//ErrMinOrderValue is returned when the order is worth less than the minimum order value of the store
+var ErrMinOrderValue = errors.New("minimum order value not met")
+
+//Round rounds the amount to paise
+func Round(amount float32) float32 {
+	return float32(math.Round(float64(amount)*100) / 100)
+}
+
+//UnitPrice returns the price of one unit with the discount percentage applied, rounded to paise
+func UnitPrice(price float32, discount int) float32 {
+	return Round(price * float32(100-discount) / 100)
+}
+
+//StrikeThroughPrice returns the price shown struck through next to the unit price, the higher of the
+//strike-through price of the catalogue and the price before the discount, null when it isn't above the unit price
+func StrikeThroughPrice(price float32, strikeThrough null.Float32, discount int) null.Float32 {
+	mrp := price
+	if strikeThrough.Valid && strikeThrough.Float32 > mrp {
+		mrp = strikeThrough.Float32
+	}
+	if mrp <= UnitPrice(price, discount) {
+		return null.Float32{}
+	}
+	return null.Float32From(mrp)
+}
+
+//Item prices the item from its price, discount, quantity and tax slab, filling its unit price,
+//strike-through price and tax breakup
+func Item(item *models.ItemInfo) {
+	discount := item.Discount.Int
+	item.UnitPrice = UnitPrice(item.Price, discount)
+	item.StrikeThroughPrice = StrikeThroughPrice(item.Price, item.StrikeThroughPrice, discount)
+
+	if item.UnitQuantity.Valid && item.Unit.Valid {
+		pricePerUnit, perUnit := PricePerUnit(item.UnitPrice, item.UnitQuantity.Float32, models.UnitOfMeasure(item.Unit.String))
+		item.PricePerUnit, item.PerUnit = null.Float32From(pricePerUnit), null.StringFrom(string(perUnit))
+	}
+
+	net := Net(*item)
+	if item.TaxInclusive {
+		item.TaxableAmount = Round(net / (1 + item.TaxRate/100))
+		item.TaxAmount = Round(net - item.TaxableAmount)
+		return
+	}
+	item.TaxableAmount = net
+	item.TaxAmount = Round(net * item.TaxRate / 100)
+}
+
+//PricePerUnit returns the price of one base unit of a pack of the quantity, like the price per kg of a 500 g pack
+func PricePerUnit(price, quantity float32, unit models.UnitOfMeasure) (float32, models.UnitOfMeasure) {
+	base, baseUnit := models.BaseUnit(quantity, unit)
+	if base <= 0 {
+		return price, baseUnit
+	}
+	return Round(price / base), baseUnit
+}
+
+//Variant fills the price per unit of the variant
+func Variant(variant *models.ItemVariant) {
+	variant.PricePerUnit, variant.PerUnit = PricePerUnit(variant.Price, variant.Quantity, variant.Unit)
+}
+
+//Gross returns the amount of the item before the discount
+func Gross(item models.ItemInfo) float32 {
+	return Round(item.Price * float32(item.Quantity))
+}
+
+//Net returns the amount of the item after the discount, taxes included only when the slab is inclusive
+func Net(item models.ItemInfo) float32 {
+	return Round(UnitPrice(item.Price, item.Discount.Int) * float32(item.Quantity))
+}
+
+//Discount returns how much the discount takes off the item
+func Discount(item models.ItemInfo) float32 {
+	return Round(Gross(item) - Net(item))
+}
+
+//Total returns the amount the customer pays for the item priced by Item
+func Total(item models.ItemInfo) float32 {
+	return Round(item.TaxableAmount + item.TaxAmount)
+}
+
+//OrderValue returns what the priced items of the order are worth
+func OrderValue(items []models.ItemInfo) float32 {
+	var value float32
+	for _, item := range items {
+		value += Total(item)
+	}
+	return Round(value)
+}
+
+//OrderAmount returns the amount of the order from its priced items, the coupon discount can't take the items below zero
+//and the delivery fee is charged on top
+func OrderAmount(items []models.ItemInfo, couponDiscount, deliveryFee float32) float32 {
+	return AmountDue(OrderValue(items), couponDiscount, deliveryFee)
+}
+
+//AmountDue returns what is paid for an order worth the value after the coupon discount and the delivery fee
+func AmountDue(orderValue, couponDiscount, deliveryFee float32) float32 {
+	amount := float32(math.Max(float64(orderValue-couponDiscount), 0))
+	return Round(amount + deliveryFee)
+}
+
+//DeliveryFee returns the fee of the first rule of the mode covering the distance from the store,
+//nothing when the order value reaches the free above of the rule or no rule covers the distance
+func DeliveryFee(rules []models.DeliveryFeeRule, mode models.OrderMode, distance float64, orderValue float32) float32 {
+	for _, rule := range rules {
+		if rule.Mode != mode || distance < rule.MinDistance || (rule.MaxDistance.Valid && distance >= rule.MaxDistance.Float64) {
+			continue
+		}
+		if rule.FreeAbove.Valid && orderValue >= rule.FreeAbove.Float32 {
+			return 0
+		}
+		return Round(rule.Fee)
+	}
+	return 0
+}
+
+//CheckMinOrderValue returns ErrMinOrderValue when the order value is below the minimum of the store
+func CheckMinOrderValue(orderValue, minOrderValue float32) error {
+	if orderValue < minOrderValue {
+		return fmt.Errorf("%w, add items worth %.2f more", ErrMinOrderValue, minOrderValue-orderValue)
+	}
+	return nil
+}
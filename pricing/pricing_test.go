@@ -0,0 +1,262 @@
+package pricing
+
+import (
+	"errors"
+	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/volatiletech/null"
+	"testing"
+)
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount float32
+		want   float32
+	}{
+		{"whole", 10, 10},
+		{"two decimals", 12.34, 12.34},
+		{"half paisa rounds up", 0.125, 0.13},
+		{"half paisa rounds up from odd", 0.375, 0.38},
+		{"half paisa above a rupee", 1.625, 1.63},
+		{"half paisa of a negative rounds away from zero", -0.125, -0.13},
+		{"below half paisa rounds down", 2.124, 2.12},
+		{"above half paisa rounds up", 2.126, 2.13},
+		{"just above the boundary", 10.005, 10.01},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Round(tt.amount); got != tt.want {
+				t.Errorf("Round(%v) = %v, want %v", tt.amount, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnitPrice(t *testing.T) {
+	tests := []struct {
+		name     string
+		price    float32
+		discount int
+		want     float32
+	}{
+		{"no discount", 100, 0, 100},
+		{"discount", 100, 10, 90},
+		{"discount rounded to paise", 99.99, 15, 84.99},
+		{"full discount", 10, 100, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UnitPrice(tt.price, tt.discount); got != tt.want {
+				t.Errorf("UnitPrice(%v, %v) = %v, want %v", tt.price, tt.discount, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStrikeThroughPrice(t *testing.T) {
+	tests := []struct {
+		name          string
+		price         float32
+		strikeThrough null.Float32
+		discount      int
+		want          null.Float32
+	}{
+		{"no strike-through and no discount", 100, null.Float32{}, 0, null.Float32{}},
+		{"strike-through without discount", 100, null.Float32From(120), 0, null.Float32From(120)},
+		{"strike-through below the price", 100, null.Float32From(90), 0, null.Float32{}},
+		{"strike-through equal to the price", 100, null.Float32From(100), 0, null.Float32{}},
+		{"discount strikes the price through", 100, null.Float32{}, 10, null.Float32From(100)},
+		{"discount keeps the higher strike-through", 100, null.Float32From(120), 10, null.Float32From(120)},
+		{"discount with strike-through below the price", 100, null.Float32From(80), 10, null.Float32From(100)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StrikeThroughPrice(tt.price, tt.strikeThrough, tt.discount); got != tt.want {
+				t.Errorf("StrikeThroughPrice(%v, %v, %v) = %v, want %v", tt.price, tt.strikeThrough, tt.discount, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestItem(t *testing.T) {
+	tests := []struct {
+		name              string
+		item              models.ItemInfo
+		wantUnitPrice     float32
+		wantStrikeThrough null.Float32
+		wantTaxable       float32
+		wantTax           float32
+		wantTotal         float32
+	}{
+		{
+			name:          "exclusive tax is added on top",
+			item:          models.ItemInfo{Price: 100, Quantity: 2, TaxRate: 5},
+			wantUnitPrice: 100,
+			wantTaxable:   200,
+			wantTax:       10,
+			wantTotal:     210,
+		},
+		{
+			name:              "exclusive tax on the discounted price",
+			item:              models.ItemInfo{Price: 100, Quantity: 2, Discount: null.IntFrom(10), TaxRate: 5},
+			wantUnitPrice:     90,
+			wantStrikeThrough: null.Float32From(100),
+			wantTaxable:       180,
+			wantTax:           9,
+			wantTotal:         189,
+		},
+		{
+			name:          "inclusive tax is taken out of the price",
+			item:          models.ItemInfo{Price: 118, Quantity: 1, TaxRate: 18, TaxInclusive: true},
+			wantUnitPrice: 118,
+			wantTaxable:   100,
+			wantTax:       18,
+			wantTotal:     118,
+		},
+		{
+			name:          "inclusive tax rounded to paise",
+			item:          models.ItemInfo{Price: 100, Quantity: 1, TaxRate: 5, TaxInclusive: true},
+			wantUnitPrice: 100,
+			wantTaxable:   95.24,
+			wantTax:       4.76,
+			wantTotal:     100,
+		},
+		{
+			name:              "inclusive tax on the discounted price",
+			item:              models.ItemInfo{Price: 200, Quantity: 3, Discount: null.IntFrom(25), StrikeThroughPrice: null.Float32From(250), TaxRate: 12, TaxInclusive: true},
+			wantUnitPrice:     150,
+			wantStrikeThrough: null.Float32From(250),
+			wantTaxable:       401.79,
+			wantTax:           48.21,
+			wantTotal:         450,
+		},
+		{
+			name:          "untaxed item",
+			item:          models.ItemInfo{Price: 45.5, Quantity: 4},
+			wantUnitPrice: 45.5,
+			wantTaxable:   182,
+			wantTax:       0,
+			wantTotal:     182,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := tt.item
+			Item(&item)
+			if item.UnitPrice != tt.wantUnitPrice {
+				t.Errorf("UnitPrice = %v, want %v", item.UnitPrice, tt.wantUnitPrice)
+			}
+			if item.StrikeThroughPrice != tt.wantStrikeThrough {
+				t.Errorf("StrikeThroughPrice = %v, want %v", item.StrikeThroughPrice, tt.wantStrikeThrough)
+			}
+			if item.TaxableAmount != tt.wantTaxable {
+				t.Errorf("TaxableAmount = %v, want %v", item.TaxableAmount, tt.wantTaxable)
+			}
+			if item.TaxAmount != tt.wantTax {
+				t.Errorf("TaxAmount = %v, want %v", item.TaxAmount, tt.wantTax)
+			}
+			if got := Total(item); got != tt.wantTotal {
+				t.Errorf("Total = %v, want %v", got, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestItemPricePerUnit(t *testing.T) {
+	item := models.ItemInfo{Price: 60, Quantity: 1, Discount: null.IntFrom(50), UnitQuantity: null.Float32From(500), Unit: null.StringFrom(string(models.GramUnit))}
+	Item(&item)
+	if item.PricePerUnit != null.Float32From(60) || item.PerUnit != null.StringFrom(string(models.KilogramUnit)) {
+		t.Errorf("price per unit = %v per %v, want 60 per kg", item.PricePerUnit, item.PerUnit)
+	}
+}
+
+func TestOrderAmount(t *testing.T) {
+	priced := func(items ...models.ItemInfo) []models.ItemInfo {
+		for i := range items {
+			Item(&items[i])
+		}
+		return items
+	}
+	tests := []struct {
+		name           string
+		items          []models.ItemInfo
+		couponDiscount float32
+		deliveryFee    float32
+		want           float32
+	}{
+		{"no items", nil, 0, 0, 0},
+		{"items only", priced(models.ItemInfo{Price: 100, Quantity: 2, TaxRate: 5}, models.ItemInfo{Price: 118, Quantity: 1, TaxRate: 18, TaxInclusive: true}), 0, 0, 328},
+		{"coupon and delivery fee", priced(models.ItemInfo{Price: 100, Quantity: 2, TaxRate: 5}, models.ItemInfo{Price: 118, Quantity: 1, TaxRate: 18, TaxInclusive: true}), 50, 20, 298},
+		{"coupon can't take the items below zero", priced(models.ItemInfo{Price: 100, Quantity: 1}), 150, 30, 30},
+		{"paise add up", priced(models.ItemInfo{Price: 0.1, Quantity: 3}, models.ItemInfo{Price: 0.2, Quantity: 1}), 0, 0.1, 0.6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := OrderAmount(tt.items, tt.couponDiscount, tt.deliveryFee); got != tt.want {
+				t.Errorf("OrderAmount() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeliveryFee(t *testing.T) {
+	rules := []models.DeliveryFeeRule{
+		{Mode: models.DeliveryMode, MinDistance: 0, MaxDistance: null.Float64From(3), Fee: 20, FreeAbove: null.Float32From(500)},
+		{Mode: models.DeliveryMode, MinDistance: 3, MaxDistance: null.Float64From(8), Fee: 40.499},
+		{Mode: models.CartMode, MinDistance: 0, Fee: 10, FreeAbove: null.Float32From(300)},
+	}
+	tests := []struct {
+		name       string
+		rules      []models.DeliveryFeeRule
+		mode       models.OrderMode
+		distance   float64
+		orderValue float32
+		want       float32
+	}{
+		{"no rules", nil, models.DeliveryMode, 1, 100, 0},
+		{"nearest rule", rules, models.DeliveryMode, 2, 100, 20},
+		{"free above reached", rules, models.DeliveryMode, 2, 500, 0},
+		{"just below free above", rules, models.DeliveryMode, 2, 499.99, 20},
+		{"min distance is covered", rules, models.DeliveryMode, 3, 100, 40.5},
+		{"rule without free above", rules, models.DeliveryMode, 5, 10000, 40.5},
+		{"max distance is not covered", rules, models.DeliveryMode, 8, 100, 0},
+		{"rule without max distance", rules, models.CartMode, 25, 299.99, 10},
+		{"rules of the other mode are skipped", rules[2:], models.DeliveryMode, 1, 100, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DeliveryFee(tt.rules, tt.mode, tt.distance, tt.orderValue); got != tt.want {
+				t.Errorf("DeliveryFee() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckMinOrderValue(t *testing.T) {
+	tests := []struct {
+		name          string
+		orderValue    float32
+		minOrderValue float32
+		wantErr       string
+	}{
+		{"no minimum", 0, 0, ""},
+		{"minimum reached", 200, 200, ""},
+		{"above the minimum", 250.5, 200, ""},
+		{"below the minimum", 199, 200, "minimum order value not met, add items worth 1.00 more"},
+		{"paise below the minimum", 149.5, 150, "minimum order value not met, add items worth 0.50 more"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckMinOrderValue(tt.orderValue, tt.minOrderValue)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("CheckMinOrderValue() = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrMinOrderValue) || err.Error() != tt.wantErr {
+				t.Errorf("CheckMinOrderValue() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
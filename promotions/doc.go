@@ -0,0 +1,2 @@
+//Package promotions evaluates coupon codes against the lines of an order
+package promotions
@@ -0,0 +1,86 @@
+package promotions
+
+import (
+	"errors"
+	"fmt"
+	"github.com/RemoteState/yourdaily-server/models"
+	"math"
+	"time"
+)
+
+var (
+	ErrInvalidCoupon   = errors.New("invalid coupon code")
+	ErrCouponNotActive = errors.New("coupon is not active")
+	ErrUsageLimit      = errors.New("coupon usage limit reached")
+	ErrNotApplicable   = errors.New("coupon is not applicable on these items")
+	ErrMinOrderValue   = errors.New("minimum order value not met")
+)
+
+//IsCouponError reports whether the coupon was rejected by one of the rules
+func IsCouponError(err error) bool {
+	for _, couponErr := range []error{ErrInvalidCoupon, ErrCouponNotActive, ErrUsageLimit, ErrNotApplicable, ErrMinOrderValue} {
+		if errors.Is(err, couponErr) {
+			return true
+		}
+	}
+	return false
+}
+
+//Discount returns the discount the coupon gives on the lines, or why it can't be applied
+func Discount(coupon models.Coupon, lines []models.CouponLine, usage models.CouponUsage, now time.Time) (float32, error) {
+	if now.Before(coupon.StartsAt) || (coupon.EndsAt.Valid && !now.Before(coupon.EndsAt.Time)) {
+		return 0, ErrCouponNotActive
+	}
+	if coupon.UsageLimit.Valid && usage.Total >= coupon.UsageLimit.Int {
+		return 0, ErrUsageLimit
+	}
+	if coupon.PerUserLimit.Valid && usage.ByUser >= coupon.PerUserLimit.Int {
+		return 0, fmt.Errorf("%w, can be used %d times per user", ErrUsageLimit, coupon.PerUserLimit.Int)
+	}
+
+	var orderValue, eligible float32
+	for _, line := range lines {
+		orderValue += line.Amount
+		if appliesTo(coupon, line) {
+			eligible += line.Amount
+		}
+	}
+	if orderValue < coupon.MinOrderValue {
+		return 0, fmt.Errorf("%w, add items worth %.2f more", ErrMinOrderValue, coupon.MinOrderValue-orderValue)
+	}
+	if eligible <= 0 {
+		return 0, ErrNotApplicable
+	}
+
+	discount := coupon.Value
+	if coupon.DiscountType == models.PercentageCoupon {
+		discount = eligible * coupon.Value / 100
+		if coupon.MaxDiscount.Valid && discount > coupon.MaxDiscount.Float32 {
+			discount = coupon.MaxDiscount.Float32
+		}
+	}
+	if discount > eligible {
+		discount = eligible
+	}
+	return float32(math.Round(float64(discount)*100) / 100), nil
+}
+
+//appliesTo tells if the line is in the scope of the coupon
+func appliesTo(coupon models.Coupon, line models.CouponLine) bool {
+	switch coupon.Scope {
+	case models.CategoryCouponScope:
+		return contains(coupon.CategoryIDs, line.CategoryID)
+	case models.ItemCouponScope:
+		return contains(coupon.ItemIDs, line.ItemID)
+	}
+	return true
+}
+
+func contains(ids []int64, id int) bool {
+	for _, v := range ids {
+		if v == int64(id) {
+			return true
+		}
+	}
+	return false
+}
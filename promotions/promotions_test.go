@@ -0,0 +1,200 @@
+package promotions
+
+import (
+	"errors"
+	"fmt"
+	"github.com/RemoteState/yourdaily-server/models"
+	"github.com/volatiletech/null"
+	"testing"
+	"time"
+)
+
+func TestDiscount(t *testing.T) {
+	now := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+	coupon := func(change func(c *models.Coupon)) models.Coupon {
+		c := models.Coupon{
+			DiscountType: models.FlatCoupon,
+			Value:        50,
+			Scope:        models.OrderCouponScope,
+			StartsAt:     now.Add(-24 * time.Hour),
+		}
+		if change != nil {
+			change(&c)
+		}
+		return c
+	}
+	lines := []models.CouponLine{
+		{ItemID: 1, CategoryID: 10, Quantity: 2, Amount: 300},
+		{ItemID: 2, CategoryID: 20, Quantity: 1, Amount: 200},
+	}
+	tests := []struct {
+		name    string
+		coupon  models.Coupon
+		lines   []models.CouponLine
+		usage   models.CouponUsage
+		want    float32
+		wantErr error
+	}{
+		{
+			name:   "flat",
+			coupon: coupon(nil),
+			lines:  lines,
+			want:   50,
+		},
+		{
+			name:   "percentage",
+			coupon: coupon(func(c *models.Coupon) { c.DiscountType, c.Value = models.PercentageCoupon, 10 }),
+			lines:  lines,
+			want:   50,
+		},
+		{
+			name: "percentage capped at max discount",
+			coupon: coupon(func(c *models.Coupon) {
+				c.DiscountType, c.Value, c.MaxDiscount = models.PercentageCoupon, 20, null.Float32From(60)
+			}),
+			lines: lines,
+			want:  60,
+		},
+		{
+			name:   "percentage rounded to paise",
+			coupon: coupon(func(c *models.Coupon) { c.DiscountType, c.Value = models.PercentageCoupon, 15 }),
+			lines:  []models.CouponLine{{ItemID: 1, CategoryID: 10, Quantity: 1, Amount: 99.99}},
+			want:   15,
+		},
+		{
+			name:   "flat can't exceed the eligible amount",
+			coupon: coupon(func(c *models.Coupon) { c.Value = 1000 }),
+			lines:  lines,
+			want:   500,
+		},
+		{
+			name:    "not started",
+			coupon:  coupon(func(c *models.Coupon) { c.StartsAt = now.Add(time.Hour) }),
+			lines:   lines,
+			wantErr: ErrCouponNotActive,
+		},
+		{
+			name:   "starts now",
+			coupon: coupon(func(c *models.Coupon) { c.StartsAt = now }),
+			lines:  lines,
+			want:   50,
+		},
+		{
+			name:    "ended",
+			coupon:  coupon(func(c *models.Coupon) { c.EndsAt = null.TimeFrom(now) }),
+			lines:   lines,
+			wantErr: ErrCouponNotActive,
+		},
+		{
+			name:   "ends later",
+			coupon: coupon(func(c *models.Coupon) { c.EndsAt = null.TimeFrom(now.Add(time.Second)) }),
+			lines:  lines,
+			want:   50,
+		},
+		{
+			name:    "usage limit reached",
+			coupon:  coupon(func(c *models.Coupon) { c.UsageLimit = null.IntFrom(5) }),
+			lines:   lines,
+			usage:   models.CouponUsage{Total: 5},
+			wantErr: ErrUsageLimit,
+		},
+		{
+			name:   "usage limit not reached",
+			coupon: coupon(func(c *models.Coupon) { c.UsageLimit = null.IntFrom(5) }),
+			lines:  lines,
+			usage:  models.CouponUsage{Total: 4, ByUser: 4},
+			want:   50,
+		},
+		{
+			name:    "per user limit reached",
+			coupon:  coupon(func(c *models.Coupon) { c.PerUserLimit = null.IntFrom(1) }),
+			lines:   lines,
+			usage:   models.CouponUsage{Total: 3, ByUser: 1},
+			wantErr: ErrUsageLimit,
+		},
+		{
+			name:    "below the min order value",
+			coupon:  coupon(func(c *models.Coupon) { c.MinOrderValue = 600 }),
+			lines:   lines,
+			wantErr: ErrMinOrderValue,
+		},
+		{
+			name:   "min order value reached",
+			coupon: coupon(func(c *models.Coupon) { c.MinOrderValue = 500 }),
+			lines:  lines,
+			want:   50,
+		},
+		{
+			name: "category scope counts the lines of the category only",
+			coupon: coupon(func(c *models.Coupon) {
+				c.DiscountType, c.Value, c.Scope, c.CategoryIDs = models.PercentageCoupon, 10, models.CategoryCouponScope, []int64{20}
+			}),
+			lines: lines,
+			want:  20,
+		},
+		{
+			name: "item scope caps a flat discount at the item",
+			coupon: coupon(func(c *models.Coupon) {
+				c.Value, c.Scope, c.ItemIDs = 400, models.ItemCouponScope, []int64{1}
+			}),
+			lines: lines,
+			want:  300,
+		},
+		{
+			name: "min order value counts all the lines",
+			coupon: coupon(func(c *models.Coupon) {
+				c.Scope, c.ItemIDs, c.MinOrderValue = models.ItemCouponScope, []int64{2}, 450
+			}),
+			lines: lines,
+			want:  50,
+		},
+		{
+			name:    "no line in scope",
+			coupon:  coupon(func(c *models.Coupon) { c.Scope, c.ItemIDs = models.ItemCouponScope, []int64{3} }),
+			lines:   lines,
+			wantErr: ErrNotApplicable,
+		},
+		{
+			name:    "no lines",
+			coupon:  coupon(nil),
+			wantErr: ErrNotApplicable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Discount(tt.coupon, tt.lines, tt.usage, now)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Discount() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Discount() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Discount() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsCouponError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"rule", ErrCouponNotActive, true},
+		{"wrapped rule", fmt.Errorf("%w, add items worth 1.00 more", ErrMinOrderValue), true},
+		{"other error", errors.New("connection refused"), false},
+		{"no error", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsCouponError(tt.err); got != tt.want {
+				t.Errorf("IsCouponError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
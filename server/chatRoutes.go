@@ -11,6 +11,12 @@ func ChatRoutes(r chi.Router) {
 		chat.Use(middlewares.AuthMiddleware)
 		chat.Get("/", handlers.GetAllMessage)
 		chat.Post("/", handlers.PostMessage)
+		chat.Put("/read", handlers.MarkMessagesRead)
+		chat.Post("/attachment", handlers.UploadChatAttachment)
+		chat.Post("/report", handlers.ReportMessage)
+
+		// websocket for realtime chat, typing indicators and receipts
+		chat.Get("/ws", handlers.ChatSocket)
 
 	})
 }
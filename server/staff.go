@@ -30,6 +30,20 @@ func StaffRoutes(r chi.Router) {
 
 			order.Post("/reject/{id}", handlers.RejectOrderForStaff)
 
+			//photo/signature captured at delivery
+			order.Post("/proof/{id}", handlers.UploadDeliveryProof)
+
+			//items the user bought from the cart, priced by the server
+			order.Put("/bill/{id}", handlers.SubmitOrderBill)
+
+			//substitute or reduce an unavailable item of a delivery order
+			order.Post("/item-change/{id}", handlers.ProposeOrderItemChange)
+			order.Get("/item-change/{id}", handlers.GetOrderItemChanges)
+
+			//masked contact to call the user of the order
+			order.Get("/contact/{id}", handlers.GetOrderContact)
+			order.Post("/contact/call", handlers.RedeemCallToken)
+
 		})
 
 		//send response to accept new order
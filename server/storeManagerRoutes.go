@@ -21,6 +21,8 @@ func storeMangerRoutes(r chi.Router) {
 		sm.Get("/dashboard/order/disputed", handlers.GetAllDisputedOrders)
 		sm.Get("/dashboard/order/disputed/{id}", handlers.GetAllDisputedOrderInfo)
 		sm.Put("/dashboard/order/disputed/{id}", handlers.MarkAsResolved)
+		sm.Get("/dashboard/order/otp-locked", handlers.GetLockedOTPOrders)
+		sm.Put("/dashboard/order/otp-locked/{id}", handlers.UnlockOTP)
 		sm.Get("/dashboard/order/{orderType}", handlers.GetAllOrdersWithStatus)
 		sm.Get("/dashboard/order/new", handlers.GetNewOrderForStoreManger)
 		sm.Put("/dashboard/unflag/user/{id}", handlers.UnFlagUser)
@@ -29,6 +31,11 @@ func storeMangerRoutes(r chi.Router) {
 		sm.Put("/staff/{status}/{id}", handlers.EnableDisableStaff)
 		sm.Put("/staff/update/role", handlers.ChangeStaffRole)
 		sm.Get("/scheduled/orders", handlers.GetScheduledOrders)
+		sm.Get("/order/invoice/{id}", handlers.GetOrderInvoice)
+		sm.Get("/store-details", handlers.GetStoreDetails)
+		sm.Put("/store-details", handlers.UpdateStoreDetails)
+		sm.Get("/delivery-settings", handlers.GetDeliverySettings)
+		sm.Put("/delivery-settings", handlers.UpdateDeliverySettings)
 		sm.Delete("/cancel/scheduled/order/{id}", handlers.CancelScheduledOrder)
 
 		sm.Route("/download", func(smd chi.Router) {
@@ -42,10 +49,32 @@ func storeMangerRoutes(r chi.Router) {
 		sm.Route("/item", func(item chi.Router) {
 			item.Get("/", handlers.GetItemsForStoreManager)
 			item.Post("/", handlers.CreateItem)
+			item.Get("/list", handlers.ListItemsForStoreManager)
+			// out of stock items users wait for
+			item.Get("/demand", handlers.GetStockDemand)
+			// bulk changes as csv
+			item.Post("/import", handlers.ImportCatalogue)
+			item.Get("/export", handlers.ExportCatalogue)
 			item.Get("/{id}", handlers.GetItemById)
 			item.Put("/{id}", handlers.ModifyItem)
 			item.Delete("/{id}", handlers.ArchiveItem)
 			item.Post("/image/{id}", handlers.AddImageForExistingItem)
+			// pack sizes of an item
+			item.Post("/{id}/variant", handlers.CreateItemVariant)
+			item.Put("/variant/{id}", handlers.ModifyItemVariant)
+			item.Delete("/variant/{id}", handlers.ArchiveItemVariant)
+			// price history and scheduled price changes
+			item.Get("/{id}/price", handlers.GetItemPrices)
+			item.Post("/{id}/price", handlers.ScheduleItemPrice)
+			item.Get("/price/{id}", handlers.GetItemPrice)
+			item.Delete("/price/{id}", handlers.CancelItemPrice)
+			item.Put("/tax/{id}", handlers.SetItemTaxSlab)
+		})
+
+		// moderation of item reviews
+		sm.Route("/review", func(review chi.Router) {
+			review.Get("/", handlers.GetItemReviewsForModeration)
+			review.Put("/{id}", handlers.ModerateItemReview)
 		})
 
 		// category
@@ -54,6 +83,24 @@ func storeMangerRoutes(r chi.Router) {
 			category.Post("/", handlers.CreateCategory)
 			category.Put("/{id}", handlers.ModifyCategory)
 			category.Delete("/{id}", handlers.ArchiveCategory)
+			category.Put("/order", handlers.OrderCategories)
+			category.Put("/tax/{id}", handlers.SetCategoryTaxSlab)
+		})
+
+		// coupons
+		sm.Route("/coupon", func(coupon chi.Router) {
+			coupon.Get("/", handlers.GetCoupons)
+			coupon.Post("/", handlers.CreateCoupon)
+			coupon.Put("/{id}", handlers.UpdateCoupon)
+			coupon.Delete("/{id}", handlers.ArchiveCoupon)
+		})
+
+		// tax slabs
+		sm.Route("/tax", func(tax chi.Router) {
+			tax.Get("/", handlers.GetTaxSlabs)
+			tax.Post("/", handlers.CreateTaxSlab)
+			tax.Put("/{id}", handlers.UpdateTaxSlab)
+			tax.Delete("/{id}", handlers.ArchiveTaxSlab)
 		})
 
 		// staff & order manage
@@ -71,6 +118,22 @@ func storeMangerRoutes(r chi.Router) {
 		// image upload
 		sm.Post("/image/{imageType}", handlers.AddImageOfGivenType)
 
+		// order chat, read-only unless the order is disputed
+		sm.Route("/chat", func(chat chi.Router) {
+			chat.Get("/", handlers.GetAllMessage)
+			chat.Post("/", handlers.PostMessage)
+			chat.Put("/read", handlers.MarkMessagesRead)
+			chat.Post("/attachment", handlers.UploadChatAttachment)
+			chat.Get("/ws", handlers.ChatSocket)
+
+			// moderation
+			chat.Get("/settings", handlers.GetChatSettings)
+			chat.Put("/settings", handlers.UpdateChatSettings)
+			chat.Get("/reports", handlers.GetChatReports)
+			chat.Put("/reports/{id}", handlers.ResolveChatReport)
+			chat.Put("/mute", handlers.MuteChatParticipant)
+		})
+
 		// discount offers
 		sm.Post("/offer", handlers.CreateNewOffer)
 		sm.Get("/offer", handlers.GetActiveOffer)
@@ -26,20 +26,39 @@ func userRoutes(r chi.Router) {
 		user.Route("/order", func(order chi.Router) {
 			// order - now
 			order.Post("/now", handlers.OrderNow)
+			//price the items before ordering them
+			order.Post("/quote", handlers.QuoteOrder)
+			//order the items of a previous order again
+			order.Post("/reorder/{id}", handlers.Reorder)
 			order.Get("/", handlers.AllPastOrder)
 
 			//get order info by id
 			order.Get("/{id}", handlers.OrderInfo)
 			order.Delete("/{id}", handlers.CancelOrder)
 			order.Get("/status/{id}", handlers.OrderStatus)
+			order.Post("/otp/{id}", handlers.ResendOTP)
 			order.Get("/staff/{id}", handlers.GetStaffByID)
 
+			//masked contact to call the staff of the order
+			order.Get("/contact/{id}", handlers.GetOrderContact)
+			order.Post("/contact/call", handlers.RedeemCallToken)
+
 			//routes for list of active order of the users active == processing,accepted,outForDelivery
 			order.Get("/active", handlers.GetActiveOrdersForUser)
 
 			//route to mark order as disputed
 			order.Post("/dispute/{id}", handlers.DisputeOrder)
 
+			//confirm or reject the bill of a cart order
+			order.Put("/bill/{id}", handlers.ConfirmOrderBill)
+
+			//item changes proposed by the staff, answered with the change id
+			order.Get("/item-change/{id}", handlers.GetOrderItemChanges)
+			order.Put("/item-change/{id}", handlers.AnswerOrderItemChange)
+
+			//invoice of a delivered order, ?format=pdf to download it
+			order.Get("/invoice/{id}", handlers.GetOrderInvoice)
+
 			//confirm order completion
 			order.Put("/confirm/{id}", handlers.ConfirmOrderDelivery)
 
@@ -55,7 +74,19 @@ func userRoutes(r chi.Router) {
 
 		// item & category
 		user.Get("/item", handlers.GetAllItems)
-		user.Get("/category", handlers.GetAllCategories)
+		user.Get("/item/search", handlers.SearchItems)
+		user.Get("/item/list", handlers.ListItems)
+		user.Get("/item/{id}", handlers.GetVisibleItemById)
+		// reviews of items received in a delivered order
+		user.Get("/item/{id}/review", handlers.GetItemReviews)
+		user.Get("/item/{id}/review/mine", handlers.GetMyItemReview)
+		user.Put("/item/{id}/review", handlers.SaveItemReview)
+		user.Delete("/item/{id}/review", handlers.ArchiveItemReview)
+		// back in stock alerts
+		user.Put("/item/{id}/alert", handlers.SetStockAlert)
+		user.Delete("/item/{id}/alert", handlers.RemoveStockAlert)
+		user.Get("/alert", handlers.GetStockAlerts)
+		user.Get("/category", handlers.GetCategoryTree)
 
 		// fcm
 		user.Post("/fcm", handlers.UpdateFcmToken)
@@ -66,6 +97,30 @@ func userRoutes(r chi.Router) {
 		// offer
 		user.Get("/offer", handlers.GetActiveOffer)
 
+		// coupon
+		user.Post("/coupon/validate", handlers.ValidateCoupon)
+
+		// cart, kept on the server so it is the same on all devices
+		user.Route("/cart", func(cart chi.Router) {
+			cart.Get("/", handlers.GetCart)
+			cart.Put("/", handlers.UpdateCartDetails)
+			cart.Delete("/", handlers.ClearCart)
+			cart.Put("/item/{id}", handlers.SetCartItem)
+			cart.Delete("/item/{id}", handlers.RemoveCartItem)
+			cart.Post("/checkout", handlers.CheckoutCart)
+			cart.Post("/schedule", handlers.ScheduleCart)
+		})
+
+		// saved baskets of items ordered again and again
+		user.Route("/basket", func(basket chi.Router) {
+			basket.Get("/", handlers.GetBaskets)
+			basket.Post("/", handlers.CreateBasket)
+			basket.Put("/{id}", handlers.UpdateBasket)
+			basket.Delete("/{id}", handlers.ArchiveBasket)
+			basket.Post("/{id}/order", handlers.OrderBasket)
+			basket.Post("/{id}/schedule", handlers.ScheduleBasket)
+		})
+
 		// discount
 		user.Get("/discount", handlers.GetActiveDiscount)
 		//admin contact info
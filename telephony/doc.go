@@ -0,0 +1,2 @@
+// Package telephony hides the phone numbers of the user and the staff behind per order proxy contacts
+package telephony
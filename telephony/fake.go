@@ -0,0 +1,54 @@
+package telephony
+
+import (
+	"fmt"
+	"sync"
+)
+
+//FakeProvider hands out made up proxy numbers and keeps them in memory, meant for tests and local setups
+type FakeProvider struct {
+	mu     sync.Mutex
+	next   int
+	active map[string]ContactRequest
+}
+
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{active: make(map[string]ContactRequest)}
+}
+
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *FakeProvider) Allocate(req ContactRequest) (Contact, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.next++
+	ref := fmt.Sprintf("fake-%d", p.next)
+	p.active[ref] = req
+	return Contact{
+		Kind:        ProxyNumber,
+		Value:       fmt.Sprintf("+1555%07d", p.next),
+		ProviderRef: ref,
+		ExpiresAt:   req.ExpiresAt,
+	}, nil
+}
+
+func (p *FakeProvider) Release(providerRef string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.active[providerRef]; !ok {
+		return ErrNotFound
+	}
+	delete(p.active, providerRef)
+	return nil
+}
+
+//Active returns the number of contacts that have not been released yet
+func (p *FakeProvider) Active() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.active)
+}
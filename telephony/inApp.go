@@ -0,0 +1,84 @@
+package telephony
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+//InAppProvider issues signed call tokens for the in-app dialer, no phone number ever leaves the server
+type InAppProvider struct {
+	secret []byte
+}
+
+//CallClaims are the details carried by an in-app call token
+type CallClaims struct {
+	OrderID   int
+	CallerID  int
+	CalleeID  int
+	ExpiresAt time.Time
+}
+
+//ErrInvalidCallToken is returned when a call token is not signed by us or has expired
+var ErrInvalidCallToken = errors.New("invalid call token")
+
+func NewInAppProvider(secret []byte) *InAppProvider {
+	return &InAppProvider{secret: secret}
+}
+
+func (p *InAppProvider) Name() string {
+	return "in-app"
+}
+
+func (p *InAppProvider) Allocate(req ContactRequest) (Contact, error) {
+	payload := fmt.Sprintf("%d.%d.%d.%d", req.OrderID, req.CallerID, req.CalleeID, req.ExpiresAt.Unix())
+	token := base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + p.sign(payload)
+	return Contact{
+		Kind:        CallToken,
+		Value:       token,
+		ProviderRef: payload,
+		ExpiresAt:   req.ExpiresAt,
+	}, nil
+}
+
+//Release is a no-op, call tokens stop working once they expire and released tokens are not served again
+func (p *InAppProvider) Release(providerRef string) error {
+	return nil
+}
+
+//Verify checks the signature and expiry of a call token and returns its claims
+func (p *InAppProvider) Verify(token string) (CallClaims, error) {
+	claims := CallClaims{}
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return claims, ErrInvalidCallToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return claims, ErrInvalidCallToken
+	}
+	if !hmac.Equal([]byte(p.sign(string(payload))), []byte(parts[1])) {
+		return claims, ErrInvalidCallToken
+	}
+
+	var expiresAt int64
+	_, err = fmt.Sscanf(string(payload), "%d.%d.%d.%d", &claims.OrderID, &claims.CallerID, &claims.CalleeID, &expiresAt)
+	if err != nil {
+		return claims, ErrInvalidCallToken
+	}
+	claims.ExpiresAt = time.Unix(expiresAt, 0)
+	if time.Now().After(claims.ExpiresAt) {
+		return claims, fmt.Errorf("%w: expired", ErrInvalidCallToken)
+	}
+	return claims, nil
+}
+
+func (p *InAppProvider) sign(payload string) string {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
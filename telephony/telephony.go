@@ -0,0 +1,78 @@
+package telephony
+
+import (
+	"crypto/rand"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"os"
+	"time"
+)
+
+type ContactKind string
+
+const (
+	ProxyNumber ContactKind = "proxy_number"
+	CallToken   ContactKind = "call_token"
+)
+
+//ErrNotFound is returned when releasing a contact the provider doesn't know about
+var ErrNotFound = errors.New("contact not found")
+
+//ContactRequest holds the two parties of an order that need to reach each other
+type ContactRequest struct {
+	OrderID     int
+	CallerID    int
+	CallerPhone string
+	CalleeID    int
+	CalleePhone string
+	ExpiresAt   time.Time
+}
+
+//Contact is what the caller dials or hands to the in-app dialer instead of the callee's phone
+type Contact struct {
+	Kind        ContactKind
+	Value       string
+	ProviderRef string
+	ExpiresAt   time.Time
+}
+
+//Provider issues and releases masked contacts, implemented by every telephony vendor we plug in
+type Provider interface {
+	Name() string
+	Allocate(req ContactRequest) (Contact, error)
+	Release(providerRef string) error
+}
+
+//CallTokenVerifier is implemented by the providers issuing call tokens, the tokens are redeemed to connect the call
+type CallTokenVerifier interface {
+	Verify(token string) (CallClaims, error)
+}
+
+//ProviderInstance is the provider selected with the TELEPHONY_PROVIDER env
+var ProviderInstance Provider
+
+func init() {
+	switch os.Getenv("TELEPHONY_PROVIDER") {
+	case "fake":
+		ProviderInstance = NewFakeProvider()
+	case "", "in-app":
+		ProviderInstance = NewInAppProvider(callTokenSecret())
+	default:
+		logrus.Warnf("telephony: unknown provider %q, using in-app call tokens", os.Getenv("TELEPHONY_PROVIDER"))
+		ProviderInstance = NewInAppProvider(callTokenSecret())
+	}
+}
+
+//callTokenSecret is the CALL_TOKEN_SECRET env, without it a random secret is used and the
+//tokens issued stop working when the server restarts
+func callTokenSecret() []byte {
+	if secret := os.Getenv("CALL_TOKEN_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	logrus.Warn("telephony: CALL_TOKEN_SECRET is not set, call tokens are signed with a random secret")
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		logrus.Fatalf("telephony: unable to generate call token secret: %v", err)
+	}
+	return secret
+}
@@ -1,7 +1,9 @@
 package utils
 
 import (
+	crand "crypto/rand"
 	"crypto/sha512"
+	"crypto/subtle"
 	"encoding/csv"
 	"encoding/hex"
 	"encoding/json"
@@ -13,17 +15,28 @@ import (
 	"io"
 	"io/ioutil"
 	"math"
+	"math/big"
 	"math/rand"
 	"mime/multipart"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 )
 
 var generator *shortid.Shortid
 
+var (
+	phoneNumberRegex = regexp.MustCompile(`\+?\d(?:[\s-]?\d){9,}`)
+	emailRegex       = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+)
+
 type clientError struct {
 	ID            string `json:"id"`
 	MessageToUser string `json:"messageToUser"`
@@ -113,12 +126,19 @@ func StringToInt(str string) (int, error) {
 	return val, nil
 }
 
-//GenerateOTP generates and returns a random number less the 9999
-func GenerateOTP() int {
+//GenerateOTP generates a cryptographically random 4 digit otp
+func GenerateOTP() (int, error) {
 	max, min := 9999, 1000
-	rand.Seed(time.Now().UnixNano())
-	randomNum := rand.Intn(max-min) + min
-	return randomNum
+	n, err := crand.Int(crand.Reader, big.NewInt(int64(max-min+1)))
+	if err != nil {
+		return 0, err
+	}
+	return int(n.Int64()) + min, nil
+}
+
+//CompareOTP compares the entered otp with the stored one in constant time
+func CompareOTP(entered, stored int) bool {
+	return subtle.ConstantTimeCompare([]byte(strconv.Itoa(entered)), []byte(strconv.Itoa(stored))) == 1
 }
 
 func GeoDistance(lng1 float64, lat1 float64, lng2 float64, lat2 float64) float64 {
@@ -179,6 +199,165 @@ func ReadFromFile(r *http.Request, imageType string) (multipart.File, []byte, st
 	return file, fileBytes, handler.Filename, nil
 }
 
+// ValidateImage checks the size and the detected content type of an uploaded image
+func ValidateImage(fileBytes []byte, maxSize int) error {
+	if len(fileBytes) == 0 {
+		return fmt.Errorf("empty image file")
+	}
+	if len(fileBytes) > maxSize {
+		return fmt.Errorf("image must be smaller than %d MB", maxSize>>20)
+	}
+	contentType := http.DetectContentType(fileBytes)
+	for _, allowed := range models.AllowedImageContentTypes {
+		if contentType == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported image type %s", contentType)
+}
+
+// blockedNetworks are the addresses an url given by a client must never reach: loopback, private,
+// link-local (cloud metadata), shared, multicast and unspecified ones
+var blockedNetworks = func() []*net.IPNet {
+	cidrs := []string{"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8", "169.254.0.0/16", "172.16.0.0/12",
+		"192.0.0.0/24", "192.168.0.0/16", "198.18.0.0/15", "224.0.0.0/4", "240.0.0.0/4",
+		"::/128", "::1/128", "fc00::/7", "fe80::/10", "ff00::/8"}
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}()
+
+// isPublicIP tells if the ip is reachable on the internet, an ipv4 mapped ipv6 is checked as ipv4
+func isPublicIP(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// publicDialer only connects to public addresses, the check runs on the resolved address of every
+// connection so redirects and dns rebinding can't reach the internal network
+var publicDialer = &net.Dialer{
+	Timeout: 10 * time.Second,
+	Control: func(network, address string, _ syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+			return fmt.Errorf("address %s is not allowed", host)
+		}
+		return nil
+	},
+}
+
+// DownloadImage fetches the image at the public http(s) url and checks it the way an uploaded image is checked
+func DownloadImage(imageURL string, maxSize int) ([]byte, error) {
+	client := http.Client{
+		Timeout: 30 * time.Second,
+		// no proxy, the connection has to go to the checked address
+		Transport: &http.Transport{DialContext: publicDialer.DialContext},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return fmt.Errorf("too many redirects")
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("redirect to %s is not allowed", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+	parsed, err := url.Parse(imageURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return nil, fmt.Errorf("image url must be http or https")
+	}
+	resp, err := client.Get(imageURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("image download failed with status %d", resp.StatusCode)
+	}
+	fileBytes, err := ioutil.ReadAll(io.LimitReader(resp.Body, int64(maxSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	return fileBytes, ValidateImage(fileBytes, maxSize)
+}
+
+// maxBlockedWordsRegexes is the most blocked words regexes cached, the cache is emptied once it is full
+const maxBlockedWordsRegexes = 100
+
+// blockedWordsRegexes caches the blocked words regexes by pattern so they are compiled again only when the chat
+// settings change
+var (
+	blockedWordsMu      sync.Mutex
+	blockedWordsRegexes = make(map[string]*regexp.Regexp)
+)
+
+// blockedWordsRegex returns the regex matching any of the blocked words as a whole word, nil when there is none
+func blockedWordsRegex(blockedWords []string) (*regexp.Regexp, error) {
+	words := make([]string, 0, len(blockedWords))
+	for _, word := range blockedWords {
+		if word = strings.TrimSpace(word); word != "" {
+			words = append(words, regexp.QuoteMeta(word))
+		}
+	}
+	if len(words) == 0 {
+		return nil, nil
+	}
+	pattern := `(?i)\b(?:` + strings.Join(words, "|") + `)\b`
+
+	blockedWordsMu.Lock()
+	defer blockedWordsMu.Unlock()
+	if blocked, ok := blockedWordsRegexes[pattern]; ok {
+		return blocked, nil
+	}
+	blocked, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(blockedWordsRegexes) >= maxBlockedWordsRegexes {
+		blockedWordsRegexes = make(map[string]*regexp.Regexp)
+	}
+	blockedWordsRegexes[pattern] = blocked
+	return blocked, nil
+}
+
+// MaskChatMessage hides phone numbers, emails and blocked words in a chat message as per the settings
+func MaskChatMessage(message string, settings models.ChatSettings) string {
+	if settings.MaskPhone {
+		message = phoneNumberRegex.ReplaceAllString(message, "[phone hidden]")
+	}
+	if settings.MaskEmail {
+		message = emailRegex.ReplaceAllString(message, "[email hidden]")
+	}
+
+	blocked, err := blockedWordsRegex(settings.BlockedWords)
+	if err != nil {
+		logrus.Errorf("MaskChatMessage: invalid blocked words error: %v", err)
+		return message
+	}
+	if blocked == nil {
+		return message
+	}
+	return blocked.ReplaceAllStringFunc(message, func(word string) string {
+		return strings.Repeat("*", len([]rune(word)))
+	})
+}
+
 func GenerateJWT(id int, email string) (string, error) {
 	token := jwt.New(jwt.SigningMethodHS256)
 	claims := token.Claims.(jwt.MapClaims)
@@ -236,7 +415,8 @@ func CreateCsvOfOrders(orders []models.ScheduledOrderCsv) (string, error) {
 
 func CreateCsvOfOrderHistory(orders []models.ScheduledOrderCsv) (string, error) {
 	data := make([][]string, 0)
-	csvHeader := []string{"orderId", "mode", "userName", "userPhone", "address", "deliveryTime", "status", "staffName", "staffPhone", "createdAt"}
+	csvHeader := []string{"orderId", "mode", "userName", "userPhone", "address", "deliveryTime", "status", "staffName", "staffPhone", "createdAt",
+		"taxableAmount", "taxAmount", "amount"}
 	for i := 1; i < 51; i++ {
 		csvHeader = append(csvHeader, "itemName-(quantity * baseQuantity)")
 	}
@@ -253,6 +433,12 @@ func CreateCsvOfOrderHistory(orders []models.ScheduledOrderCsv) (string, error)
 			order.Status,
 			order.StaffName.String, order.StaffPhone.String,
 			order.CreatedAt.In(loc).Format(time.RFC850)}
+		var taxable, tax float32
+		for _, item := range order.Items {
+			taxable += item.TaxableAmount
+			tax += item.TaxAmount
+		}
+		record = append(record, fmt.Sprintf("%.2f", taxable), fmt.Sprintf("%.2f", tax), fmt.Sprintf("%.2f", order.Amount))
 		for _, item := range order.Items {
 			record = append(record, fmt.Sprintf("%s - (%d * %s)", item.Name, item.Quantity, item.BaseQuantity))
 		}
@@ -318,7 +504,7 @@ func FilterOrderItems(Items []models.ItemInfo) []models.ItemInfo {
 		item := Items[i]
 		exist := false
 		for j := range newItems {
-			if newItems[j].Id == item.Id {
+			if newItems[j].Id == item.Id && newItems[j].VariantID == item.VariantID {
 				exist = true
 			}
 		}
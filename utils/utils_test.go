@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"github.com/RemoteState/yourdaily-server/models"
+	"testing"
+)
+
+func TestMaskChatMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  string
+		settings models.ChatSettings
+		want     string
+	}{
+		{"nothing to mask", "leave it at the door", models.ChatSettings{MaskPhone: true, MaskEmail: true}, "leave it at the door"},
+		{"phone", "call me on 9876543210", models.ChatSettings{MaskPhone: true}, "call me on [phone hidden]"},
+		{"phone with country code and spaces", "call +91 98765 43210 please", models.ChatSettings{MaskPhone: true}, "call [phone hidden] please"},
+		{"phone with dashes", "98765-43210", models.ChatSettings{MaskPhone: true}, "[phone hidden]"},
+		{"short numbers are kept", "order 12345 for flat 402", models.ChatSettings{MaskPhone: true}, "order 12345 for flat 402"},
+		{"phone not masked", "call me on 9876543210", models.ChatSettings{}, "call me on 9876543210"},
+		{"email", "mail a.b+c@example.co.in now", models.ChatSettings{MaskEmail: true}, "mail [email hidden] now"},
+		{"email not masked", "mail a.b@example.com", models.ChatSettings{MaskPhone: true}, "mail a.b@example.com"},
+		{"blocked word of any case", "You IDIOT!", models.ChatSettings{BlockedWords: []string{"idiot"}}, "You *****!"},
+		{"blocked words are whole words", "Dumb and dumber", models.ChatSettings{BlockedWords: []string{"dumb"}}, "**** and dumber"},
+		{"blocked words are trimmed", "what a fool", models.ChatSettings{BlockedWords: []string{" fool ", "", " "}}, "what a ****"},
+		{"blocked words are not patterns", "a.b and axb", models.ChatSettings{BlockedWords: []string{"a.b"}}, "*** and axb"},
+		{"blank blocked words", "what a fool", models.ChatSettings{BlockedWords: []string{"", " "}}, "what a fool"},
+		{
+			name:     "everything",
+			message:  "idiot, call 9876543210 or mail me@example.com",
+			settings: models.ChatSettings{MaskPhone: true, MaskEmail: true, BlockedWords: []string{"idiot"}},
+			want:     "*****, call [phone hidden] or mail [email hidden]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MaskChatMessage(tt.message, tt.settings); got != tt.want {
+				t.Errorf("MaskChatMessage(%q) = %q, want %q", tt.message, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBlockedWordsRegexIsCached(t *testing.T) {
+	first, err := blockedWordsRegex([]string{"idiot", "fool"})
+	if err != nil {
+		t.Fatalf("blockedWordsRegex() error = %v", err)
+	}
+	again, err := blockedWordsRegex([]string{" idiot", "fool "})
+	if err != nil {
+		t.Fatalf("blockedWordsRegex() error = %v", err)
+	}
+	if first != again {
+		t.Errorf("blockedWordsRegex() compiled the same blocked words again")
+	}
+	changed, err := blockedWordsRegex([]string{"idiot"})
+	if err != nil {
+		t.Fatalf("blockedWordsRegex() error = %v", err)
+	}
+	if changed == first {
+		t.Errorf("blockedWordsRegex() kept the regex of the previous blocked words")
+	}
+}